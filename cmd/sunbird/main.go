@@ -1,19 +1,28 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"github.com/radeqq007/sunbird/internal/ast"
 	"github.com/radeqq007/sunbird/internal/evaluator"
 	"github.com/radeqq007/sunbird/internal/lexer"
+	sunbirdio "github.com/radeqq007/sunbird/internal/modules/io"
 	"github.com/radeqq007/sunbird/internal/object"
 	"github.com/radeqq007/sunbird/internal/parser"
 	"github.com/radeqq007/sunbird/internal/pkg"
 	"github.com/radeqq007/sunbird/internal/repl"
-	"io"
+	"github.com/radeqq007/sunbird/internal/sandbox"
+	"github.com/radeqq007/sunbird/internal/vfs"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 func main() {
+	runBundle()
+
 	if len(os.Args) < 2 {
 		fmt.Println("Welcome to the sunbird programming language!")
 		fmt.Printf("Type in 'exit' to exit.\n")
@@ -35,32 +44,109 @@ func main() {
 		handleUpdate()
 	case "tidy":
 		handleTidy()
+	case "cache":
+		handleCache()
+	case "pack":
+		handlePack()
+	case "build":
+		handleBuild()
+	case "task":
+		handleTask()
 	case "run":
 		handleRun()
 	case "help", "-h", "--help":
 		printHelp()
 	case "version", "-v", "--version":
 		printVersion()
+	default:
+		handleRunDirect(command)
 	}
 
 	os.Exit(0)
 }
 
 func handleRun() {
-	filePath, err := resolveFilePath()
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	run := addRunFlags(flags)
+	_ = flags.Parse(os.Args[2:])
+
+	limits, policy, err := run.settings()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	filePath, err := resolveFilePath(flags.Args())
 	if err != nil {
 		fmt.Println("Error: No file specified and no main file found")
-		fmt.Println("Usage: sunbird run [file.sb]")
+		fmt.Println("Usage: sunbird run [flags] [file.sb] [args...]")
+		os.Exit(1)
+	}
+
+	if flags.NArg() > 1 {
+		sunbirdio.SetArgs(flags.Args()[1:])
+	} else {
+		sunbirdio.SetArgs(nil)
+	}
+
+	runFile(filePath, limits, policy, *run.timeout)
+}
+
+// handleRunDirect runs `sunbird <file.sb> [args...]`, without flags. Like
+// `sunbird run`, it uses the default limits and the sandbox of sunbird.toml.
+func handleRunDirect(path string) {
+	if _, err := os.Stat(path); err != nil {
+		fmt.Printf("Error: unknown command or file %q\n", path)
+		fmt.Println("Run 'sunbird help' for usage.")
 		os.Exit(1)
 	}
 
-	runFile(filePath)
+	limits, policy, err := directRunSettings()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	sunbirdio.SetArgs(os.Args[2:])
+	runFile(path, limits, policy, 0)
+}
+
+// runFlags are the flags of `sunbird run`
+type runFlags struct {
+	timeout  *time.Duration
+	maxDepth *int
+	maxSteps *int64
+	sandbox  *sandboxFlags
+}
+
+func addRunFlags(flags *flag.FlagSet) *runFlags {
+	return &runFlags{
+		timeout:  flags.Duration("timeout", 0, "stop the script after the given duration, e.g. 5s"),
+		maxDepth: flags.Int("max-depth", object.DefaultMaxDepth, "maximum function call depth"),
+		maxSteps: flags.Int64("max-steps", 0, "maximum number of evaluation steps, 0 means no limit"),
+		sandbox:  addSandboxFlags(flags),
+	}
+}
+
+// settings returns the limits and the sandbox policy scripts run with
+func (f *runFlags) settings() (object.Limits, *sandbox.Policy, error) {
+	policy, err := f.sandbox.policy()
+	if err != nil {
+		return object.Limits{}, nil, err
+	}
+
+	return object.Limits{MaxDepth: *f.maxDepth, MaxSteps: *f.maxSteps}, policy, nil
+}
+
+// directRunSettings returns the settings of `sunbird run` without flags
+func directRunSettings() (object.Limits, *sandbox.Policy, error) {
+	return addRunFlags(flag.NewFlagSet("run", flag.ContinueOnError)).settings()
 }
 
-func resolveFilePath() (string, error) {
+func resolveFilePath(args []string) (string, error) {
 	// Check CLI Arguments
-	if len(os.Args) >= 3 {
-		return os.Args[2], nil
+	if len(args) > 0 {
+		return args[0], nil
 	}
 
 	// Check Config File
@@ -89,21 +175,13 @@ func findMainFile() (string, error) {
 	return "", errors.New("no main file found")
 }
 
-func runFile(path string) {
-	src, err := os.Open(path)
+func runFile(path string, limits object.Limits, policy *sandbox.Policy, timeout time.Duration) {
+	content, err := vfs.ReadFile(path)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err)
 		os.Exit(1)
 	}
 
-	content, err := io.ReadAll(src)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err)
-		os.Exit(1)
-	}
-
-	_ = src.Close()
-
 	l := lexer.New(string(content))
 	p := parser.New(l)
 
@@ -118,8 +196,11 @@ func runFile(path string) {
 	}
 
 	env := object.NewEnvironment()
+	env.SetFile(path)
+	env.Runtime().SetLimits(limits)
+	env.Runtime().SetPolicy(policy)
 
-	evaluated := evaluator.Eval(program, env)
+	evaluated := evalWithTimeout(program, env, timeout)
 
 	if evaluated.IsError() {
 		fmt.Println(evaluated.Inspect())
@@ -127,6 +208,18 @@ func runFile(path string) {
 	}
 }
 
+// evalWithTimeout evaluates program, stopping it after timeout unless it's zero
+func evalWithTimeout(program *ast.Program, env *object.Environment, timeout time.Duration) object.Value {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return evaluator.Eval(ctx, program, env)
+}
+
 func handleInit() {
 	fmt.Println("Initializing new project...")
 
@@ -175,30 +268,212 @@ io.println("Hello, sunbird!")
 }
 
 func handleInstall() {
+	flags := flag.NewFlagSet("install", flag.ExitOnError)
+	frozen := flags.Bool("frozen", false, "install exactly what sunbird.lock lists, failing if it's out of date")
+	offline := flags.Bool("offline", false, "only use packages already in the cache")
+	_ = flags.Parse(os.Args[2:])
+
+	pkgManager := pkg.NewPackageManager()
+	pkgManager.Frozen = *frozen
+	pkgManager.Offline = *offline
 
+	fmt.Println("Installing dependencies...")
+	if err := pkgManager.Install(); err != nil {
+		fmt.Printf("Error installing dependencies: %s\n", err)
+		os.Exit(1)
+	}
 }
 
 func handleAdd() {
+	flags := flag.NewFlagSet("add", flag.ExitOnError)
+	registry := flags.String("registry", "", "take the package from a directory of package archives")
+	_ = flags.Parse(os.Args[2:])
+
 	pkgManager := pkg.NewPackageManager()
+	pkgManager.Registry = *registry
 
-	if len(os.Args) < 3 {
+	if flags.NArg() < 1 {
 		fmt.Println("Provide a package url")
 		os.Exit(1)
 	}
 
-	url := os.Args[2]
+	url := flags.Arg(0)
 	err := pkgManager.Add(url)
 	if err != nil {
 		fmt.Printf("Error adding dependency: %s\n", err)
+		os.Exit(1)
 	}
 }
 
 func handleUpdate() {
-
+	fmt.Println("Updating dependencies...")
+	if err := pkg.NewPackageManager().Update(); err != nil {
+		fmt.Printf("Error updating dependencies: %s\n", err)
+		os.Exit(1)
+	}
 }
 
 func handleTidy() {
+	fmt.Println("Removing unused dependencies...")
+	if err := pkg.NewPackageManager().Tidy(); err != nil {
+		fmt.Printf("Error tidying dependencies: %s\n", err)
+		os.Exit(1)
+	}
+}
 
+func handlePack() {
+	flags := flag.NewFlagSet("pack", flag.ExitOnError)
+	out := flags.String("o", "", "archive file or directory to write it to")
+	_ = flags.Parse(os.Args[2:])
+
+	fmt.Println("Packing project...")
+	if _, err := pkg.NewPackageManager().Pack(*out); err != nil {
+		fmt.Printf("Error packing project: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func handleBuild() {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	out := flags.String("o", "", "path of the executable")
+	runtime := flags.String("runtime", "", "sunbird executable to build with, e.g. one for another platform")
+	var include stringList
+	flags.Var(&include, "include", "also bundle the files matching the pattern (repeatable)")
+	_ = flags.Parse(os.Args[2:])
+
+	pkgManager := pkg.NewPackageManager()
+	if *runtime != "" {
+		pkgManager.Sunbird = *runtime
+	}
+
+	fmt.Println("Building executable...")
+	if _, err := pkgManager.Build(flags.Arg(0), *out, include); err != nil {
+		fmt.Printf("Error building executable: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// runBundle runs the program built into the executable by 'sunbird build',
+// if there's one, and exits. Its files appear next to the executable.
+func runBundle() {
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+
+	bundle, err := pkg.OpenBundle(exe)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	if bundle == nil {
+		return
+	}
+
+	dir := filepath.Dir(exe)
+	if err := vfs.Mount(dir, bundle.Files); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	var policy *sandbox.Policy
+	if bundle.Sandbox != nil {
+		policy, err = bundle.Sandbox.Policy(filepath.Join(dir, filepath.FromSlash(bundle.Project)))
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	sunbirdio.SetArgs(os.Args[1:])
+	runFile(filepath.Join(dir, filepath.FromSlash(bundle.Entry)), object.Limits{MaxDepth: object.DefaultMaxDepth}, policy, 0)
+	os.Exit(0)
+}
+
+func handleTask() {
+	pkgManager := pkg.NewPackageManager()
+
+	if len(os.Args) < 3 {
+		tasks, err := pkgManager.Tasks()
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Usage: sunbird task <name> [args...]")
+		if len(tasks) > 0 {
+			fmt.Println("\nTasks:")
+			for _, task := range tasks {
+				fmt.Printf("  %s\n", task)
+			}
+		}
+		return
+	}
+
+	args := os.Args[3:]
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+
+	if err := pkgManager.RunTask(os.Args[2], args); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func handleCache() {
+	dir, err := pkg.DefaultCacheDir()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	cache := &pkg.Cache{Dir: dir}
+
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: sunbird cache <list|clean>")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "list":
+		entries, err := cache.List()
+		if err != nil {
+			fmt.Printf("Error reading cache: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Cache: %s\n", dir)
+		var total int64
+		for _, entry := range entries {
+			fmt.Printf("  %-8s %-10s %s\n", entry.Kind, formatSize(entry.Size), entry.Name)
+			total += entry.Size
+		}
+		fmt.Printf("%d entries, %s\n", len(entries), formatSize(total))
+	case "clean":
+		if err := cache.Clean(); err != nil {
+			fmt.Printf("Error cleaning cache: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %s\n", dir)
+	default:
+		fmt.Printf("Unknown cache command: %s\n", os.Args[2])
+		fmt.Println("Usage: sunbird cache <list|clean>")
+		os.Exit(1)
+	}
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
 }
 
 func printHelp() {
@@ -210,10 +485,33 @@ Usage:
 Commands:
   init                Initialize a new Sunbird project
   install, i          Install dependencies from sunbird.toml
-  get <package>       Download and install a specific package
+                        --frozen              fail if sunbird.lock is out of date
+                        --offline             only use packages already in the cache
+  add <package>       Download a package and add it to sunbird.toml
+                        (package[@version], e.g. github.com/user/pkg@^1.2,
+                        or a package archive like ./pkg-1.0.0.tar.gz)
+                        --registry <dir>      take name[@version] from a directory of archives
   update              Update all dependencies
-  tidy                Remove unused dependencies
+  tidy                Remove dependencies no file imports
+  task <name> [args]  Run a script from the [scripts] table of sunbird.toml
+  pack                Build a package archive, name-version.tar.gz
+                        -o <path>             archive file or directory to write it to
+  build [file]        Build a single executable running the project
+                        -o <path>             path of the executable
+                        --include <pattern>   also bundle matching files, e.g. for fs.read
+                        --runtime <sunbird>   sunbird executable to build with
+  cache list          List the downloaded repositories and packages
+  cache clean         Remove everything from the download cache
   run <file>          Run a Sunbird file with package resolution
+                        --timeout <duration>  stop the script after the given time
+                        --max-depth <n>       maximum function call depth
+                        --max-steps <n>       maximum number of evaluation steps
+                        --deny-all            deny every capability not allowed below
+                        --allow-read <dir>    only allow reading inside dir
+                        --allow-write <dir>   only allow writing inside dir
+                        --allow-net, --deny-net
+                        --allow-exit, --deny-exit
+                        --deny-module <name>  deny importing a built-in module
   help, -h, --help    Show this help message
   version, -v         Show version information
 
@@ -223,9 +521,11 @@ Running files:
 
 Examples:
   sunbird init
-  sunbird get github.com/user/package@v1.0.0
+  sunbird add github.com/user/package@v1.0.0
   sunbird install
   sunbird run main.sb
+  sunbird build -o app
+  sunbird run --timeout 5s --max-depth 1000 main.sb
   sunbird main.sb
 
 For more information, visit: https://github.com/radeqq007/sunbird
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/sandbox"
+)
+
+func TestRunSettingsUseSandboxConfig(t *testing.T) {
+	t.Chdir(t.TempDir())
+	config := "[package]\nname = \"app\"\n\n[sandbox]\nallow_net = false\ndeny_modules = [\"http\"]\n"
+	if err := os.WriteFile("sunbird.toml", []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) (object.Limits, *sandbox.Policy) {
+		flags := flag.NewFlagSet("run", flag.ContinueOnError)
+		f := addRunFlags(flags)
+		if err := flags.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		limits, policy, err := f.settings()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return limits, policy
+	}
+
+	// sunbird run file.sb
+	limits, policy := run("main.sb")
+	if policy.CheckNet() == nil || policy.CheckImport("http") == nil {
+		t.Error("expected sunbird run file.sb to apply the sandbox")
+	}
+	if limits.MaxDepth != object.DefaultMaxDepth {
+		t.Errorf("expected the default depth, got %d", limits.MaxDepth)
+	}
+
+	// sunbird file.sb
+	limits, policy, err := directRunSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.CheckNet() == nil || policy.CheckImport("http") == nil {
+		t.Error("expected sunbird file.sb to apply the sandbox")
+	}
+	if limits.MaxDepth != object.DefaultMaxDepth {
+		t.Errorf("expected the default depth, got %d", limits.MaxDepth)
+	}
+
+	// Flags take precedence over the config
+	if _, policy := run("--allow-net", "main.sb"); policy.CheckNet() != nil {
+		t.Errorf("expected --allow-net to allow network access, got %v", policy.CheckNet())
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"strings"
+
+	"github.com/radeqq007/sunbird/internal/pkg"
+	"github.com/radeqq007/sunbird/internal/sandbox"
+)
+
+// stringList is a flag that can be given several times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+type sandboxFlags struct {
+	denyAll     bool
+	allowRead   stringList
+	allowWrite  stringList
+	allowNet    bool
+	denyNet     bool
+	allowExit   bool
+	denyExit    bool
+	denyModules stringList
+}
+
+func addSandboxFlags(flags *flag.FlagSet) *sandboxFlags {
+	f := &sandboxFlags{}
+	flags.BoolVar(&f.denyAll, "deny-all", false, "deny every capability that isn't explicitly allowed")
+	flags.Var(&f.allowRead, "allow-read", "only allow reading files inside the given directory (repeatable)")
+	flags.Var(&f.allowWrite, "allow-write", "only allow writing files inside the given directory (repeatable)")
+	flags.BoolVar(&f.allowNet, "allow-net", false, "allow network access")
+	flags.BoolVar(&f.denyNet, "deny-net", false, "deny network access")
+	flags.BoolVar(&f.allowExit, "allow-exit", false, "allow scripts to exit the process")
+	flags.BoolVar(&f.denyExit, "deny-exit", false, "deny scripts exiting the process")
+	flags.Var(&f.denyModules, "deny-module", "deny importing the given built-in module (repeatable)")
+	return f
+}
+
+func (f *sandboxFlags) isSet() bool {
+	return f.denyAll || f.allowNet || f.denyNet || f.allowExit || f.denyExit ||
+		len(f.allowRead) > 0 || len(f.allowWrite) > 0 || len(f.denyModules) > 0
+}
+
+// policy combines the [sandbox] table of sunbird.toml with the command line flags,
+// the flags taking precedence. It returns nil when nothing is restricted.
+func (f *sandboxFlags) policy() (*sandbox.Policy, error) {
+	config, err := pkg.LoadConfig("sunbird.toml")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var policy *sandbox.Policy
+	if config != nil && config.Sandbox != nil {
+		policy, err = config.Sandbox.Policy(".")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !f.isSet() {
+		return policy, nil
+	}
+
+	if policy == nil {
+		policy = sandbox.New()
+	}
+
+	if f.denyAll {
+		policy.DenyAll()
+	}
+
+	for _, dir := range f.allowRead {
+		if err := policy.AllowRead(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, dir := range f.allowWrite {
+		if err := policy.AllowWrite(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case f.allowNet:
+		policy.SetNet(true)
+	case f.denyNet:
+		policy.SetNet(false)
+	}
+
+	switch {
+	case f.allowExit:
+		policy.SetExit(true)
+	case f.denyExit:
+		policy.SetExit(false)
+	}
+
+	for _, name := range f.denyModules {
+		policy.DenyModule(name)
+	}
+
+	return policy, nil
+}
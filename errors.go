@@ -0,0 +1,29 @@
+package sunbird
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is an error raised by a script and not caught by it
+type Error struct {
+	Message string
+	Line    int
+	Col     int
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s (at line %d, col %d)", e.Message, e.Line, e.Col)
+	}
+	return e.Message
+}
+
+// ParseError lists the syntax errors that kept a script from running
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parse errors:\n\t" + strings.Join(e.Errors, "\n\t")
+}
@@ -256,3 +256,20 @@ func (re *RangeExpression) String() string {
 	}
 	return out.String()
 }
+
+type AwaitExpression struct {
+	Token token.Token // the 'await' token
+	Value Expression
+}
+
+func (ae *AwaitExpression) expressionNode()      {}
+func (ae *AwaitExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AwaitExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(await ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
@@ -104,6 +104,7 @@ type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
 	Body       *BlockStatement
+	IsAsync    bool
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -116,6 +117,9 @@ func (fl *FunctionLiteral) String() string {
 		params = append(params, p.String())
 	}
 
+	if fl.IsAsync {
+		out.WriteString("async ")
+	}
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
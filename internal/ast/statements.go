@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"strings"
 
 	"github.com/radeqq007/sunbird/internal/token"
 )
@@ -123,6 +124,25 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// DeferStatement runs Body when the enclosing function returns.
+// Body is either an expression or a block statement.
+type DeferStatement struct {
+	Token token.Token
+	Body  Node
+}
+
+func (ds *DeferStatement) statementNode()       {}
+func (ds *DeferStatement) TokenLiteral() string { return ds.Token.Literal }
+
+func (ds *DeferStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("defer ")
+	out.WriteString(ds.Body.String())
+
+	return out.String()
+}
+
 type PropertyAssignStatement struct {
 	Statement
 	Token    token.Token // identifier
@@ -146,17 +166,56 @@ func (pas *PropertyAssignStatement) String() string {
 	return out.String()
 }
 
-type ImportStatement struct {
-	Token token.Token
-	Path  *StringLiteral
+// ModuleMember is a name listed in braces by an import or export statement,
+// like `post as p` in `import { get, post as p } from "http"`
+type ModuleMember struct {
+	Name  *Identifier
 	Alias *Identifier
 }
 
+// BoundName is the name the member is known as on the other side of the statement
+func (mm *ModuleMember) BoundName() string {
+	if mm.Alias != nil {
+		return mm.Alias.Value
+	}
+	return mm.Name.Value
+}
+
+func (mm *ModuleMember) String() string {
+	if mm.Alias != nil {
+		return mm.Name.String() + " as " + mm.Alias.String()
+	}
+	return mm.Name.String()
+}
+
+func membersString(members []*ModuleMember) string {
+	names := make([]string, len(members))
+	for i, member := range members {
+		names[i] = member.String()
+	}
+	return "{ " + strings.Join(names, ", ") + " }"
+}
+
+type ImportStatement struct {
+	Token    token.Token
+	Path     *StringLiteral
+	Alias    *Identifier
+	Members  []*ModuleMember // import { a, b } from "path", nil when importing the module
+	Wildcard bool            // import * from "path"
+}
+
 func (is *ImportStatement) statementNode()       {}
 func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
 func (is *ImportStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString("import ")
+	switch {
+	case is.Wildcard:
+		out.WriteString("* from ")
+	case is.Members != nil:
+		out.WriteString(membersString(is.Members))
+		out.WriteString(" from ")
+	}
 	out.WriteString(is.Path.String())
 	if is.Alias != nil {
 		out.WriteString(" as ")
@@ -168,7 +227,9 @@ func (is *ImportStatement) String() string {
 
 type ExportStatement struct {
 	Token       token.Token
-	Declaration Expression // variable declaration
+	Declaration Expression      // variable declaration
+	Members     []*ModuleMember // export { a, b }
+	From        *StringLiteral  // export * from "path"
 }
 
 func (es *ExportStatement) statementNode()       {}
@@ -176,7 +237,15 @@ func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
 func (es *ExportStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString("export ")
-	if es.Declaration != nil {
+	switch {
+	case es.From != nil:
+		out.WriteString("* from ")
+		out.WriteString(es.From.String())
+		out.WriteString(";")
+	case es.Members != nil:
+		out.WriteString(membersString(es.Members))
+		out.WriteString(";")
+	case es.Declaration != nil:
 		out.WriteString(es.Declaration.String())
 	}
 	return out.String()
@@ -27,11 +27,15 @@ func _() {
 	_ = x[PropertyAccessOnNonObjectError-16]
 	_ = x[UnknownOperatorError-17]
 	_ = x[FeatureNotImplementedError-18]
+	_ = x[RecursionError-19]
+	_ = x[TimeoutError-20]
+	_ = x[StepLimitError-21]
+	_ = x[PermissionError-22]
 }
 
-const _ErrorCode_name = "SyntaxErrorTypeErrorTypeMismatchErrorUndefinedVariableErrorDivisionByZeroErrorConstantReassignmentErrorRuntimeErrorIndexNotSupportedErrorIndexOutOfBoundsErrorKeyErrorImportErrorVariableReassignmentErrorNotCallableErrorInvalidAssignmentErrorArgumentErrorParseErrorPropertyAccessOnNonObjectErrorUnknownOperatorErrorFeatureNotImplementedError"
+const _ErrorCode_name = "SyntaxErrorTypeErrorTypeMismatchErrorUndefinedVariableErrorDivisionByZeroErrorConstantReassignmentErrorRuntimeErrorIndexNotSupportedErrorIndexOutOfBoundsErrorKeyErrorImportErrorVariableReassignmentErrorNotCallableErrorInvalidAssignmentErrorArgumentErrorParseErrorPropertyAccessOnNonObjectErrorUnknownOperatorErrorFeatureNotImplementedErrorRecursionErrorTimeoutErrorStepLimitErrorPermissionError"
 
-var _ErrorCode_index = [...]uint16{0, 11, 20, 37, 59, 78, 103, 115, 137, 158, 166, 177, 202, 218, 240, 253, 263, 293, 313, 339}
+var _ErrorCode_index = [...]uint16{0, 11, 20, 37, 59, 78, 103, 115, 137, 158, 166, 177, 202, 218, 240, 253, 263, 293, 313, 339, 353, 365, 379, 394}
 
 func (i ErrorCode) String() string {
 	idx := int(i) - 0
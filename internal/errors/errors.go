@@ -29,6 +29,10 @@ const (
 	PropertyAccessOnNonObjectError
 	UnknownOperatorError
 	FeatureNotImplementedError
+	RecursionError
+	TimeoutError
+	StepLimitError
+	PermissionError
 )
 
 //go:generate stringer -type=ErrorCode
@@ -185,3 +189,19 @@ func NewArgumentError(line, col int, format string, args ...any) object.Value {
 func NewFeatureNotImplementedError(line, col int, feature string) object.Value {
 	return New(FeatureNotImplementedError, line, col, "%s", feature)
 }
+
+func NewRecursionError(line, col, maxDepth int) object.Value {
+	return New(RecursionError, line, col, "maximum call depth of %d exceeded", maxDepth)
+}
+
+func NewTimeoutError(line, col int, format string, args ...any) object.Value {
+	return New(TimeoutError, line, col, format, args...)
+}
+
+func NewStepLimitError(line, col int, maxSteps int64) object.Value {
+	return New(StepLimitError, line, col, "exceeded the budget of %d steps", maxSteps)
+}
+
+func NewPermissionError(line, col int, err error) object.Value {
+	return New(PermissionError, line, col, "%s", err)
+}
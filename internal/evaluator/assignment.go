@@ -43,7 +43,7 @@ func evalPropertyExpressionAssignment(
 	val object.Value,
 	env *object.Environment,
 ) object.Value {
-	obj := Eval(node.Object, env)
+	obj := eval(node.Object, env)
 	if isError(obj) {
 		return obj
 	}
@@ -68,12 +68,12 @@ func evalIndexExpressionAssignment(
 	val object.Value,
 	env *object.Environment,
 ) object.Value {
-	left := Eval(node.Left, env)
+	left := eval(node.Left, env)
 	if isError(left) {
 		return left
 	}
 
-	index := Eval(node.Index, env)
+	index := eval(node.Index, env)
 	if isError(index) {
 		return index
 	}
@@ -127,7 +127,7 @@ func evalCompoundAssignExpression(
 	val object.Value,
 	env *object.Environment,
 ) object.Value {
-	currentVal := Eval(node.Name, env)
+	currentVal := eval(node.Name, env)
 	if isError(currentVal) {
 		return currentVal
 	}
@@ -0,0 +1,38 @@
+package evaluator
+
+import (
+	"github.com/radeqq007/sunbird/internal/ast"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+// startAsyncCall schedules the body of an async function on the event loop
+// and returns a promise for its result straight away.
+func startAsyncCall(fn *object.Function, fnEnv *object.Environment) object.Value {
+	promise := object.NewPromise()
+
+	fnEnv.Runtime().Go(func() {
+		evaluated := resolveTailCall(runDeferred(fnEnv, eval(fn.Body, fnEnv)))
+		promise.AsPromise().Settle(unwrapReturnValue(evaluated))
+	})
+
+	return promise
+}
+
+func evalAwaitExpression(exp *ast.AwaitExpression, env *object.Environment) object.Value {
+	val := eval(exp.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	// Awaiting anything that isn't a promise just yields the value itself
+	if !val.IsPromise() {
+		return val
+	}
+
+	result, err := env.Runtime().Await(val.AsPromise())
+	if err != nil {
+		return newLimitError(env, err)
+	}
+
+	return result
+}
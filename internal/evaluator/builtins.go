@@ -192,6 +192,10 @@ var builtins = map[string]object.Value{
 			return err
 		}
 
+		if errGo := ctx.Runtime().Policy().CheckExit(); errGo != nil {
+			return errors.NewPermissionError(ctx.Line, ctx.Col, errGo)
+		}
+
 		os.Exit(0)
 		return object.NewNull()
 	},
@@ -8,16 +8,16 @@ import (
 
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Value {
 	ifEnv := object.NewEnclosedEnvironment(env)
-	condition := Eval(ie.Condition, ifEnv)
+	condition := eval(ie.Condition, ifEnv)
 	if isError(condition) {
 		return condition
 	}
 
 	switch {
 	case isTruthy(condition):
-		return Eval(ie.Consequence, ifEnv)
+		return eval(ie.Consequence, ifEnv)
 	case ie.Alternative != nil:
-		return Eval(ie.Alternative, ifEnv)
+		return eval(ie.Alternative, ifEnv)
 	default:
 		return NULL
 	}
@@ -26,7 +26,7 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Valu
 func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Value {
 	loopEnv := object.NewEnclosedEnvironment(env)
 
-	iterable := Eval(fs.Iterable, loopEnv)
+	iterable := eval(fs.Iterable, loopEnv)
 	if isError(iterable) {
 		return iterable
 	}
@@ -54,7 +54,7 @@ func evalRangeLoop(fs *ast.ForStatement, iterable *object.Range, env *object.Env
 		for i := iterable.Start; i < iterable.End; i += step {
 			env.Set(fs.Variable.Value, object.NewInt(i))
 
-			result := Eval(fs.Body, env)
+			result := eval(fs.Body, env)
 			if isError(result) {
 				return result
 			}
@@ -72,7 +72,7 @@ func evalRangeLoop(fs *ast.ForStatement, iterable *object.Range, env *object.Env
 		for i := iterable.Start; i > iterable.End; i += step {
 			env.Set(fs.Variable.Value, object.NewInt(i))
 
-			result := Eval(fs.Body, env)
+			result := eval(fs.Body, env)
 			if isError(result) {
 				return result
 			}
@@ -96,7 +96,7 @@ func evalArrayLoop(fs *ast.ForStatement, iterable *object.Array, env *object.Env
 	for _, element := range iterable.Elements {
 		env.Set(fs.Variable.Value, element)
 
-		result := Eval(fs.Body, env)
+		result := eval(fs.Body, env)
 		if isError(result) {
 			return result
 		}
@@ -120,7 +120,7 @@ func evalStringLoop(fs *ast.ForStatement, iterable *object.String, env *object.E
 	for _, element := range iterable.Value {
 		env.Set(fs.Variable.Value, object.NewString(string(element)))
 
-		result := Eval(fs.Body, env)
+		result := eval(fs.Body, env)
 		if isError(result) {
 			return result
 		}
@@ -145,7 +145,7 @@ func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.
 
 	for {
 		loopEnv := object.NewEnclosedEnvironment(env)
-		condition := Eval(ws.Condition, loopEnv)
+		condition := eval(ws.Condition, loopEnv)
 		if isError(condition) {
 			return condition
 		}
@@ -154,7 +154,7 @@ func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.
 			break
 		}
 
-		result = Eval(ws.Body, loopEnv)
+		result = eval(ws.Body, loopEnv)
 		if isError(result) {
 			return result
 		}
@@ -177,7 +177,7 @@ func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.
 func evalLoopStatement(ls *ast.LoopStatement, env *object.Environment) object.Value {
 	for {
 		loopEnv := object.NewEnclosedEnvironment(env)
-		result := Eval(ls.Body, loopEnv)
+		result := eval(ls.Body, loopEnv)
 		if isError(result) {
 			return result
 		}
@@ -196,7 +196,8 @@ func evalLoopStatement(ls *ast.LoopStatement, env *object.Environment) object.Va
 }
 
 func evalTryCatchStatement(tcs *ast.TryCatchStatement, env *object.Environment) object.Value {
-	tryResult := Eval(tcs.Try, env)
+	// Calls in tail position have to be made here for their errors to be caught
+	tryResult := resolveTailCall(eval(tcs.Try, env))
 
 	result := tryResult
 
@@ -207,11 +208,11 @@ func evalTryCatchStatement(tcs *ast.TryCatchStatement, env *object.Environment)
 		catchEnv := object.NewEnclosedEnvironment(env)
 		catchEnv.Set(tcs.Param.Value, caughtError)
 
-		result = Eval(tcs.Catch, catchEnv)
+		result = resolveTailCall(eval(tcs.Catch, catchEnv))
 	}
 
 	if tcs.Finally != nil {
-		finallyResult := Eval(tcs.Finally, env)
+		finallyResult := eval(tcs.Finally, env)
 		if isError(finallyResult) {
 			return finallyResult
 		}
@@ -235,7 +236,7 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	var result object.Value
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, blockEnv)
+		result = eval(statement, blockEnv)
 
 		if !result.IsNull() {
 			kind := result.Kind()
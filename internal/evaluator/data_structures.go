@@ -11,12 +11,12 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Valu
 	pairs := make(map[object.HashKey]object.HashPair) // ! take a look at this
 
 	for _, pair := range node.Pairs {
-		key := Eval(pair.Key, env)
+		key := eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
 
-		value := Eval(pair.Value, env)
+		value := eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
@@ -108,7 +108,7 @@ func evalStringIndexExpression(left, index object.Value, line, col int) object.V
 }
 
 func evalPropertyExpression(pe *ast.PropertyExpression, env *object.Environment) object.Value {
-	obj := Eval(pe.Object, env)
+	obj := eval(pe.Object, env)
 	if isError(obj) {
 		return obj
 	}
@@ -142,11 +142,12 @@ func evalMethodCall(
 	obj object.Value,
 	method object.Value,
 	args []object.Value,
+	env *object.Environment,
 	line, col int,
 ) object.Value {
 	if !method.IsFunction() {
 		// Not a function, just call normally
-		return applyFunction(method, args, line, col)
+		return applyFunction(method, args, env, line, col)
 	}
 
 	fn := method.AsFunction()
@@ -155,13 +156,14 @@ func evalMethodCall(
 	newEnv.Set("this", obj)
 
 	boundFn := object.NewFunction(fn.Parameters, fn.Body, fn.Env)
+	boundFn.AsFunction().IsAsync = fn.IsAsync
 
-	return applyFunction(boundFn, args, line, col)
+	return applyFunction(boundFn, args, env, line, col)
 }
 
 func evalMethodCallExpression(exp *ast.CallExpression, env *object.Environment) object.Value {
 	propExp, _ := exp.Function.(*ast.PropertyExpression)
-	obj := Eval(propExp.Object, env)
+	obj := eval(propExp.Object, env)
 	if isError(obj) {
 		return obj
 	}
@@ -178,7 +180,7 @@ func evalMethodCallExpression(exp *ast.CallExpression, env *object.Environment)
 			return args[0]
 		}
 
-		return evalMethodCall(obj, method, args, exp.Token.Line, exp.Token.Col)
+		return evalMethodCall(obj, method, args, env, exp.Token.Line, exp.Token.Col)
 	}
 
 	// TODO: handle this in a better way
@@ -193,5 +195,5 @@ func evalMethodCallExpression(exp *ast.CallExpression, env *object.Environment)
 		return args[0]
 	}
 
-	return applyFunction(function, args, exp.Token.Line, exp.Token.Col)
+	return applyFunction(function, args, env, exp.Token.Line, exp.Token.Col)
 }
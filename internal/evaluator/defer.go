@@ -0,0 +1,42 @@
+package evaluator
+
+import (
+	"github.com/radeqq007/sunbird/internal/ast"
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+func evalDeferStatement(stmt *ast.DeferStatement, env *object.Environment) object.Value {
+	if !env.Defer(stmt.Body) {
+		return errors.NewRuntimeError(stmt.Token.Line, stmt.Token.Col, "defer used outside of a function")
+	}
+
+	return NULL
+}
+
+// runDeferred runs everything the function call owning fnEnv deferred,
+// most recent first. Like a finally block, a deferred error or return
+// replaces the result of the function.
+func runDeferred(fnEnv *object.Environment, result object.Value) object.Value {
+	deferred := fnEnv.TakeDeferred()
+	if len(deferred) == 0 {
+		return result
+	}
+
+	// A pending tail call is still part of the function, so it has to be made before cleaning up
+	result = resolveTailCall(result)
+
+	// Deferred code can defer more, so keep going until nothing is left
+	for ; len(deferred) > 0; deferred = fnEnv.TakeDeferred() {
+		for i := len(deferred) - 1; i >= 0; i-- {
+			d := deferred[i]
+
+			val := resolveTailCall(eval(d.Body, d.Env))
+			if isError(val) || val.Kind() == object.ReturnValueKind {
+				result = val
+			}
+		}
+	}
+
+	return result
+}
@@ -1,6 +1,8 @@
 package evaluator
 
 import (
+	"context"
+
 	"github.com/radeqq007/sunbird/internal/ast"
 	"github.com/radeqq007/sunbird/internal/errors"
 	"github.com/radeqq007/sunbird/internal/object"
@@ -14,7 +16,40 @@ var (
 	CONTINUE = object.NewContinue()
 )
 
-func Eval(node ast.Node, env *object.Environment) object.Value {
+// Eval evaluates node in env and waits for every async task started
+// along the way to finish before returning.
+// Evaluation stops with an error once ctx is cancelled or its deadline passes.
+func Eval(ctx context.Context, node ast.Node, env *object.Environment) object.Value {
+	return run(ctx, env, func() object.Value {
+		return eval(node, env)
+	})
+}
+
+// Apply calls fn with args on behalf of Go code, such as a program embedding
+// the interpreter, with the same guarantees as Eval.
+func Apply(ctx context.Context, fn object.Value, args []object.Value, env *object.Environment) object.Value {
+	return run(ctx, env, func() object.Value {
+		return applyFunction(fn, args, env, 0, 0)
+	})
+}
+
+func run(ctx context.Context, env *object.Environment, fn func() object.Value) object.Value {
+	rt := env.Runtime()
+	rt.Acquire()
+	defer rt.Release()
+
+	rt.Begin(ctx)
+	result := fn()
+	rt.Wait()
+
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Value {
+	if err := env.Runtime().Step(); err != nil {
+		return newLimitError(env, err)
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node.Statements, env)
@@ -32,7 +67,7 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Value {
 	var result object.Value
 
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		result = resolveTailCall(eval(statement, env))
 
 		switch result.Kind() {
 		case object.ReturnValueKind:
@@ -57,7 +92,7 @@ func evalStatement(node ast.Statement, env *object.Environment) object.Value {
 		return evalExportStatement(stmt, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(stmt.Expression, env)
+		return eval(stmt.Expression, env)
 
 	case *ast.BreakStatement:
 		return BREAK
@@ -80,10 +115,17 @@ func evalStatement(node ast.Statement, env *object.Environment) object.Value {
 	case *ast.TryCatchStatement:
 		return evalTryCatchStatement(stmt, env)
 
+	case *ast.DeferStatement:
+		return evalDeferStatement(stmt, env)
+
 	case *ast.ReturnStatement:
+		if call, ok := stmt.ReturnValue.(*ast.CallExpression); ok {
+			return evalTailCall(call, env)
+		}
+
 		var val object.Value
 		if stmt.ReturnValue != nil {
-			val = Eval(stmt.ReturnValue, env)
+			val = eval(stmt.ReturnValue, env)
 			if isError(val) {
 				return val
 			}
@@ -99,12 +141,12 @@ func evalStatement(node ast.Statement, env *object.Environment) object.Value {
 func evalExpression(node ast.Expression, env *object.Environment) object.Value {
 	switch exp := node.(type) {
 	case *ast.InfixExpression:
-		left := Eval(exp.Left, env)
+		left := eval(exp.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		right := Eval(exp.Right, env)
+		right := eval(exp.Right, env)
 		if isError(right) {
 			return right
 		}
@@ -112,7 +154,7 @@ func evalExpression(node ast.Expression, env *object.Environment) object.Value {
 		return evalInfixExpression(exp.Operator, left, right, exp.Token.Line, exp.Token.Col)
 
 	case *ast.PrefixExpression:
-		right := Eval(exp.Right, env)
+		right := eval(exp.Right, env)
 		if isError(right) {
 			return right
 		}
@@ -125,7 +167,7 @@ func evalExpression(node ast.Expression, env *object.Environment) object.Value {
 		return evalDeclarationExpression(exp, env)
 
 	case *ast.AssignExpression:
-		val := Eval(exp.Value, env)
+		val := eval(exp.Value, env)
 		if isError(val) {
 			return val
 		}
@@ -133,7 +175,7 @@ func evalExpression(node ast.Expression, env *object.Environment) object.Value {
 		return evalAssignment(exp.Name, val, env)
 
 	case *ast.CompoundAssignExpression:
-		val := Eval(exp.Value, env)
+		val := eval(exp.Value, env)
 		if isError(val) {
 			return val
 		}
@@ -147,12 +189,12 @@ func evalExpression(node ast.Expression, env *object.Environment) object.Value {
 		return evalCallExpression(exp, env)
 
 	case *ast.IndexExpression:
-		left := Eval(exp.Left, env)
+		left := eval(exp.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		index := Eval(exp.Index, env)
+		index := eval(exp.Index, env)
 		if isError(index) {
 			return index
 		}
@@ -183,7 +225,12 @@ func evalExpression(node ast.Expression, env *object.Environment) object.Value {
 	case *ast.FunctionLiteral:
 		params := exp.Parameters
 		body := exp.Body
-		return object.NewFunction(params, body, env)
+		fn := object.NewFunction(params, body, env)
+		fn.AsFunction().IsAsync = exp.IsAsync
+		return fn
+
+	case *ast.AwaitExpression:
+		return evalAwaitExpression(exp, env)
 
 	case *ast.HashLiteral:
 		return evalHashLiteral(exp, env)
@@ -205,7 +252,7 @@ func evalExpressions(
 	result := make([]object.Value, 0, len(exps))
 
 	for _, e := range exps {
-		evaluated := Eval(e, env)
+		evaluated := eval(e, env)
 
 		if isError(evaluated) {
 			return []object.Value{evaluated}
@@ -243,12 +290,12 @@ func nativeBoolToBooleanObject(input bool) object.Value {
 }
 
 func evalRangeExpression(node *ast.RangeExpression, env *object.Environment) object.Value {
-	start := Eval(node.Start, env)
+	start := eval(node.Start, env)
 	if isError(start) {
 		return start
 	}
 
-	end := Eval(node.End, env)
+	end := eval(node.End, env)
 	if isError(end) {
 		return end
 	}
@@ -269,7 +316,7 @@ func evalRangeExpression(node *ast.RangeExpression, env *object.Environment) obj
 	stepVal := int64(1)
 
 	if node.Step != nil {
-		step := Eval(node.Step, env)
+		step := eval(node.Step, env)
 		if isError(step) {
 			return step
 		}
@@ -290,7 +337,7 @@ func evalDeclarationExpression(exp *ast.DeclarationExpression, env *object.Envir
 		return errors.NewVariableReassignmentError(exp.Token.Line, exp.Token.Col, exp.Name.String())
 	}
 
-	val := Eval(exp.Value, env)
+	val := eval(exp.Value, env)
 	if isError(val) {
 		return val
 	}
@@ -309,7 +356,7 @@ func evalCallExpression(exp *ast.CallExpression, env *object.Environment) object
 	}
 
 	// Regular function call
-	function := Eval(exp.Function, env)
+	function := eval(exp.Function, env)
 	if isError(function) {
 		return function
 	}
@@ -317,5 +364,5 @@ func evalCallExpression(exp *ast.CallExpression, env *object.Environment) object
 	if len(args) == 1 && isError(args[0]) {
 		return args[0]
 	}
-	return applyFunction(function, args, exp.Token.Line, exp.Token.Col)
+	return applyFunction(function, args, env, exp.Token.Line, exp.Token.Col)
 }
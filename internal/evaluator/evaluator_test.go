@@ -1,12 +1,22 @@
 package evaluator_test
 
 import (
+	"context"
+	"fmt"
 	"github.com/radeqq007/sunbird/internal/evaluator"
 	"github.com/radeqq007/sunbird/internal/lexer"
 	"github.com/radeqq007/sunbird/internal/object"
 	"github.com/radeqq007/sunbird/internal/parser"
+	"github.com/radeqq007/sunbird/internal/pkg"
+	"github.com/radeqq007/sunbird/internal/sandbox"
+	"github.com/radeqq007/sunbird/internal/vfs"
+	"io"
 	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -65,7 +75,7 @@ func testEval(input string) object.Value {
 	program := p.ParseProgram()
 	env := object.NewEnvironment()
 
-	return evaluator.Eval(program, env)
+	return evaluator.Eval(context.Background(), program, env)
 }
 
 func testIntegerObject(t *testing.T, obj object.Value, expected int64) {
@@ -511,7 +521,7 @@ a + true;
 		p := parser.New(l)
 		program := p.ParseProgram()
 		env := object.NewEnvironment()
-		evaluated := evaluator.Eval(program, env)
+		evaluated := evaluator.Eval(context.Background(), program, env)
 
 		if !evaluated.IsError() {
 			t.Errorf("no error object returned for input: %q. got=%T(%+v)",
@@ -624,6 +634,713 @@ func benchmarkEval(b *testing.B, input string) {
 		p := parser.New(l)
 		program := p.ParseProgram()
 		env := object.NewEnvironment()
-		evaluator.Eval(program, env)
+		evaluator.Eval(context.Background(), program, env)
+	}
+}
+
+func TestAsyncAwait(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"f :: async fn(x) { x * 2 }; await f(21)", 42},
+		{"f :: async fn(x) { return x + 1 }; a := f(1); b := f(2); await a + await b", 5},
+		{"await 5", 5},
+		{"f :: async fn() { 1 }; type(f())", "Promise"},
+		{
+			`f :: async fn() { error("boom") }
+			 p := f()
+			 try { await p } catch e { 7 }`,
+			7,
+		},
+		{
+			`import "promise"
+			 f :: async fn(x) { x }
+			 r := await promise.all([f(1), f(2), 3])
+			 r[0] + r[1] + r[2]`,
+			6,
+		},
+		{
+			`import "promise"
+			 import "time"
+			 slow :: async fn() { time.sleep(0.2); 1 }
+			 fast :: async fn() { 2 }
+			 await promise.race([slow(), fast()])`,
+			2,
+		},
+		{
+			`import "promise"
+			 import "time"
+			 slow :: async fn() { time.sleep(0.2); 1 }
+			 try { await promise.timeout(slow(), 0.01) } catch e { 3 }`,
+			3,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if !evaluated.IsString() || evaluated.AsString().Value != expected {
+				t.Errorf("expected %q, got %s", expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestAsyncTasksOverlap(t *testing.T) {
+	input := `
+import "time"
+nap :: async fn() { time.sleep(0.2) }
+a := nap(); b := nap(); c := nap()
+await a; await b; await c
+`
+	start := time.Now()
+	testEval(input)
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("async sleeps did not overlap, took %s", elapsed)
+	}
+}
+
+func TestEvalWaitsForPendingTasks(t *testing.T) {
+	input := `
+import "time"
+done := false
+finish :: async fn() { time.sleep(0.05); done = true }
+finish()
+`
+	env := object.NewEnvironment()
+	l := lexer.New(input)
+	p := parser.New(l)
+	evaluator.Eval(context.Background(), p.ParseProgram(), env)
+
+	done, _ := env.Get("done")
+	testBooleanObject(t, done, true)
+}
+
+func testEvalWithLimits(ctx context.Context, input string, limits object.Limits) object.Value {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	env.Runtime().SetLimits(limits)
+
+	return evaluator.Eval(ctx, program, env)
+}
+
+func TestExecutionLimits(t *testing.T) {
+	deadline := func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), 50*time.Millisecond)
+	}
+	cancelled := func() (context.Context, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx, cancel
+	}
+	background := func() (context.Context, context.CancelFunc) {
+		return context.Background(), func() {}
+	}
+
+	tests := []struct {
+		name            string
+		ctx             func() (context.Context, context.CancelFunc)
+		limits          object.Limits
+		input           string
+		expectedMessage string
+	}{
+		{
+			"deadline stops an endless loop",
+			deadline,
+			object.Limits{},
+			"loop {}",
+			"TimeoutError: execution timed out",
+		},
+		{
+			"cancellation stops an endless loop",
+			cancelled,
+			object.Limits{},
+			"loop {}",
+			"TimeoutError: execution cancelled",
+		},
+		{
+			"deadline interrupts sleep",
+			deadline,
+			object.Limits{},
+			`import "time"; time.sleep(10); loop {}`,
+			"TimeoutError: execution timed out",
+		},
+		{
+			"step budget",
+			background,
+			object.Limits{MaxSteps: 100},
+			"loop {}",
+			"StepLimitError: exceeded the budget of 100 steps",
+		},
+		{
+			"step budget cannot be caught",
+			background,
+			object.Limits{MaxSteps: 100},
+			"try { loop {} } catch e { 1 }",
+			"StepLimitError: exceeded the budget of 100 steps",
+		},
+		{
+			"default call depth",
+			background,
+			object.Limits{},
+			"f := fn(n) { return 1 + f(n + 1) }; f(0)",
+			"RecursionError: maximum call depth of 10000 exceeded",
+		},
+		{
+			"configured call depth",
+			background,
+			object.Limits{MaxDepth: 10},
+			"f := fn(n) { if n > 0 { return 1 + f(n - 1) }; return 0 }; f(10)",
+			"RecursionError: maximum call depth of 10 exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			evaluated := testEvalWithLimits(ctx, tt.input, tt.limits)
+			if !evaluated.IsError() {
+				t.Fatalf("no error object returned. got=%s", evaluated.Inspect())
+			}
+
+			if msg := evaluated.AsError().Message; msg != tt.expectedMessage {
+				t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, msg)
+			}
+		})
+	}
+}
+
+func TestCallDepthWithinLimit(t *testing.T) {
+	input := "f := fn(n) { if n > 0 { return 1 + f(n - 1) }; return n }; f(9)"
+	evaluated := testEvalWithLimits(context.Background(), input, object.Limits{MaxDepth: 10})
+	testIntegerObject(t, evaluated, 9)
+}
+
+func TestSandboxPolicy(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed.txt")
+	if err := os.WriteFile(allowed, []byte("ok"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+
+	policy := sandbox.New()
+	policy.DenyAll()
+	if err := policy.AllowRead(dir); err != nil {
+		t.Fatal(err)
+	}
+	policy.DenyModule("random")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{fmt.Sprintf(`import "fs"; fs.read(%q)`, allowed), "ok"},
+		{
+			fmt.Sprintf(`import "fs"; fs.read(%q)`, secret),
+			fmt.Sprintf("PermissionError: reading %s is not allowed by the sandbox policy", secret),
+		},
+		{
+			fmt.Sprintf(`import "fs"; fs.write(%q, "x")`, allowed),
+			fmt.Sprintf("PermissionError: writing %s is not allowed by the sandbox policy", allowed),
+		},
+		{
+			`import "random"`,
+			`PermissionError: importing module "random" is not allowed by the sandbox policy`,
+		},
+		{
+			`import "http"; http.create_server().listen(0)`,
+			"PermissionError: network access is not allowed by the sandbox policy",
+		},
+		{
+			"exit()",
+			"PermissionError: exiting the process is not allowed by the sandbox policy",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		env := object.NewEnvironment()
+		env.Runtime().SetPolicy(policy)
+
+		evaluated := evaluator.Eval(context.Background(), p.ParseProgram(), env)
+		if evaluated.IsError() {
+			if msg := evaluated.AsError().Message; msg != tt.expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, msg)
+			}
+			continue
+		}
+
+		if !evaluated.IsString() || evaluated.AsString().Value != tt.expected {
+			t.Errorf("expected %q, got %s", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{
+			`countdown := fn(n) { if n == 0 { return "done" }; return countdown(n - 1) }
+			countdown(1000000)`,
+			"done",
+		},
+		{
+			`is_even := fn(n) { if n == 0 { return true }; return is_odd(n - 1) }
+			is_odd := fn(n) { if n == 0 { return false }; return is_even(n - 1) }
+			is_even(100001)`,
+			false,
+		},
+		{
+			`sum := fn(n, acc) { loop { if n == 0 { return acc }; return sum(n - 1, acc + n) } }
+			sum(100000, 0)`,
+			int64(5000050000),
+		},
+		{
+			`fail := fn() { return 1 + true }
+			f := fn() { try { return fail() } catch e { return "caught" } }
+			f()`,
+			"caught",
+		},
+		{
+			`double := fn(x) { x * 2 }
+			return double(21)`,
+			int64(42),
+		},
+		{
+			`f := fn(s) { return len(s) }
+			f("four")`,
+			int64(4),
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int64:
+			testIntegerObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if !evaluated.IsString() || evaluated.AsString().Value != expected {
+				t.Errorf("expected %q, got %s", expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestTailCallErrors(t *testing.T) {
+	input := `f := fn(n) { if n == 0 { return g() }; return f(n - 1) }
+f(3)`
+
+	evaluated := testEval(input)
+	if !evaluated.IsError() {
+		t.Fatalf("no error object returned. got=%s", evaluated.Inspect())
+	}
+
+	expected := "UndefinedVariableError: g"
+	if msg := evaluated.AsError().Message; msg != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, msg)
+	}
+}
+
+func TestDeferStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{
+			`import "array"
+			log := []
+			f := fn() { defer array.push(log, 1); defer array.push(log, 2); array.push(log, 3) }
+			f()
+			log`,
+			[]int64{3, 2, 1},
+		},
+		{
+			`import "array"
+			log := []
+			f := fn() { defer array.push(log, 1); return array.push(log, 2) }
+			f()
+			log`,
+			[]int64{2, 1},
+		},
+		{
+			`import "array"
+			log := []
+			f := fn() { defer array.push(log, 1); 1 + true }
+			try { f() } catch e { array.push(log, 2) }
+			log`,
+			[]int64{1, 2},
+		},
+		{
+			`import "array"
+			log := []
+			f := fn(n) { for i in [1, 2] { defer array.push(log, i) }; return n }
+			f(5)
+			log`,
+			[]int64{2, 2},
+		},
+		{
+			`import "array"
+			log := []
+			f := fn() { x := 1; defer array.push(log, x); x = 2 }
+			f()
+			log`,
+			[]int64{2},
+		},
+		{
+			`import "array"
+			log := []
+			pusher := fn(v) { return fn() { array.push(log, v) } }
+			f := fn() { for i in [1, 2, 3] { push := pusher(i); defer push() } }
+			f()
+			log`,
+			[]int64{3, 2, 1},
+		},
+		{
+			`f := fn() { defer { return 2 }; return 1 }
+			f()`,
+			int64(2),
+		},
+		{
+			`f := fn() { defer { return "recovered" }; 1 + true }
+			f()`,
+			"recovered",
+		},
+		{
+			`f := fn() { x := 1; defer { x = 2 }; return x }
+			f()`,
+			int64(1),
+		},
+		{
+			`import "array"
+			log := []
+			f := fn() { defer { defer array.push(log, 2); array.push(log, 1) } }
+			f()
+			log`,
+			[]int64{1, 2},
+		},
+		{
+			`import "array"
+			log := []
+			g := fn(n) { return n }
+			f := fn() { defer array.push(log, "deferred"); return g(array.push(log, "call")) }
+			f()
+			log`,
+			[]string{"call", "deferred"},
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int64:
+			testIntegerObject(t, evaluated, expected)
+		case string:
+			if !evaluated.IsString() || evaluated.AsString().Value != expected {
+				t.Errorf("expected %q, got %s", expected, evaluated.Inspect())
+			}
+		case []int64:
+			if !evaluated.IsArray() || len(evaluated.AsArray().Elements) != len(expected) {
+				t.Errorf("expected %v, got %s", expected, evaluated.Inspect())
+				continue
+			}
+			for i, el := range evaluated.AsArray().Elements {
+				testIntegerObject(t, el, expected[i])
+			}
+		case []string:
+			if !evaluated.IsArray() || len(evaluated.AsArray().Elements) != len(expected) {
+				t.Errorf("expected %v, got %s", expected, evaluated.Inspect())
+				continue
+			}
+			for i, el := range evaluated.AsArray().Elements {
+				if !el.IsString() || el.AsString().Value != expected[i] {
+					t.Errorf("expected %q, got %s", expected[i], el.Inspect())
+				}
+			}
+		}
+	}
+}
+
+func TestDeferErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{
+			"defer 1",
+			"RuntimeError: defer used outside of a function",
+		},
+		{
+			"f := fn() { defer 1 + true; return 1 }; f()",
+			"TypeMismatchError: Integer + Boolean",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !evaluated.IsError() {
+			t.Errorf("no error object returned. got=%s", evaluated.Inspect())
+			continue
+		}
+
+		if msg := evaluated.AsError().Message; msg != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, msg)
+		}
+	}
+}
+
+// writeFiles creates files under dir, keyed by their slash-separated relative paths
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testEvalFile(t *testing.T, path string) object.Value {
+	t.Helper()
+
+	src, err := vfs.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	env := object.NewEnvironment()
+	env.SetFile(path)
+
+	return evaluator.Eval(context.Background(), p.ParseProgram(), env)
+}
+
+func TestRelativeImports(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.sb":          `import "./lib/a"; a.describe()`,
+		"lib/a.sb":         `import "fs"; import "./b"; import "../shared/c"; export describe :: fn() { b.name + c.name + fs.read("data.txt") }`,
+		"lib/b.sb":         `export name :: "b"`,
+		"lib/data.txt":     "!",
+		"shared/c.sb":      `export name :: "c"`,
+		"where.sb":         `import "./lib/where" as w; [__file__, __dir__, w.file]`,
+		"lib/where.sb":     `export file :: __file__`,
+		"missing.sb":       `import "./lib/nope"`,
+		"data.txt":         "wrong file",
+		"nested/deep/x.sb": `import "../../lib/b"; b.name`,
+	})
+
+	evaluated := testEvalFile(t, filepath.Join(dir, "main.sb"))
+	if !evaluated.IsString() || evaluated.AsString().Value != "bc!" {
+		t.Errorf("expected \"bc!\", got %s", evaluated.Inspect())
+	}
+
+	evaluated = testEvalFile(t, filepath.Join(dir, "nested", "deep", "x.sb"))
+	if !evaluated.IsString() || evaluated.AsString().Value != "b" {
+		t.Errorf("expected \"b\", got %s", evaluated.Inspect())
+	}
+
+	evaluated = testEvalFile(t, filepath.Join(dir, "where.sb"))
+	expected := fmt.Sprintf("[%q, %q, %q]",
+		filepath.Join(dir, "where.sb"), dir, filepath.Join(dir, "lib", "where.sb"))
+	if evaluated.Inspect() != expected {
+		t.Errorf("expected %s, got %s", expected, evaluated.Inspect())
+	}
+
+	evaluated = testEvalFile(t, filepath.Join(dir, "missing.sb"))
+	if !evaluated.IsError() || evaluated.AsError().Message != "ImportError: module not found: "+filepath.Join(dir, "lib", "nope") {
+		t.Errorf("expected a module not found error, got %s", evaluated.Inspect())
+	}
+}
+
+func TestCircularImports(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"direct.sb":    `import "./direct_a"`,
+		"direct_a.sb":  `import "./direct_b"; export x :: 1`,
+		"direct_b.sb":  `import "./direct_a"; export y :: 2`,
+		"indirect.sb":  `import "./lib/a"`,
+		"lib/a.sb":     `import "./b"`,
+		"lib/b.sb":     `import "../c"`,
+		"c.sb":         `import "./lib/a"`,
+		"self.sb":      `import "./self_a"`,
+		"self_a.sb":    `import "./self_a"`,
+		"main.sb":      `import "./main_a"`,
+		"main_a.sb":    `import "./main"`,
+		"diamond.sb":   `import "./diamond_a"; import "./diamond_b"; diamond_a.x + diamond_b.x`,
+		"diamond_a.sb": `import "./diamond_c"; export x :: diamond_c.x`,
+		"diamond_b.sb": `import "./diamond_c"; export x :: diamond_c.x * 2`,
+		"diamond_c.sb": `export x :: 1`,
+	})
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"direct.sb", "ImportError: circular import: direct.sb -> direct_a.sb -> direct_b.sb -> direct_a.sb"},
+		{"indirect.sb", "ImportError: circular import: indirect.sb -> lib/a.sb -> lib/b.sb -> c.sb -> lib/a.sb"},
+		{"self.sb", "ImportError: circular import: self.sb -> self_a.sb -> self_a.sb"},
+		{"main.sb", "ImportError: circular import: main.sb -> main_a.sb -> main.sb"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalFile(t, filepath.Join(dir, tt.file))
+		if !evaluated.IsError() {
+			t.Errorf("%s: expected an error, got %s", tt.file, evaluated.Inspect())
+			continue
+		}
+
+		if msg := evaluated.AsError().Message; msg != tt.expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.file, tt.expected, msg)
+		}
+	}
+
+	// Importing the same module along different paths isn't a cycle
+	testIntegerObject(t, testEvalFile(t, filepath.Join(dir, "diamond.sb")), 3)
+}
+
+func TestSelectiveImports(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"utils.sb":       `a := 1; b := 2; hidden := 3; export { a, b as bee }`,
+		"strings.sb":     `export shout :: fn(s) { s + "!" }`,
+		"index.sb":       `export * from "./utils"; export * from "./strings"; export a :: 10`,
+		"members.sb":     `import { a, bee as b } from "./utils"; a + b`,
+		"wildcard.sb":    `import * from "./index"; [a, bee, shout("hi")]`,
+		"builtin.sb":     `import { sqrt, pow as power } from "math"; sqrt(power(3, 2))`,
+		"all_builtin.sb": `import * from "math"; abs(-2)`,
+		"missing.sb":     `import { a, hidden } from "./utils"`,
+		"undefined.sb":   `export { nope }`,
+		"reexport.sb":    `export * from "./nope"`,
+		"aliases.sb":     `a := 1; export { a, a as b, a as c }`,
+		"use_aliases.sb": `import { a, b, c } from "./aliases"; [a, b, c]`,
+		"taken.sb":       `a := 1; b := 2; export { a as x, b as x }`,
+	})
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"members.sb", "3"},
+		{"wildcard.sb", `[10, 2, "hi!"]`},
+		{"builtin.sb", "3"},
+		{"all_builtin.sb", "2"},
+		{"missing.sb", `ImportError: module "./utils" has no export "hidden" (at line 1, col 13)`},
+		{"undefined.sb", "UndefinedVariableError: nope (at line 1, col 10)"},
+		{"reexport.sb", "ImportError: module not found: " + filepath.Join(dir, "nope") + " (at line 1, col 1)"},
+		{"use_aliases.sb", "[1, 1, 1]"},
+		{"taken.sb", "RuntimeError: x is already exported (at line 1, col 34)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalFile(t, filepath.Join(dir, tt.file))
+		if got := evaluated.Inspect(); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.file, tt.expected, got)
+		}
+	}
+}
+
+func TestPackageImports(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "workspace")
+	writeFiles(t, dir, map[string]string{
+		// A workspace whose members import each other by name
+		"sunbird.toml":                       "[workspace]\nmembers = [\"packages/*\"]\n",
+		"packages/app/sunbird.toml":          "[package]\nname = \"app\"\nmain = \"main.sb\"\n\n[[package.dependencies]]\npath = \"../../../shared\"\n",
+		"packages/app/main.sb":               `import "core"; import "shared"; import "installed"; core.name + shared.name + installed.name`,
+		"packages/core/sunbird.toml":         "[package]\nname = \"core\"\nmain = \"lib/core.sb\"\n",
+		"packages/core/lib/core.sb":          `export name :: "core"`,
+		".sb_modules/installed/sunbird.toml": "[package]\nname = \"installed\"\nmain = \"main.sb\"\n",
+		".sb_modules/installed/main.sb":      `export name :: "+installed"`,
+	})
+
+	// A path dependency outside the workspace
+	shared := filepath.Join(base, "shared")
+	writeFiles(t, shared, map[string]string{
+		"sunbird.toml": "[package]\nname = \"shared\"\nmain = \"main.sb\"\n",
+		"main.sb":      `export name :: "+shared"`,
+	})
+
+	evaluated := testEvalFile(t, filepath.Join(dir, "packages", "app", "main.sb"))
+	if !evaluated.IsString() || evaluated.AsString().Value != "core+shared+installed" {
+		t.Errorf("expected \"core+shared+installed\", got %s", evaluated.Inspect())
+	}
+
+	// Path dependencies are used live, so changes show up without reinstalling
+	writeFiles(t, shared, map[string]string{"main.sb": `export name :: "+changed"`})
+	evaluated = testEvalFile(t, filepath.Join(dir, "packages", "app", "main.sb"))
+	if !evaluated.IsString() || evaluated.AsString().Value != "core+changed+installed" {
+		t.Errorf("expected \"core+changed+installed\", got %s", evaluated.Inspect())
+	}
+
+	// Packages outside the workspace can't see its members
+	writeFiles(t, shared, map[string]string{"other.sb": `import "core"`})
+	evaluated = testEvalFile(t, filepath.Join(shared, "other.sb"))
+	if !evaluated.IsError() || !strings.Contains(evaluated.AsError().Message, "module not found") {
+		t.Errorf("expected a module not found error, got %s", evaluated.Inspect())
+	}
+}
+
+func TestBundledModules(t *testing.T) {
+	base := t.TempDir()
+	project := filepath.Join(base, "app")
+	writeFiles(t, project, map[string]string{
+		"sunbird.toml":                       "[package]\nname = \"app\"\nmain = \"src/main.sb\"\n",
+		"src/main.sb":                        `import "fs"; import "./util"; import "installed"; util.name + installed.name + fs.read("../data.txt") + len(fs.list_dir("."))`,
+		"src/util.sb":                        `export name :: "util"`,
+		"data.txt":                           "+data",
+		".sb_modules/installed/sunbird.toml": "[package]\nname = \"installed\"\nmain = \"main.sb\"\n",
+		".sb_modules/installed/main.sb":      `export name :: "+installed"`,
+	})
+
+	runtime := filepath.Join(base, "sunbird")
+	if err := os.WriteFile(runtime, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := &pkg.PackageManager{Dir: project, Out: io.Discard, Sunbird: runtime}
+	out, err := pm.Build("", filepath.Join(base, "app.bin"), []string{"data.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := pkg.OpenBundle(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bundle.Close()
+
+	// The bundle is all there is where it's mounted
+	dir := t.TempDir()
+	if err := vfs.Mount(dir, bundle.Files); err != nil {
+		t.Fatal(err)
+	}
+	defer vfs.Unmount()
+
+	evaluated := testEvalFile(t, filepath.Join(dir, filepath.FromSlash(bundle.Entry)))
+	if !evaluated.IsString() || evaluated.AsString().Value != "util+installed+data2" {
+		t.Errorf("expected \"util+installed+data2\", got %s", evaluated.Inspect())
 	}
 }
@@ -1,14 +1,50 @@
 package evaluator
 
 import (
+	"github.com/radeqq007/sunbird/internal/ast"
 	"github.com/radeqq007/sunbird/internal/errors"
 	"github.com/radeqq007/sunbird/internal/object"
 )
 
-func applyFunction(fn object.Value, args []object.Value, line, col int) object.Value {
+func applyFunction(
+	fn object.Value,
+	args []object.Value,
+	env *object.Environment,
+	line, col int,
+) object.Value {
 	switch fn.Kind() {
 	case object.FunctionKind:
-		fn := fn.AsFunction()
+		return callFunction(fn.AsFunction(), args, env, line, col)
+
+	case object.BuiltinKind:
+		ctx := object.CallContext{Line: line, Col: col, Env: env}
+		return fn.AsBuiltin().Fn(ctx, args...)
+
+	default:
+		return errors.NewNotCallableError(line, col, fn)
+	}
+}
+
+// callFunction runs the body of fn. Calls the body makes in tail position
+// are carried out by the loop here rather than by nesting another call,
+// so tail recursion doesn't grow the Go stack or the call depth.
+func callFunction(
+	fn *object.Function,
+	args []object.Value,
+	env *object.Environment,
+	line, col int,
+) object.Value {
+	depth := 1
+	if env != nil {
+		depth = env.Depth() + 1
+	}
+
+	maxDepth := fn.Env.Runtime().Limits().MaxDepth
+	if depth > maxDepth {
+		return errors.NewRecursionError(line, col, maxDepth)
+	}
+
+	for {
 		err := errors.ExpectNumberOfArguments(line, col, len(fn.Parameters), args)
 		if err.IsError() {
 			return err
@@ -18,26 +54,85 @@ func applyFunction(fn object.Value, args []object.Value, line, col int) object.V
 		if err.IsError() {
 			return err
 		}
+		extendedEnv.SetDepth(depth)
+		extendedEnv.MarkAsFunctionScope()
+
+		if fn.IsAsync {
+			return startAsyncCall(fn, extendedEnv)
+		}
 
 		// set 'this' binding
 		if thisVal, ok := extendedEnv.Get("this"); ok {
 			extendedEnv.Set("this", thisVal)
 		}
 
-		evaluated := Eval(fn.Body, extendedEnv)
+		evaluated := runDeferred(extendedEnv, eval(fn.Body, extendedEnv))
 
 		if isError(evaluated) {
 			return evaluated
 		}
 
-		return unwrapReturnValue(evaluated)
+		tail := tailCallOf(evaluated)
+		if tail == nil {
+			return unwrapReturnValue(evaluated)
+		}
 
-	case object.BuiltinKind:
-		return fn.AsBuiltin().Fn(object.NewCallContext(line, col), args...)
+		if !tail.Fn.IsFunction() {
+			return applyFunction(tail.Fn, tail.Args, tail.Env, tail.Line, tail.Col)
+		}
 
-	default:
-		return errors.NewNotCallableError(line, col, fn)
+		fn, args, line, col = tail.Fn.AsFunction(), tail.Args, tail.Line, tail.Col
+	}
+}
+
+// evalTailCall evaluates the callee and arguments of `return f(x)`, leaving
+// the call itself to callFunction once the current frame has been left.
+func evalTailCall(exp *ast.CallExpression, env *object.Environment) object.Value {
+	// Method calls need their receiver bound, so they're made straight away
+	if _, ok := exp.Function.(*ast.PropertyExpression); ok {
+		return newReturnValue(evalMethodCallExpression(exp, env))
+	}
+
+	function := eval(exp.Function, env)
+	if isError(function) {
+		return function
+	}
+
+	args := evalExpressions(exp.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	if !function.IsFunction() {
+		return newReturnValue(applyFunction(function, args, env, exp.Token.Line, exp.Token.Col))
+	}
+
+	return object.NewTailCall(function, args, env, exp.Token.Line, exp.Token.Col)
+}
+
+// resolveTailCall makes the pending call of a tail call return value,
+// for places that need the actual result, such as the body of a try block.
+func resolveTailCall(val object.Value) object.Value {
+	tail := tailCallOf(val)
+	if tail == nil {
+		return val
+	}
+
+	return newReturnValue(applyFunction(tail.Fn, tail.Args, tail.Env, tail.Line, tail.Col))
+}
+
+func tailCallOf(val object.Value) *object.TailCall {
+	if val.Kind() != object.ReturnValueKind {
+		return nil
+	}
+	return val.AsReturnValue().Tail
+}
+
+func newReturnValue(val object.Value) object.Value {
+	if isError(val) {
+		return val
 	}
+	return object.NewReturnValue(val)
 }
 
 func extendFunctionEnv(
@@ -62,7 +157,7 @@ func unwrapReturnValue(obj object.Value) object.Value {
 }
 
 func init() {
-	object.ApplyFunction = func(fn object.Value, args []object.Value) object.Value {
-		return applyFunction(fn, args, 0, 0)
+	object.ApplyFunction = func(ctx object.CallContext, fn object.Value, args []object.Value) object.Value {
+		return applyFunction(fn, args, ctx.Env, ctx.Line, ctx.Col)
 	}
 }
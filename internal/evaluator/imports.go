@@ -1,46 +1,103 @@
 package evaluator
 
 import (
+	goerrors "errors"
+	"fmt"
 	"path/filepath"
 	"strings"
 
 	"github.com/radeqq007/sunbird/internal/ast"
 	"github.com/radeqq007/sunbird/internal/errors"
 	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/sandbox"
+	"github.com/radeqq007/sunbird/internal/token"
 )
 
-var moduleCache = NewModuleCache()
-
 func evalImportStatement(stmt *ast.ImportStatement, env *object.Environment) object.Value {
 	path := stmt.Path.Value
 
-	module, err := moduleCache.loadModule(path)
-	if err != nil {
-		return errors.NewImportError(stmt.Token.Line, stmt.Token.Col, err.Error())
+	module := importModule(path, stmt.Token, env)
+	if isError(module) {
+		return module
 	}
+	exports := module.AsModule().Exports
 
-	// Determine the name to bind to
-	name := path
-	if stmt.Alias != nil {
-		name = stmt.Alias.Value
-	} else {
-		// If it's a file path, extract filename without extension
-		base := filepath.Base(path)
-		ext := filepath.Ext(base)
-
-		if ext != "" {
-			name = strings.TrimSuffix(base, ext)
+	switch {
+	case stmt.Wildcard:
+		for name, val := range exports {
+			env.SetConst(name, val)
 		}
-	}
 
-	// Bind module to environment
-	env.SetConst(name, module)
+	case stmt.Members != nil:
+		for _, member := range stmt.Members {
+			val, ok := exports[member.Name.Value]
+			if !ok {
+				return errors.NewImportError(member.Name.Token.Line, member.Name.Token.Col,
+					fmt.Sprintf("module %q has no export %q", path, member.Name.Value))
+			}
+			env.SetConst(member.BoundName(), val)
+		}
+
+	default:
+		// Determine the name to bind to
+		name := path
+		if stmt.Alias != nil {
+			name = stmt.Alias.Value
+		} else {
+			// If it's a file path, extract filename without extension
+			base := filepath.Base(path)
+			name = strings.TrimSuffix(base, filepath.Ext(base))
+		}
+
+		// Bind module to environment
+		env.SetConst(name, module)
+	}
 
 	return NULL
 }
 
+// importModule loads the module imported as path by the statement starting with tok
+func importModule(path string, tok token.Token, env *object.Environment) object.Value {
+	module, err := loadModule(path, env)
+	if err != nil {
+		var denied *sandbox.Error
+		if goerrors.As(err, &denied) {
+			return errors.NewPermissionError(tok.Line, tok.Col, err)
+		}
+		return errors.NewImportError(tok.Line, tok.Col, err.Error())
+	}
+
+	// Errors raised while evaluating the module, including the ones
+	// of its own imports, are returned as they are
+	return module
+}
+
 func evalExportStatement(stmt *ast.ExportStatement, env *object.Environment) object.Value {
-	val := Eval(stmt.Declaration, env)
+	switch {
+	case stmt.From != nil:
+		module := importModule(stmt.From.Value, stmt.Token, env)
+		if isError(module) {
+			return module
+		}
+
+		for name, val := range module.AsModule().Exports {
+			env.Reexport(name, val)
+		}
+		return NULL
+
+	case stmt.Members != nil:
+		for _, member := range stmt.Members {
+			if _, ok := env.Get(member.Name.Value); !ok {
+				return errors.NewUndefinedVariableError(member.Name.Token.Line, member.Name.Token.Col, member.Name.Value)
+			}
+			if !env.ExportAs(member.Name.Value, member.BoundName()) {
+				return errors.NewRuntimeError(member.Name.Token.Line, member.Name.Token.Col, "%s is already exported", member.BoundName())
+			}
+		}
+		return NULL
+	}
+
+	val := eval(stmt.Declaration, env)
 	if isError(val) {
 		return val
 	}
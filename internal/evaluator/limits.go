@@ -0,0 +1,23 @@
+package evaluator
+
+import (
+	"context"
+	goerrors "errors"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+// newLimitError turns the reason a run was stopped into a Sunbird error
+func newLimitError(env *object.Environment, err error) object.Value {
+	switch {
+	case goerrors.Is(err, object.ErrStepLimit):
+		return errors.NewStepLimitError(0, 0, env.Runtime().Limits().MaxSteps)
+	case goerrors.Is(err, context.DeadlineExceeded):
+		return errors.NewTimeoutError(0, 0, "execution timed out")
+	case goerrors.Is(err, context.Canceled):
+		return errors.NewTimeoutError(0, 0, "execution cancelled")
+	default:
+		return errors.NewRuntimeError(0, 0, "%s", err)
+	}
+}
@@ -3,78 +3,72 @@ package evaluator
 import (
 	"errors"
 	"fmt"
-	"io"
-	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 
 	"github.com/radeqq007/sunbird/internal/lexer"
 	"github.com/radeqq007/sunbird/internal/modules"
 	"github.com/radeqq007/sunbird/internal/object"
 	"github.com/radeqq007/sunbird/internal/parser"
 	"github.com/radeqq007/sunbird/internal/pkg"
+	"github.com/radeqq007/sunbird/internal/vfs"
 )
 
-type ModuleCache struct {
-	modules map[string]object.Value
-	mu      sync.RWMutex
-}
+// loadModule finds the module imported as path by code running in env. Relative
+// paths are resolved from the directory of the importing file. Loaded modules are
+// cached on the runtime, so every file is evaluated at most once per run.
+func loadModule(path string, env *object.Environment) (object.Value, error) {
+	rt := env.Runtime()
 
-func NewModuleCache() *ModuleCache {
-	return &ModuleCache{
-		modules: make(map[string]object.Value),
+	// Modules registered by the host program take precedence over the built-in ones
+	if module, ok := rt.Module(path); ok {
+		return module, rt.Policy().CheckImport(path)
 	}
-}
 
-func (mc *ModuleCache) loadModule(path string) (object.Value, error) {
-	mc.mu.RLock()
-	module, ok := mc.modules[path]
-	mc.mu.RUnlock()
-	if ok {
-		return module, nil
+	// Check if it's a built-in module
+	if builtinModule, ok, err := modules.Import(path, rt.Policy()); ok {
+		return builtinModule, err
 	}
 
-	// Check if it's a built-in module
-	if builtinModule, ok := modules.BuiltinModules[path]; ok {
-		mc.mu.Lock()
-		mc.modules[path] = builtinModule
-		mc.mu.Unlock()
-		return builtinModule, nil
-	}
-
-	// Check in .sb_modules directory
-	modulesDir := ".sb_modules"
-	if _, err := os.Stat(modulesDir); err == nil {
-		if module, err := mc.tryLoadFromModulesDir(path); err == nil {
-			mc.mu.Lock()
-			mc.modules[path] = module
-			mc.mu.Unlock()
-			return module, nil
-		}
+	// Check the packages of the project: path dependencies, workspace members and .sb_modules
+	if packageDir, ok := pkg.FindPackage(env.Dir(), path); ok {
+		return loadPackage(packageDir, rt)
 	}
 
 	// Load from file
-	return mc.loadFileModule(path)
-}
-
-func (mc *ModuleCache) loadFileModule(path string) (object.Value, error) {
-	fullPath, err := mc.resolveModulePath(path)
+	fullPath, err := resolveModulePath(path, env.Dir())
 	if err != nil {
 		return object.NewNull(), err
 	}
 
-	file, err := os.Open(fullPath)
-	if err != nil {
-		return object.NewNull(), err
+	// The file doing the first import is the start of every import chain,
+	// so cycles leading back to it are caught too
+	if !rt.Importing() && env.File() != "" {
+		done, _ := rt.BeginImport(env.File())
+		defer done()
 	}
 
-	content, err := io.ReadAll(file)
-	if err != nil {
+	return loadFileModule(fullPath, rt)
+}
+
+// loadFileModule evaluates the file at fullPath as a module,
+// unless it's been loaded already
+func loadFileModule(fullPath string, rt *object.Runtime) (object.Value, error) {
+	if module, ok := rt.Module(fullPath); ok {
+		return module, nil
+	}
+
+	done, chain := rt.BeginImport(fullPath)
+	defer done()
+	if chain != nil {
+		return object.NewNull(), circularImportError(chain)
+	}
+
+	if err := rt.Policy().CheckRead(fullPath); err != nil {
 		return object.NewNull(), err
 	}
 
-	err = file.Close()
+	content, err := vfs.ReadFile(fullPath)
 	if err != nil {
 		return object.NewNull(), err
 	}
@@ -87,14 +81,15 @@ func (mc *ModuleCache) loadFileModule(path string) (object.Value, error) {
 		return object.NewNull(), fmt.Errorf("parse errors in module: %v", p.Errors())
 	}
 
-	moduleEnv := object.NewEnvironment()
+	moduleEnv := object.NewRuntimeEnvironment(rt)
+	moduleEnv.SetFile(fullPath)
 
-	result := Eval(program, moduleEnv)
+	result := eval(program, moduleEnv)
 	if isError(result) {
 		return result, nil
 	}
 
-	moduleName := filepath.Base(path)
+	moduleName := filepath.Base(fullPath)
 	if ext := filepath.Ext(moduleName); ext != "" {
 		moduleName = moduleName[:len(moduleName)-len(ext)]
 	}
@@ -102,49 +97,51 @@ func (mc *ModuleCache) loadFileModule(path string) (object.Value, error) {
 	exports := moduleEnv.GetExports()
 
 	module := object.NewModule(moduleName, exports)
-	mc.mu.Lock()
-	mc.modules[path] = module
-	mc.mu.Unlock()
+	rt.SetModule(fullPath, module)
 
 	return module, nil
 }
 
-func (mc *ModuleCache) resolveModulePath(path string) (string, error) {
-	mainFileDir := ""
-	if len(os.Args) > 2 {
-		mainFileDir = filepath.Dir(os.Args[2]) // TODO: don't use os.Args
-	}
+// circularImportError describes the chain of imports ending in a cycle,
+// showing the files relative to the directory of the first one
+func circularImportError(chain []string) error {
+	dir := filepath.Dir(chain[0])
 
-	var fullPath string
-	if filepath.IsAbs(path) {
-		fullPath = filepath.Clean(path)
-	} else {
-		joined, err := safeJoin(mainFileDir, path)
-		if err != nil {
-			return "", fmt.Errorf("module not found: %s", path)
-		}
-		fullPath = joined
-
-		if _, err := os.Stat(fullPath); err != nil {
-			withExt := fullPath + ".sb"
-			if _, err2 := os.Stat(withExt); err2 != nil {
-				return "", fmt.Errorf("module not found: %s", path)
-			}
-			fullPath = withExt
+	names := make([]string, len(chain))
+	for i, path := range chain {
+		names[i] = path
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			names[i] = filepath.ToSlash(rel)
 		}
 	}
-	return fullPath, nil
+
+	return fmt.Errorf("circular import: %s", strings.Join(names, " -> "))
 }
 
-func (mc *ModuleCache) tryLoadFromModulesDir(path string) (object.Value, error) {
-	modulesDir := ".sb_modules"
-	packagePath := filepath.Join(modulesDir, path)
+// resolveModulePath finds the file imported as path from dir,
+// trying path with the .sb extension added too
+func resolveModulePath(path, dir string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
 
-	if _, err := os.Stat(packagePath); err != nil {
-		return object.NewNull(), err
+	fullPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("module not found: %s", path)
 	}
 
-	moduleConf, err := pkg.LoadConfig(filepath.Join(packagePath, "sunbird.toml"))
+	for _, candidate := range []string{fullPath, fullPath + ".sb"} {
+		if info, err := vfs.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("module not found: %s", path)
+}
+
+// loadPackage loads the main file of the package in packageDir
+func loadPackage(packageDir string, rt *object.Runtime) (object.Value, error) {
+	moduleConf, err := pkg.LoadConfig(filepath.Join(packageDir, "sunbird.toml"))
 	if err != nil {
 		return object.NewNull(), errors.New("failed to load module config: " + err.Error())
 	}
@@ -153,19 +150,5 @@ func (mc *ModuleCache) tryLoadFromModulesDir(path string) (object.Value, error)
 		return object.NewNull(), errors.New("module config missing main file")
 	}
 
-	return mc.loadFileModule(filepath.Join(packagePath, moduleConf.Package.Main))
-}
-
-// safeJoin joins base and rel and verifies the result stays within base.
-func safeJoin(base, rel string) (string, error) {
-    absBase, err := filepath.Abs(base)
-    if err != nil {
-        return "", fmt.Errorf("cannot resolve base directory: %w", err)
-    }
-    full := filepath.Clean(filepath.Join(absBase, rel))
-    if !strings.HasPrefix(full, absBase+string(os.PathSeparator)) && full != absBase {
-        return "", fmt.Errorf("path %q escapes base directory", rel)
-    }
-    return full, nil
+	return loadFileModule(filepath.Join(packageDir, moduleConf.Package.Main), rt)
 }
-
@@ -31,12 +31,16 @@ var keywords = map[string]token.TokenType{
 	"import":   token.Import,
 	"export":   token.Export,
 	"as":       token.As,
+	"from":     token.From,
 	"break":    token.Break,
 	"continue": token.Continue,
 	"try":      token.Try,
 	"catch":    token.Catch,
 	"finally":  token.Finally,
 	"in":       token.In,
+	"async":    token.Async,
+	"await":    token.Await,
+	"defer":    token.Defer,
 }
 
 func New(input string) *Lexer {
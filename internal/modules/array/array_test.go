@@ -1,6 +1,7 @@
 package array_test
 
 import (
+	"context"
 	"github.com/radeqq007/sunbird/internal/evaluator"
 	"github.com/radeqq007/sunbird/internal/lexer"
 	"github.com/radeqq007/sunbird/internal/object"
@@ -248,5 +249,5 @@ func testEval(input string) object.Value {
 	program := p.ParseProgram()
 	env := object.NewEnvironment()
 
-	return evaluator.Eval(program, env)
+	return evaluator.Eval(context.Background(), program, env)
 }
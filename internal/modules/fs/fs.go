@@ -4,6 +4,7 @@ import (
 	"github.com/radeqq007/sunbird/internal/errors"
 	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
 	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/vfs"
 	"os"
 	"path/filepath"
 )
@@ -23,18 +24,31 @@ func New() object.Value {
 		Build()
 }
 
-func getFullPath(requestedPath string) string {
-	var fullPath string
-	if filepath.IsAbs(requestedPath) {
-		fullPath = requestedPath
-	} else {
-		mainFileDir := ""
-		if len(os.Args) > 1 {
-			mainFileDir = filepath.Dir(os.Args[1])
-		}
-		fullPath = filepath.Join(mainFileDir, requestedPath)
+// getFullPath resolves relative paths from the directory of the calling file
+func getFullPath(ctx object.CallContext, requestedPath string) string {
+	if filepath.IsAbs(requestedPath) || ctx.Env == nil {
+		return requestedPath
+	}
+	return filepath.Join(ctx.Env.Dir(), requestedPath)
+}
+
+// readablePath resolves the path a script asked for, making sure
+// the sandbox policy lets it read from there
+func readablePath(ctx object.CallContext, requestedPath object.Value) (string, object.Value) {
+	fullPath := getFullPath(ctx, requestedPath.AsString().Value)
+	if err := ctx.Runtime().Policy().CheckRead(fullPath); err != nil {
+		return "", errors.NewPermissionError(ctx.Line, ctx.Col, err)
 	}
-	return fullPath
+	return fullPath, object.NewNull()
+}
+
+// writablePath is like readablePath, but for creating, changing or removing files
+func writablePath(ctx object.CallContext, requestedPath object.Value) (string, object.Value) {
+	fullPath := getFullPath(ctx, requestedPath.AsString().Value)
+	if err := ctx.Runtime().Policy().CheckWrite(fullPath); err != nil {
+		return "", errors.NewPermissionError(ctx.Line, ctx.Col, err)
+	}
+	return fullPath, object.NewNull()
 }
 
 func readFile(ctx object.CallContext, args ...object.Value) object.Value {
@@ -48,7 +62,16 @@ func readFile(ctx object.CallContext, args ...object.Value) object.Value {
 		return err
 	}
 
-	data, errGo := os.ReadFile(getFullPath(args[0].AsString().Value))
+	path, err := readablePath(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
+
+	var data []byte
+	var errGo error
+	ctx.Runtime().Block(func() {
+		data, errGo = vfs.ReadFile(path)
+	})
 	if errGo != nil {
 		return errors.New(errors.RuntimeError, ctx.Line, ctx.Col, "%s", errGo.Error())
 	}
@@ -72,7 +95,15 @@ func writeFile(ctx object.CallContext, args ...object.Value) object.Value {
 		return err
 	}
 
-	errGo := os.WriteFile(getFullPath(args[0].AsString().Value), []byte(args[1].AsString().Value), 0o644)
+	path, err := writablePath(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
+
+	var errGo error
+	ctx.Runtime().Block(func() {
+		errGo = os.WriteFile(path, []byte(args[1].AsString().Value), 0o644)
+	})
 	if errGo != nil {
 		return errors.New(errors.RuntimeError, ctx.Line, ctx.Col, "%s", errGo.Error())
 	}
@@ -96,13 +127,21 @@ func appendFile(ctx object.CallContext, args ...object.Value) object.Value {
 		return err
 	}
 
-	file, errGo := os.OpenFile(getFullPath(args[0].AsString().Value), os.O_APPEND|os.O_WRONLY, 0o644)
-	if errGo != nil {
-		return errors.New(errors.RuntimeError, ctx.Line, ctx.Col, "%s", errGo.Error())
+	path, err := writablePath(ctx, args[0])
+	if err.IsError() {
+		return err
 	}
-	defer file.Close()
 
-	_, errGo = file.WriteString(args[1].AsString().Value)
+	var errGo error
+	ctx.Runtime().Block(func() {
+		var file *os.File
+		if file, errGo = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644); errGo != nil {
+			return
+		}
+		defer file.Close()
+
+		_, errGo = file.WriteString(args[1].AsString().Value)
+	})
 	if errGo != nil {
 		return errors.New(errors.RuntimeError, ctx.Line, ctx.Col, "%s", errGo.Error())
 	}
@@ -121,7 +160,15 @@ func removeFile(ctx object.CallContext, args ...object.Value) object.Value {
 		return err
 	}
 
-	errGo := os.Remove(getFullPath(args[0].AsString().Value))
+	path, err := writablePath(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
+
+	var errGo error
+	ctx.Runtime().Block(func() {
+		errGo = os.Remove(path)
+	})
 	if errGo != nil {
 		return errors.New(errors.RuntimeError, 0, 0, "%s", errGo.Error())
 	}
@@ -140,7 +187,12 @@ func exists(ctx object.CallContext, args ...object.Value) object.Value {
 		return err
 	}
 
-	_, errGo := os.Stat(getFullPath(args[0].AsString().Value))
+	path, err := readablePath(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
+
+	_, errGo := vfs.Stat(path)
 	if errGo != nil {
 		if os.IsNotExist(errGo) {
 			return object.NewBool(false)
@@ -163,7 +215,12 @@ func isDir(ctx object.CallContext, args ...object.Value) object.Value {
 		return err
 	}
 
-	info, errGo := os.Stat(getFullPath(args[0].AsString().Value))
+	path, err := readablePath(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
+
+	info, errGo := vfs.Stat(path)
 	if errGo != nil {
 		return errors.New(errors.RuntimeError, 0, 0, "%s", errGo.Error())
 	}
@@ -182,7 +239,12 @@ func listDir(ctx object.CallContext, args ...object.Value) object.Value {
 		return err
 	}
 
-	entries, errGo := os.ReadDir(getFullPath(args[0].AsString().Value))
+	path, err := readablePath(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
+
+	entries, errGo := vfs.ReadDir(path)
 	if errGo != nil {
 		return errors.New(errors.RuntimeError, 0, 0, "%s", errGo.Error())
 	}
@@ -206,7 +268,15 @@ func createDir(ctx object.CallContext, args ...object.Value) object.Value {
 		return err
 	}
 
-	errGo := os.Mkdir(getFullPath(args[0].AsString().Value), 0o755)
+	path, err := writablePath(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
+
+	var errGo error
+	ctx.Runtime().Block(func() {
+		errGo = os.Mkdir(path, 0o755)
+	})
 	if errGo != nil {
 		return errors.New(errors.RuntimeError, 0, 0, "%s", errGo.Error())
 	}
@@ -229,7 +299,20 @@ func rename(ctx object.CallContext, args ...object.Value) object.Value {
 		return err
 	}
 
-	errGo := os.Rename(getFullPath(args[0].AsString().Value), getFullPath(args[1].AsString().Value))
+	from, err := writablePath(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
+
+	to, err := writablePath(ctx, args[1])
+	if err.IsError() {
+		return err
+	}
+
+	var errGo error
+	ctx.Runtime().Block(func() {
+		errGo = os.Rename(from, to)
+	})
 	if errGo != nil {
 		return errors.New(errors.RuntimeError, 0, 0, "%s", errGo.Error())
 	}
@@ -253,16 +336,27 @@ func copyFile(ctx object.CallContext, args ...object.Value) object.Value {
 		return err
 	}
 
-	str := args[0].AsString().Value
+	src, err := readablePath(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
 
-	data, errGo := os.ReadFile(getFullPath(str))
-	if errGo != nil {
-		return errors.New(errors.RuntimeError, 0, 0, "%s", errGo.Error())
+	dst, err := writablePath(ctx, args[1])
+	if err.IsError() {
+		return err
 	}
 
-	//nolint:gosec // G703: path traversal — sunbird scripts run with user permissions;
-	// restricting cross-directory access would break legitimate use cases.
-	errGo = os.WriteFile(getFullPath(args[1].AsString().Value), data, 0o644)
+	var errGo error
+	ctx.Runtime().Block(func() {
+		var data []byte
+		if data, errGo = vfs.ReadFile(src); errGo != nil {
+			return
+		}
+
+		//nolint:gosec // G703: path traversal — sunbird scripts run with user permissions
+		// unless the sandbox policy restricts them, which writablePath already checked.
+		errGo = os.WriteFile(dst, data, 0o644)
+	})
 	if errGo != nil {
 		return errors.New(errors.RuntimeError, 0, 0, "%s", errGo.Error())
 	}
@@ -0,0 +1,512 @@
+package http
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	gojson "encoding/json"
+	goerrors "errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/modules/json"
+	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
+	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/vfs"
+)
+
+// defaultMaxRedirects is how many redirects fetch follows unless told otherwise
+const defaultMaxRedirects = 10
+
+// Requests without TLS options share a transport, so they can reuse connections
+var defaultTransport = http.DefaultTransport.(*http.Transport).Clone()
+
+// fetch sends a request to a URL. Its options are a hash with any of method,
+// headers, query, body, timeout, redirects, jar, insecure, ca_cert,
+// client_cert and client_key.
+func fetch(ctx object.CallContext, args ...object.Value) object.Value {
+	err := expectURLAndOptions(ctx, 1, args)
+	if err.IsError() {
+		return err
+	}
+
+	return send(ctx, "", args[0], object.NewNull(), optionsArg(args, 1))
+}
+
+func getRequest(ctx object.CallContext, args ...object.Value) object.Value {
+	err := expectURLAndOptions(ctx, 1, args)
+	if err.IsError() {
+		return err
+	}
+
+	return send(ctx, http.MethodGet, args[0], object.NewNull(), optionsArg(args, 1))
+}
+
+func deleteRequest(ctx object.CallContext, args ...object.Value) object.Value {
+	err := expectURLAndOptions(ctx, 1, args)
+	if err.IsError() {
+		return err
+	}
+
+	return send(ctx, http.MethodDelete, args[0], object.NewNull(), optionsArg(args, 1))
+}
+
+func postRequest(ctx object.CallContext, args ...object.Value) object.Value {
+	return sendBody(ctx, http.MethodPost, args...)
+}
+
+func putRequest(ctx object.CallContext, args ...object.Value) object.Value {
+	return sendBody(ctx, http.MethodPut, args...)
+}
+
+// sendBody sends a request with a body, taking the URL, the body and the options
+func sendBody(ctx object.CallContext, method string, args ...object.Value) object.Value {
+	err := expectURLAndOptions(ctx, 2, args)
+	if err.IsError() {
+		return err
+	}
+
+	body := object.NewNull()
+	if len(args) > 1 {
+		body = args[1]
+	}
+
+	return send(ctx, method, args[0], body, optionsArg(args, 2))
+}
+
+// expectURLAndOptions checks the arguments of the functions sending requests:
+// a URL, up to count-1 other arguments and an optional hash of options
+func expectURLAndOptions(ctx object.CallContext, count int, args []object.Value) object.Value {
+	if len(args) < 1 || len(args) > count+1 {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "expected 1 to %d arguments, got %d", count+1, len(args))
+	}
+
+	err := errors.ExpectType(ctx.Line, ctx.Col, args[0], object.StringKind)
+	if err.IsError() {
+		return err
+	}
+
+	if len(args) == count+1 && !args[count].IsNull() {
+		return errors.ExpectType(ctx.Line, ctx.Col, args[count], object.HashKind)
+	}
+	return object.NewNull()
+}
+
+func optionsArg(args []object.Value, i int) object.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return object.NewNull()
+}
+
+// option looks up key in options, which may be Null
+func option(options object.Value, key string) (object.Value, bool) {
+	if options.IsNull() {
+		return object.NewNull(), false
+	}
+
+	pair, ok := options.AsHash().Pairs[object.NewString(key).HashKey()]
+	if !ok || pair.Value.IsNull() {
+		return object.NewNull(), false
+	}
+	return pair.Value, true
+}
+
+// seconds converts a number of seconds, given as an integer or a float like
+// time.sleep takes them, to a duration
+func seconds(ctx object.CallContext, value object.Value) (time.Duration, object.Value) {
+	err := errors.ExpectOneOfTypes(ctx.Line, ctx.Col, value, object.IntKind, object.FloatKind)
+	if err.IsError() {
+		return 0, err
+	}
+
+	if value.IsInt() {
+		return time.Duration(value.AsInt()) * time.Second, object.NewNull()
+	}
+	return time.Duration(value.AsFloat() * float64(time.Second)), object.NewNull()
+}
+
+// send sends the request described by the arguments of fetch and the other
+// functions, and returns the response. An empty method is taken from the
+// options, defaulting to GET.
+func send(ctx object.CallContext, method string, rawURL, body, options object.Value) object.Value {
+	if errGo := ctx.Runtime().Policy().CheckNet(); errGo != nil {
+		return errors.NewPermissionError(ctx.Line, ctx.Col, errGo)
+	}
+
+	if method == "" {
+		method = http.MethodGet
+		if value, ok := option(options, "method"); ok {
+			if err := errors.ExpectType(ctx.Line, ctx.Col, value, object.StringKind); err.IsError() {
+				return err
+			}
+			method = value.AsString().Value
+		}
+	}
+
+	if value, ok := option(options, "body"); ok && body.IsNull() {
+		body = value
+	}
+
+	u, errGo := url.Parse(rawURL.AsString().Value)
+	if errGo != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "invalid URL %q", rawURL.AsString().Value)
+	}
+
+	if value, ok := option(options, "query"); ok {
+		query, err := stringHash(ctx, value, "query")
+		if err.IsError() {
+			return err
+		}
+
+		values := u.Query()
+		for name, value := range query {
+			values.Set(name, value)
+		}
+		u.RawQuery = values.Encode()
+	}
+
+	data, contentType, err := encodeBody(ctx, body)
+	if err.IsError() {
+		return err
+	}
+
+	client, err := newClient(ctx, options)
+	if err.IsError() {
+		return err
+	}
+
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, errGo := http.NewRequestWithContext(ctx.Runtime().Context(), method, u.String(), reqBody)
+	if errGo != nil {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if value, ok := option(options, "headers"); ok {
+		headers, err := stringHash(ctx, value, "headers")
+		if err.IsError() {
+			return err
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+	}
+
+	var resp *http.Response
+	var respBody []byte
+	ctx.Runtime().Block(func() {
+		resp, errGo = client.Do(req)
+		if errGo != nil {
+			return
+		}
+		defer resp.Body.Close()
+		respBody, errGo = io.ReadAll(resp.Body)
+	})
+
+	if errGo != nil {
+		var urlErr *url.Error
+		if goerrors.As(errGo, &urlErr) && urlErr.Timeout() && ctx.Runtime().Context().Err() == nil {
+			return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s %s: timed out after %s", method, u.Redacted(), client.Timeout)
+		}
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+
+	return newResponse(resp, respBody)
+}
+
+// encodeBody turns the body of a request into bytes. Strings are sent as they
+// are, other values as JSON. Null means there's no body.
+func encodeBody(ctx object.CallContext, body object.Value) ([]byte, string, object.Value) {
+	switch {
+	case body.IsNull():
+		return nil, "", object.NewNull()
+	case body.IsString():
+		return []byte(body.AsString().Value), "", object.NewNull()
+	}
+
+	data, errGo := gojson.Marshal(json.FromObject(body))
+	if errGo != nil {
+		return nil, "", errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+	return data, "application/json", object.NewNull()
+}
+
+// stringHash converts a hash of strings, like the headers option, to a map
+func stringHash(ctx object.CallContext, value object.Value, name string) (map[string]string, object.Value) {
+	err := errors.ExpectType(ctx.Line, ctx.Col, value, object.HashKind)
+	if err.IsError() {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for _, pair := range value.AsHash().Pairs {
+		if !pair.Key.IsString() {
+			return nil, errors.NewTypeError(ctx.Line, ctx.Col, "%s: expected String keys, got %s", name, pair.Key.Kind())
+		}
+
+		switch {
+		case pair.Value.IsString():
+			result[pair.Key.AsString().Value] = pair.Value.AsString().Value
+		case pair.Value.IsInt(), pair.Value.IsFloat(), pair.Value.IsBool():
+			result[pair.Key.AsString().Value] = pair.Value.Inspect()
+		default:
+			return nil, errors.NewTypeError(ctx.Line, ctx.Col, "%s: expected String values, got %s", name, pair.Value.Kind())
+		}
+	}
+	return result, object.NewNull()
+}
+
+// newClient makes a client following the timeout, redirect, cookie and TLS options
+func newClient(ctx object.CallContext, options object.Value) (*http.Client, object.Value) {
+	client := &http.Client{Transport: defaultTransport}
+
+	if value, ok := option(options, "timeout"); ok {
+		timeout, err := seconds(ctx, value)
+		if err.IsError() {
+			return nil, err
+		}
+		client.Timeout = timeout
+	}
+
+	maxRedirects := defaultMaxRedirects
+	if value, ok := option(options, "redirects"); ok {
+		if err := errors.ExpectType(ctx.Line, ctx.Col, value, object.IntKind); err.IsError() {
+			return nil, err
+		}
+		maxRedirects = int(value.AsInt())
+	}
+
+	// Once the redirects run out, the last redirect is the response
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+
+	if value, ok := option(options, "jar"); ok {
+		err := errors.ExpectType(ctx.Line, ctx.Col, value, object.HashKind)
+		if err.IsError() {
+			return nil, err
+		}
+
+		jar, ok := value.AsHash().Native.(*cookiejar.Jar)
+		if !ok {
+			return nil, errors.NewArgumentError(ctx.Line, ctx.Col, "jar expects a cookie jar from http.cookie_jar")
+		}
+		client.Jar = jar
+	}
+
+	config, err := tlsConfig(ctx, options)
+	if err.IsError() {
+		return nil, err
+	}
+	if config != nil {
+		transport := defaultTransport.Clone()
+		transport.TLSClientConfig = config
+		transport.DisableKeepAlives = true
+		client.Transport = transport
+	}
+
+	return client, object.NewNull()
+}
+
+// tlsConfig builds the TLS configuration of the insecure, ca_cert, client_cert
+// and client_key options, or returns nil if none of them are set
+func tlsConfig(ctx object.CallContext, options object.Value) (*tls.Config, object.Value) {
+	var config *tls.Config
+
+	if value, ok := option(options, "insecure"); ok {
+		if err := errors.ExpectType(ctx.Line, ctx.Col, value, object.BoolKind); err.IsError() {
+			return nil, err
+		}
+		config = &tls.Config{InsecureSkipVerify: value.AsBool()}
+	}
+
+	if value, ok := option(options, "ca_cert"); ok {
+		pem, err := readOptionFile(ctx, value)
+		if err.IsError() {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.NewArgumentError(ctx.Line, ctx.Col, "ca_cert: no certificates found in %s", value.AsString().Value)
+		}
+
+		if config == nil {
+			config = &tls.Config{}
+		}
+		config.RootCAs = pool
+	}
+
+	certValue, hasCert := option(options, "client_cert")
+	keyValue, hasKey := option(options, "client_key")
+	if hasCert != hasKey {
+		return nil, errors.NewArgumentError(ctx.Line, ctx.Col, "client_cert and client_key have to be given together")
+	}
+
+	if hasCert {
+		certPEM, err := readOptionFile(ctx, certValue)
+		if err.IsError() {
+			return nil, err
+		}
+		keyPEM, err := readOptionFile(ctx, keyValue)
+		if err.IsError() {
+			return nil, err
+		}
+
+		cert, errGo := tls.X509KeyPair(certPEM, keyPEM)
+		if errGo != nil {
+			return nil, errors.NewArgumentError(ctx.Line, ctx.Col, "client_cert: %s", errGo.Error())
+		}
+
+		if config == nil {
+			config = &tls.Config{}
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, object.NewNull()
+}
+
+// readOptionFile reads a file named by an option, relative to the directory of
+// the calling file, if the sandbox policy allows it
+func readOptionFile(ctx object.CallContext, value object.Value) ([]byte, object.Value) {
+	if err := errors.ExpectType(ctx.Line, ctx.Col, value, object.StringKind); err.IsError() {
+		return nil, err
+	}
+
+	path := scriptPath(ctx, value.AsString().Value)
+
+	if errGo := ctx.Runtime().Policy().CheckRead(path); errGo != nil {
+		return nil, errors.NewPermissionError(ctx.Line, ctx.Col, errGo)
+	}
+
+	data, errGo := vfs.ReadFile(path)
+	if errGo != nil {
+		return nil, errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+	return data, object.NewNull()
+}
+
+// newResponse wraps a response whose body has been read already
+func newResponse(resp *http.Response, body []byte) object.Value {
+	var parsed *object.Value
+
+	return modbuilder.NewHashBuilder().
+		AddInteger("status", int64(resp.StatusCode)).
+		AddBoolean("ok", resp.StatusCode >= 200 && resp.StatusCode < 300).
+		AddString("url", resp.Request.URL.String()).
+		AddValue("headers", headerHash(resp.Header)).
+		AddFunction("text", func(ctx object.CallContext, args ...object.Value) object.Value {
+			err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+			if err.IsError() {
+				return err
+			}
+			return object.NewString(string(body))
+		}).
+		AddFunction("json", func(ctx object.CallContext, args ...object.Value) object.Value {
+			err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+			if err.IsError() {
+				return err
+			}
+
+			if parsed == nil {
+				var data any
+				if errGo := gojson.Unmarshal(body, &data); errGo != nil {
+					return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+				}
+				value := json.ToObject(data)
+				parsed = &value
+			}
+			return *parsed
+		}).
+		Build()
+}
+
+// headerHash turns headers into a hash, joining repeated headers with commas
+func headerHash(header http.Header) object.Value {
+	pairs := make(map[object.HashKey]object.HashPair)
+	for key, values := range header {
+		keyObj := object.NewString(key)
+		pairs[keyObj.HashKey()] = object.NewHashPair(keyObj, object.NewString(strings.Join(values, ", ")))
+	}
+	return object.NewHash(pairs)
+}
+
+// cookieJar makes a jar keeping the cookies servers set between requests
+// given it with the jar option
+func cookieJar(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+	if err.IsError() {
+		return err
+	}
+
+	jar, errGo := cookiejar.New(nil)
+	if errGo != nil {
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+
+	value := modbuilder.NewHashBuilder().
+		AddFunction("cookies", func(ctx object.CallContext, args ...object.Value) object.Value {
+			err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
+			if err.IsError() {
+				return err
+			}
+
+			err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.StringKind)
+			if err.IsError() {
+				return err
+			}
+
+			u, errGo := url.Parse(args[0].AsString().Value)
+			if errGo != nil {
+				return errors.NewArgumentError(ctx.Line, ctx.Col, "invalid URL %q", args[0].AsString().Value)
+			}
+
+			pairs := make(map[object.HashKey]object.HashPair)
+			for _, cookie := range jar.Cookies(u) {
+				key := object.NewString(cookie.Name)
+				pairs[key.HashKey()] = object.NewHashPair(key, object.NewString(cookie.Value))
+			}
+			return object.NewHash(pairs)
+		}).
+		AddFunction("set", func(ctx object.CallContext, args ...object.Value) object.Value {
+			err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 3, args)
+			if err.IsError() {
+				return err
+			}
+
+			for _, arg := range args {
+				if err := errors.ExpectType(ctx.Line, ctx.Col, arg, object.StringKind); err.IsError() {
+					return err
+				}
+			}
+
+			u, errGo := url.Parse(args[0].AsString().Value)
+			if errGo != nil {
+				return errors.NewArgumentError(ctx.Line, ctx.Col, "invalid URL %q", args[0].AsString().Value)
+			}
+
+			jar.SetCookies(u, []*http.Cookie{{Name: args[1].AsString().Value, Value: args[2].AsString().Value}})
+			return object.NewNull()
+		}).
+		Build()
+
+	value.AsHash().Native = jar
+	return value
+}
@@ -0,0 +1,295 @@
+package http
+
+import (
+	gojson "encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
+	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/sandbox"
+)
+
+// scriptContext returns the context of a call from a script running under policy,
+// which holds the runtime lock like running scripts do
+func scriptContext(t *testing.T, policy *sandbox.Policy) object.CallContext {
+	t.Helper()
+
+	env := object.NewEnvironment()
+	env.Runtime().SetPolicy(policy)
+	env.Runtime().Acquire()
+	t.Cleanup(env.Runtime().Release)
+
+	return object.CallContext{Env: env}
+}
+
+func hashOf(values map[string]object.Value) object.Value {
+	hb := modbuilder.NewHashBuilder()
+	for key, value := range values {
+		hb.AddValue(key, value)
+	}
+	return hb.Build()
+}
+
+func mustSucceed(t *testing.T, result object.Value) object.Value {
+	t.Helper()
+
+	if result.IsError() {
+		t.Fatal(result.Inspect())
+	}
+	return result
+}
+
+// echoServer answers with a JSON description of every request it gets
+func echoServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		gojson.NewEncoder(w).Encode(map[string]string{
+			"method":       r.Method,
+			"path":         r.URL.Path,
+			"query":        r.URL.RawQuery,
+			"test":         r.Header.Get("X-Test"),
+			"content_type": r.Header.Get("Content-Type"),
+			"body":         string(body),
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetch(t *testing.T) {
+	server := echoServer(t)
+	ctx := scriptContext(t, nil)
+
+	resp := mustSucceed(t, fetch(ctx, object.NewString(server.URL+"/echo?a=1"), hashOf(map[string]object.Value{
+		"method":  object.NewString("PATCH"),
+		"headers": hashOf(map[string]object.Value{"X-Test": object.NewString("yes")}),
+		"query":   hashOf(map[string]object.Value{"b": object.NewString("x y")}),
+		"body":    hashOf(map[string]object.Value{"n": object.NewInt(1)}),
+	})))
+
+	if status := get(t, resp, "status"); status.AsInt() != 200 || !get(t, resp, "ok").AsBool() {
+		t.Errorf("expected status 200, got %s", status.Inspect())
+	}
+
+	if contentType := get(t, get(t, resp, "headers"), "Content-Type"); contentType.AsString().Value != "application/json" {
+		t.Errorf("expected a JSON response, got %s", contentType.Inspect())
+	}
+
+	data := mustCall(t, resp, "json")
+	expected := map[string]string{
+		"method":       "PATCH",
+		"path":         "/echo",
+		"query":        "a=1&b=x+y",
+		"test":         "yes",
+		"content_type": "application/json",
+		"body":         `{"n":1}`,
+	}
+	for key, value := range expected {
+		if got := get(t, data, key).AsString().Value; got != value {
+			t.Errorf("%s: expected %q, got %q", key, value, got)
+		}
+	}
+
+	tests := []struct {
+		fn     object.BuiltinFunction
+		args   []object.Value
+		method string
+		body   string
+	}{
+		{getRequest, nil, "GET", ""},
+		{deleteRequest, nil, "DELETE", ""},
+		{postRequest, []object.Value{object.NewString("text")}, "POST", "text"},
+		{putRequest, []object.Value{object.NewArray([]object.Value{object.NewInt(1)})}, "PUT", "[1]"},
+	}
+
+	for _, tt := range tests {
+		args := append([]object.Value{object.NewString(server.URL)}, tt.args...)
+		data := mustCall(t, mustSucceed(t, tt.fn(ctx, args...)), "json")
+
+		if method := get(t, data, "method").AsString().Value; method != tt.method {
+			t.Errorf("expected %s, got %s", tt.method, method)
+		}
+		if body := get(t, data, "body").AsString().Value; body != tt.body {
+			t.Errorf("%s: expected body %q, got %q", tt.method, tt.body, body)
+		}
+	}
+
+	// Error statuses are responses too
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	resp = mustSucceed(t, getRequest(ctx, object.NewString(missing.URL)))
+	if get(t, resp, "status").AsInt() != 404 || get(t, resp, "ok").AsBool() {
+		t.Errorf("expected a 404 response, got %s", resp.Inspect())
+	}
+	if text := mustCall(t, resp, "text").AsString().Value; !strings.Contains(text, "not found") {
+		t.Errorf("expected the body of the 404 response, got %q", text)
+	}
+
+	if result := getRequest(ctx, object.NewString("ftp://example.com")); !result.IsError() {
+		t.Errorf("expected an error for a URL that isn't HTTP, got %s", result.Inspect())
+	}
+}
+
+func TestFetchPolicy(t *testing.T) {
+	server := echoServer(t)
+
+	policy := sandbox.New()
+	policy.SetNet(false)
+
+	result := getRequest(scriptContext(t, policy), object.NewString(server.URL))
+	if !result.IsError() || !strings.Contains(result.AsError().Message, "not allowed") {
+		t.Errorf("expected a permission error, got %s", result.Inspect())
+	}
+}
+
+func TestFetchRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/middle", http.StatusFound))
+	mux.Handle("/middle", http.RedirectHandler("/new", http.StatusMovedPermanently))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "new") })
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := scriptContext(t, nil)
+
+	tests := []struct {
+		redirects object.Value
+		status    int64
+		url       string
+	}{
+		{object.NewNull(), 200, "/new"},
+		{object.NewInt(1), 301, "/middle"},
+		{object.NewInt(0), 302, "/old"},
+	}
+
+	for _, tt := range tests {
+		resp := mustSucceed(t, getRequest(ctx, object.NewString(server.URL+"/old"),
+			hashOf(map[string]object.Value{"redirects": tt.redirects})))
+
+		if status := get(t, resp, "status").AsInt(); status != tt.status {
+			t.Errorf("redirects %s: expected status %d, got %d", tt.redirects.Inspect(), tt.status, status)
+		}
+		if url := get(t, resp, "url").AsString().Value; url != server.URL+tt.url {
+			t.Errorf("redirects %s: expected to end at %s, got %s", tt.redirects.Inspect(), tt.url, url)
+		}
+	}
+}
+
+func TestFetchCookies(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+	})
+	mux.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session"); err == nil {
+			io.WriteString(w, cookie.Value)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := scriptContext(t, nil)
+	jar := cookieJar(ctx)
+	options := hashOf(map[string]object.Value{"jar": jar})
+
+	mustSucceed(t, getRequest(ctx, object.NewString(server.URL+"/login"), options))
+
+	resp := mustSucceed(t, getRequest(ctx, object.NewString(server.URL+"/me"), options))
+	if text := mustCall(t, resp, "text").AsString().Value; text != "abc" {
+		t.Errorf("expected the session cookie to be sent, got %q", text)
+	}
+
+	resp = mustSucceed(t, getRequest(ctx, object.NewString(server.URL+"/me")))
+	if text := mustCall(t, resp, "text").AsString().Value; text != "" {
+		t.Errorf("expected no cookies without the jar, got %q", text)
+	}
+
+	cookies := mustCall(t, jar, "cookies", object.NewString(server.URL))
+	if session := get(t, cookies, "session").AsString().Value; session != "abc" {
+		t.Errorf("expected the jar to hold the session cookie, got %q", session)
+	}
+
+	mustCall(t, jar, "set", object.NewString(server.URL), object.NewString("session"), object.NewString("xyz"))
+	resp = mustSucceed(t, getRequest(ctx, object.NewString(server.URL+"/me"), options))
+	if text := mustCall(t, resp, "text").AsString().Value; text != "xyz" {
+		t.Errorf("expected the cookie set on the jar, got %q", text)
+	}
+
+	options = hashOf(map[string]object.Value{"jar": hashOf(nil)})
+	if result := getRequest(ctx, object.NewString(server.URL+"/me"), options); !result.IsError() {
+		t.Errorf("expected an error for a hash that isn't a cookie jar, got %s", result.Inspect())
+	}
+}
+
+func TestFetchTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure")
+	}))
+	defer server.Close()
+
+	ctx := scriptContext(t, nil)
+
+	if result := getRequest(ctx, object.NewString(server.URL)); !result.IsError() || !strings.Contains(result.AsError().Message, "certificate") {
+		t.Errorf("expected a certificate error, got %s", result.Inspect())
+	}
+
+	resp := mustSucceed(t, getRequest(ctx, object.NewString(server.URL), hashOf(map[string]object.Value{
+		"insecure": object.NewBool(true),
+	})))
+	if text := mustCall(t, resp, "text").AsString().Value; text != "secure" {
+		t.Errorf("expected \"secure\", got %q", text)
+	}
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caCert, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = mustSucceed(t, getRequest(ctx, object.NewString(server.URL), hashOf(map[string]object.Value{
+		"ca_cert": object.NewString(caCert),
+	})))
+	if text := mustCall(t, resp, "text").AsString().Value; text != "secure" {
+		t.Errorf("expected \"secure\", got %q", text)
+	}
+
+	// Certificates are files, so the sandbox has to allow reading them
+	policy := sandbox.New()
+	if err := policy.AllowRead(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	result := getRequest(scriptContext(t, policy), object.NewString(server.URL), hashOf(map[string]object.Value{
+		"ca_cert": object.NewString(caCert),
+	}))
+	if !result.IsError() || !strings.Contains(result.AsError().Message, "not allowed") {
+		t.Errorf("expected a permission error, got %s", result.Inspect())
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	result := getRequest(scriptContext(t, nil), object.NewString(server.URL), hashOf(map[string]object.Value{
+		"timeout": object.NewFloat(0.05),
+	}))
+	if !result.IsError() || !strings.Contains(result.AsError().Message, "timed out after 50ms") {
+		t.Errorf("expected a timeout error, got %s", result.Inspect())
+	}
+}
@@ -0,0 +1,289 @@
+package http
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+// defaultMaxBodyBytes is the size of the largest request body servers take,
+// unless listen is given a max_body_bytes
+const defaultMaxBodyBytes = 10 << 20
+
+// multipartMemory is how much of a multipart body is kept in memory.
+// Larger uploads go to temporary files, which are removed after the request.
+const multipartMemory = 32 << 20
+
+// limitedBody is a request body that fails once more than limit bytes are read.
+// The limit can change until the body is read, as the request goes through
+// routers with limits of their own.
+type limitedBody struct {
+	io.ReadCloser
+	limit    int64 // 0 for no limit
+	read     int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.limit <= 0 {
+		return b.ReadCloser.Read(p)
+	}
+	if b.exceeded {
+		return 0, &http.MaxBytesError{Limit: b.limit}
+	}
+
+	// Read a byte past the limit to tell whether the body goes on
+	if left := b.limit - b.read + 1; int64(len(p)) > left {
+		p = p[:left]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		b.exceeded = true
+		return n - int(b.read-b.limit), &http.MaxBytesError{Limit: b.limit}
+	}
+	return n, err
+}
+
+// declaredTooLarge reports whether the request says its body is over the limit
+func (b *limitedBody) declaredTooLarge(r *http.Request) bool {
+	return b.limit > 0 && r.ContentLength > b.limit
+}
+
+// rejectBody answers 413 Payload Too Large, unless the response has started already
+func rejectBody(w *statusWriter) {
+	if w.status != 0 {
+		return
+	}
+
+	// The rest of the body isn't read, so the connection can't be reused
+	w.Header().Set("Connection", "close")
+	http.Error(w, "413 payload too large", http.StatusRequestEntityTooLarge)
+}
+
+// setMaxBodyBytes sets the size of the largest request body the routes of the
+// router take, overriding the limit of the server. Zero means there's no limit.
+func (rt *router) setMaxBodyBytes(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.IntKind)
+	if err.IsError() {
+		return err
+	}
+
+	if args[0].AsInt() < 0 {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "invalid limit %d", args[0].AsInt())
+	}
+
+	rt.maxBodyBytes = args[0].AsInt()
+	return object.NewNull()
+}
+
+// bodyError turns an error reading the body into an error value,
+// answering 413 if the body is over the limit
+func (req *request) bodyError(ctx object.CallContext, errGo error) object.Value {
+	if req.limited.exceeded {
+		rejectBody(req.w)
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "request body is larger than %d bytes", req.limited.limit)
+	}
+	return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+}
+
+// mediaType returns the media type of the request, without parameters
+func (req *request) mediaType() string {
+	mediaType, _, _ := mime.ParseMediaType(req.r.Header.Get("Content-Type"))
+	return mediaType
+}
+
+// form returns the fields of a URL-encoded or multipart form
+func (req *request) form(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+	if err.IsError() {
+		return err
+	}
+
+	switch req.mediaType() {
+	case "application/x-www-form-urlencoded":
+		body, err := req.readBody(ctx)
+		if err.IsError() {
+			return err
+		}
+
+		values, errGo := url.ParseQuery(body)
+		if errGo != nil {
+			return errors.NewRuntimeError(ctx.Line, ctx.Col, "invalid form: %s", errGo.Error())
+		}
+		return formValues(values)
+
+	case "multipart/form-data":
+		err := req.parseMultipart(ctx)
+		if err.IsError() {
+			return err
+		}
+		return formValues(req.r.MultipartForm.Value)
+
+	default:
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "expected a form, got a body of type %q", req.r.Header.Get("Content-Type"))
+	}
+}
+
+// multipart returns the fields and files of a multipart form
+func (req *request) multipart(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+	if err.IsError() {
+		return err
+	}
+
+	if req.mediaType() != "multipart/form-data" {
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "expected a multipart form, got a body of type %q", req.r.Header.Get("Content-Type"))
+	}
+
+	err = req.parseMultipart(ctx)
+	if err.IsError() {
+		return err
+	}
+
+	files := make(map[object.HashKey]object.HashPair)
+	for name, headers := range req.r.MultipartForm.File {
+		uploads := make([]object.Value, len(headers))
+		for i, header := range headers {
+			uploads[i] = uploadedFile(header)
+		}
+
+		key := object.NewString(name)
+		if len(uploads) == 1 {
+			files[key.HashKey()] = object.NewHashPair(key, uploads[0])
+		} else {
+			files[key.HashKey()] = object.NewHashPair(key, object.NewArray(uploads))
+		}
+	}
+
+	return modbuilder.NewHashBuilder().
+		AddValue("fields", formValues(req.r.MultipartForm.Value)).
+		AddValue("files", object.NewHash(files)).
+		Build()
+}
+
+// parseMultipart reads the multipart form in the body, once. The body is
+// gone after a failed read, so later calls fail with the same error.
+func (req *request) parseMultipart(ctx object.CallContext) object.Value {
+	if req.multipartErr != nil {
+		return req.bodyError(ctx, req.multipartErr)
+	}
+	if req.r.MultipartForm != nil {
+		return object.NewNull()
+	}
+
+	ctx.Runtime().Block(func() {
+		req.multipartErr = req.r.ParseMultipartForm(multipartMemory)
+	})
+	if req.multipartErr != nil {
+		return req.bodyError(ctx, req.multipartErr)
+	}
+	return object.NewNull()
+}
+
+// formValues turns form fields into a hash of strings, with arrays
+// for fields given more than once
+func formValues(values map[string][]string) object.Value {
+	pairs := make(map[object.HashKey]object.HashPair)
+	for name, list := range values {
+		key := object.NewString(name)
+		if len(list) == 1 {
+			pairs[key.HashKey()] = object.NewHashPair(key, object.NewString(list[0]))
+			continue
+		}
+
+		elements := make([]object.Value, len(list))
+		for i, value := range list {
+			elements[i] = object.NewString(value)
+		}
+		pairs[key.HashKey()] = object.NewHashPair(key, object.NewArray(elements))
+	}
+	return object.NewHash(pairs)
+}
+
+// uploadedFile is the hash of a file uploaded with a multipart form
+func uploadedFile(header *multipart.FileHeader) object.Value {
+	return modbuilder.NewHashBuilder().
+		AddString("name", header.Filename).
+		AddInteger("size", header.Size).
+		AddString("content_type", header.Header.Get("Content-Type")).
+		AddFunction("text", func(ctx object.CallContext, args ...object.Value) object.Value {
+			err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+			if err.IsError() {
+				return err
+			}
+
+			var data []byte
+			var errGo error
+			ctx.Runtime().Block(func() {
+				var f multipart.File
+				if f, errGo = header.Open(); errGo != nil {
+					return
+				}
+				defer f.Close()
+				data, errGo = io.ReadAll(f)
+			})
+			if errGo != nil {
+				return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+			}
+			return object.NewString(string(data))
+		}).
+		AddFunction("save", func(ctx object.CallContext, args ...object.Value) object.Value {
+			err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
+			if err.IsError() {
+				return err
+			}
+
+			err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.StringKind)
+			if err.IsError() {
+				return err
+			}
+
+			path := scriptPath(ctx, args[0].AsString().Value)
+			if errGo := ctx.Runtime().Policy().CheckWrite(path); errGo != nil {
+				return errors.NewPermissionError(ctx.Line, ctx.Col, errGo)
+			}
+
+			var errGo error
+			ctx.Runtime().Block(func() {
+				errGo = saveUpload(header, path)
+			})
+			if errGo != nil {
+				return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+			}
+			return object.NewNull()
+		}).
+		Build()
+}
+
+func saveUpload(header *multipart.FileHeader, path string) error {
+	src, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,245 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/sandbox"
+)
+
+// multipartBody encodes fields and files, by field name and then file name, as a multipart form
+func multipartBody(t *testing.T, fields map[string]string, files map[string]map[string]string) (io.Reader, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	for field, uploads := range files {
+		for name, content := range uploads {
+			part, err := mw.CreateFormFile(field, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write([]byte(content))
+		}
+	}
+	mw.Close()
+
+	return &body, mw.FormDataContentType()
+}
+
+func TestRequestForm(t *testing.T) {
+	var result object.Value
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "post", object.NewString("/"), builtin(func(args ...object.Value) object.Value {
+		result = call(t, args[1], "form")
+		return object.NewNull()
+	}))
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader("name=Ada+Lovelace&tag=math&tag=poetry"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	serveRequest(server, r)
+
+	mustSucceed(t, result)
+	if name := get(t, result, "name"); name.AsString().Value != "Ada Lovelace" {
+		t.Errorf("expected name to be \"Ada Lovelace\", got %s", name.Inspect())
+	}
+	if tags := get(t, result, "tag"); !tags.IsArray() || len(tags.AsArray().Elements) != 2 {
+		t.Errorf("expected both tags, got %s", tags.Inspect())
+	}
+
+	body, contentType := multipartBody(t, map[string]string{"name": "Grace"}, nil)
+	r = httptest.NewRequest("POST", "/", body)
+	r.Header.Set("Content-Type", contentType)
+	serveRequest(server, r)
+
+	if name := get(t, mustSucceed(t, result), "name"); name.AsString().Value != "Grace" {
+		t.Errorf("expected name to be \"Grace\", got %s", name.Inspect())
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"Ada"}`))
+	r.Header.Set("Content-Type", "application/json")
+	serveRequest(server, r)
+
+	if !result.IsError() {
+		t.Errorf("expected an error for a JSON body, got %s", result.Inspect())
+	}
+}
+
+func TestRequestMultipart(t *testing.T) {
+	var result object.Value
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "post", object.NewString("/upload"), builtin(func(args ...object.Value) object.Value {
+		result = call(t, args[1], "multipart")
+		return object.NewNull()
+	}))
+
+	body, contentType := multipartBody(t,
+		map[string]string{"title": "holiday"},
+		map[string]map[string]string{
+			"cover":  {"cover.txt": "front"},
+			"photos": {"a.txt": "first", "b.txt": "second"},
+		},
+	)
+	r := httptest.NewRequest("POST", "/upload", body)
+	r.Header.Set("Content-Type", contentType)
+	serveRequest(server, r)
+
+	mustSucceed(t, result)
+	if title := get(t, get(t, result, "fields"), "title"); title.AsString().Value != "holiday" {
+		t.Errorf("expected the title field, got %s", title.Inspect())
+	}
+
+	files := get(t, result, "files")
+	if photos := get(t, files, "photos"); !photos.IsArray() || len(photos.AsArray().Elements) != 2 {
+		t.Errorf("expected two photos, got %s", photos.Inspect())
+	}
+
+	cover := get(t, files, "cover")
+	if name := get(t, cover, "name"); name.AsString().Value != "cover.txt" {
+		t.Errorf("expected cover.txt, got %s", name.Inspect())
+	}
+	if size := get(t, cover, "size"); size.AsInt() != 5 {
+		t.Errorf("expected a size of 5, got %s", size.Inspect())
+	}
+	if contentType := get(t, cover, "content_type"); contentType.AsString().Value != "application/octet-stream" {
+		t.Errorf("expected application/octet-stream, got %s", contentType.Inspect())
+	}
+	if text := mustCall(t, cover, "text"); text.AsString().Value != "front" {
+		t.Errorf("expected \"front\", got %s", text.Inspect())
+	}
+
+	dir := t.TempDir()
+	mustCall(t, cover, "save", object.NewString(filepath.Join(dir, "cover.txt")))
+	if data, err := os.ReadFile(filepath.Join(dir, "cover.txt")); err != nil || string(data) != "front" {
+		t.Errorf("expected the saved file to hold \"front\", got %q (%v)", data, err)
+	}
+
+	policy := sandbox.New()
+	policy.AllowWrite(t.TempDir())
+	save := get(t, cover, "save").AsBuiltin().Fn(scriptContext(t, policy), object.NewString(filepath.Join(dir, "denied.txt")))
+	if !save.IsError() || !strings.Contains(save.AsError().Message, "not allowed") {
+		t.Errorf("expected a permission error, got %s", save.Inspect())
+	}
+
+	r = httptest.NewRequest("POST", "/upload", strings.NewReader("a=1"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	serveRequest(server, r)
+	if !result.IsError() {
+		t.Errorf("expected an error for a URL-encoded body, got %s", result.Inspect())
+	}
+}
+
+func TestRequestMultipartTruncated(t *testing.T) {
+	var form, multipart object.Value
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "post", object.NewString("/"), builtin(func(args ...object.Value) object.Value {
+		form = call(t, args[1], "form")
+		multipart = call(t, args[1], "multipart")
+		return object.NewNull()
+	}))
+
+	body, contentType := multipartBody(t, nil, map[string]map[string]string{
+		"file": {"notes.txt": "some notes"},
+	})
+	data, _ := io.ReadAll(body)
+
+	// Cut the body short in its closing boundary
+	r := httptest.NewRequest("POST", "/", bytes.NewReader(data[:len(data)-10]))
+	r.Header.Set("Content-Type", contentType)
+	serveRequest(server, r)
+
+	if !form.IsError() {
+		t.Fatalf("expected an error for a truncated body, got %s", form.Inspect())
+	}
+	if !multipart.IsError() || multipart.AsError().Message != form.AsError().Message {
+		t.Errorf("expected the same error again, got %s and %s", form.Inspect(), multipart.Inspect())
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	var result object.Value
+	echoBody := builtin(func(args ...object.Value) object.Value {
+		result = call(t, args[1], "body")
+		if result.IsError() {
+			return result
+		}
+		return call(t, args[0], "send", result)
+	})
+
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "post", object.NewString("/"), echoBody)
+
+	uploads := createRouter(object.NewCallContext(0, 0))
+	mustCall(t, uploads, "post", object.NewString("/"), echoBody)
+	mustCall(t, uploads, "max_body_bytes", object.NewInt(0))
+	mustCall(t, server, "mount", object.NewString("/uploads"), uploads)
+
+	small := createRouter(object.NewCallContext(0, 0))
+	mustCall(t, small, "post", object.NewString("/"), echoBody)
+	mustCall(t, small, "max_body_bytes", object.NewInt(3))
+	mustCall(t, server, "mount", object.NewString("/small"), small)
+
+	h := &handler{ctx: object.NewCallContext(0, 0), router: mustRouter(t, server), maxBodyBytes: 10}
+	post := func(target string, body io.Reader) *httptest.ResponseRecorder {
+		result = object.NewNull()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", target, body))
+		return w
+	}
+
+	// Bodies declared too large are turned down before the handler runs
+	w := post("/", strings.NewReader(strings.Repeat("x", 11)))
+	expectResponse(t, w, 413, "413 payload too large")
+	if !result.IsNull() {
+		t.Errorf("expected the handler not to run, got %s", result.Inspect())
+	}
+
+	// Bodies of unknown length fail once they go over the limit
+	w = post("/", io.MultiReader(strings.NewReader(strings.Repeat("x", 11))))
+	expectResponse(t, w, 413, "413 payload too large")
+	if !result.IsError() || !strings.Contains(result.AsError().Message, "larger than 10 bytes") {
+		t.Errorf("expected an error reading the body, got %s", result.Inspect())
+	}
+
+	expectResponse(t, post("/", io.MultiReader(strings.NewReader("0123456789"))), 200, "0123456789")
+	expectResponse(t, post("/uploads/", strings.NewReader(strings.Repeat("x", 100))), 200, strings.Repeat("x", 100))
+	expectResponse(t, post("/small/", strings.NewReader("1234")), 413, "413 payload too large")
+
+	if result := call(t, small, "max_body_bytes", object.NewInt(-1)); !result.IsError() {
+		t.Errorf("expected an error for a negative limit, got %s", result.Inspect())
+	}
+}
+
+func TestBodyLimitMultipart(t *testing.T) {
+	var result object.Value
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "post", object.NewString("/"), builtin(func(args ...object.Value) object.Value {
+		result = call(t, args[1], "multipart")
+		return object.NewNull()
+	}))
+
+	body, contentType := multipartBody(t, nil, map[string]map[string]string{
+		"file": {"big.txt": strings.Repeat("x", 1000)},
+	})
+	r := httptest.NewRequest("POST", "/", io.MultiReader(body))
+	r.Header.Set("Content-Type", contentType)
+
+	w := httptest.NewRecorder()
+	h := &handler{ctx: object.NewCallContext(0, 0), router: mustRouter(t, server), maxBodyBytes: 100}
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge || !result.IsError() {
+		t.Errorf("expected 413 and an error, got %d and %s", w.Code, result.Inspect())
+	}
+}
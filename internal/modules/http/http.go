@@ -5,121 +5,36 @@ import (
 	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
 	"github.com/radeqq007/sunbird/internal/object"
 	"net/http"
-	"strconv"
 )
 
 func New() object.Value {
 	return modbuilder.NewModuleBuilder().
 		AddFunction("create_server", createServer).
+		AddFunction("create_router", createRouter).
+		AddFunction("fetch", fetch).
+		AddFunction("get", getRequest).
+		AddFunction("post", postRequest).
+		AddFunction("put", putRequest).
+		AddFunction("delete", deleteRequest).
+		AddFunction("cookie_jar", cookieJar).
+		AddFunction("ws_connect", wsConnect).
 		AddValue("status", statusCodes).
 		AddValue("methods", methods).
 		Build()
 }
 
+// createServer makes a server with a router of its own
 func createServer(ctx object.CallContext, args ...object.Value) object.Value {
-	server := modbuilder.NewHashBuilder().
-		AddFunction("get", getRoute).
-		AddFunction("post", postRoute).
-		AddFunction("put", putRoute).
-		AddFunction("delete", deleteRoute).
-		AddFunction("patch", patchRoute).
-		AddFunction("head", headRoute).
-		AddFunction("options", optionsRoute).
-		AddFunction("connect", connectRoute).
-		AddFunction("trace", traceRoute).
-		AddFunction("listen", listen).
-		Build()
-
-	return server
-}
-
-func route(ctx object.CallContext, method string, args ...object.Value) object.Value {
-	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 2, args)
-	if err.IsError() {
-		return err
-	}
-
-	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.StringKind)
-	if err.IsError() {
-		return err
-	}
-
-	err = errors.ExpectType(1, 0, args[1], object.FunctionKind)
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
 	if err.IsError() {
 		return err
 	}
 
-	route := args[0].AsString().Value
-	callback := args[1]
-
-	http.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
-		funcArgs := []object.Value{
-			newWriter(w),
-			newRequest(r),
-		}
-		if r.Method == method && object.ApplyFunction != nil {
-			object.ApplyFunction(callback, funcArgs)
-		}
-	})
-
-	return object.NewNull()
-}
-
-func getRoute(ctx object.CallContext, args ...object.Value) object.Value {
-	return route(ctx, http.MethodGet, args...)
-}
-
-func postRoute(ctx object.CallContext, args ...object.Value) object.Value {
-	return route(ctx, http.MethodPost, args...)
-}
-
-func putRoute(ctx object.CallContext, args ...object.Value) object.Value {
-	return route(ctx, http.MethodPut, args...)
-}
-
-func deleteRoute(ctx object.CallContext, args ...object.Value) object.Value {
-	return route(ctx, http.MethodDelete, args...)
-}
-
-func patchRoute(ctx object.CallContext, args ...object.Value) object.Value {
-	return route(ctx, http.MethodPatch, args...)
-}
-
-func headRoute(ctx object.CallContext, args ...object.Value) object.Value {
-	return route(ctx, http.MethodHead, args...)
-}
-
-func optionsRoute(ctx object.CallContext, args ...object.Value) object.Value {
-	return route(ctx, http.MethodOptions, args...)
-}
-
-func connectRoute(ctx object.CallContext, args ...object.Value) object.Value {
-	return route(ctx, http.MethodConnect, args...)
-}
-
-func traceRoute(ctx object.CallContext, args ...object.Value) object.Value {
-	return route(ctx, http.MethodTrace, args...)
-}
-
-func listen(ctx object.CallContext, args ...object.Value) object.Value {
-	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
-	if err.IsError() {
-		return err
-	}
-
-	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.IntKind)
-	if err.IsError() {
-		return err
-	}
-
-	port := args[0].AsInt()
-
-	errGo := http.ListenAndServe(":"+strconv.FormatInt(port, 10), nil)
-	if errGo != nil {
-		return errors.New(errors.RuntimeError, 0, 0, "%s", errGo.Error())
-	}
-
-	return object.NewNull()
+	rt := newRouter()
+	return rt.hash(modbuilder.NewHashBuilder().
+		AddFunction("listen", func(ctx object.CallContext, args ...object.Value) object.Value {
+			return listen(ctx, rt, args...)
+		}))
 }
 
 var statusCodes = modbuilder.NewHashBuilder().
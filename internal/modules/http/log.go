@@ -0,0 +1,112 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	gojson "encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+// logOutput is where servers log errors and, with access_log set to true, requests
+var logOutput io.Writer = os.Stderr
+
+// requestIDHeader carries the ID of a request, both ways
+const requestIDHeader = "X-Request-Id"
+
+// requestID returns the ID the client gave the request, if it's usable,
+// or a new random one
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); validRequestID(id) {
+		return id
+	}
+	return newRequestID()
+}
+
+// validRequestID reports whether id is short and printable enough to be
+// logged and sent back as it is
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := range len(id) {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// accessEntry describes a request that was served, for the access log
+type accessEntry struct {
+	Time    time.Time
+	ID      string
+	Method  string
+	Path    string
+	Status  int
+	Latency time.Duration
+}
+
+func (e accessEntry) latencyMS() float64 {
+	return float64(e.Latency.Microseconds()) / 1000
+}
+
+// accessLogger makes the access log asked for by the access_log option of listen:
+// true writes a JSON line for every request to standard error, and a function
+// is called with a hash describing every request
+func accessLogger(ctx object.CallContext, option object.Value) (func(entry accessEntry), object.Value) {
+	if option.IsBool() {
+		if !option.AsBool() {
+			return nil, object.NewNull()
+		}
+		return writeAccessEntry, object.NewNull()
+	}
+
+	err := expectCallable(ctx, option)
+	if err.IsError() {
+		return nil, errors.NewArgumentError(ctx.Line, ctx.Col, "access_log has to be true, false or a function")
+	}
+
+	return func(entry accessEntry) {
+		result := object.ApplyFunction(ctx, option, []object.Value{entry.hash()})
+		if result.IsError() {
+			fmt.Fprintf(logOutput, "http: error logging request %s: %s\n", entry.ID, result.Inspect())
+		}
+	}, object.NewNull()
+}
+
+func writeAccessEntry(entry accessEntry) {
+	line, _ := gojson.Marshal(map[string]any{
+		"time":       entry.Time.UTC().Format(time.RFC3339Nano),
+		"id":         entry.ID,
+		"method":     entry.Method,
+		"path":       entry.Path,
+		"status":     entry.Status,
+		"latency_ms": entry.latencyMS(),
+	})
+	fmt.Fprintf(logOutput, "%s\n", line)
+}
+
+func (e accessEntry) hash() object.Value {
+	return modbuilder.NewHashBuilder().
+		AddString("time", e.Time.UTC().Format(time.RFC3339Nano)).
+		AddString("id", e.ID).
+		AddString("method", e.Method).
+		AddString("path", e.Path).
+		AddInteger("status", int64(e.Status)).
+		AddFloat("latency_ms", e.latencyMS()).
+		Build()
+}
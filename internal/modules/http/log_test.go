@@ -0,0 +1,192 @@
+package http
+
+import (
+	"bytes"
+	gojson "encoding/json"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+// captureLog sends what servers log to a buffer until the test ends
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	logOutput = &buf
+	t.Cleanup(func() { logOutput = os.Stderr })
+	return &buf
+}
+
+// fail is a handler failing with message
+func fail(message string) object.Value {
+	return builtin(func(args ...object.Value) object.Value {
+		return errors.NewRuntimeError(1, 2, "%s", message)
+	})
+}
+
+func TestHandlerErrors(t *testing.T) {
+	log := captureLog(t)
+
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "get", object.NewString("/boom"), fail("boom"))
+	mustCall(t, server, "get", object.NewString("/late"), builtin(func(args ...object.Value) object.Value {
+		call(t, args[0], "send", object.NewString("partial"))
+		return errors.NewRuntimeError(0, 0, "too late")
+	}))
+
+	// Errors nothing handles are 500s, and get logged
+	expectResponse(t, serve(server, "GET", "/boom"), 500, "500 internal server error")
+	if !strings.Contains(log.String(), "GET /boom") || !strings.Contains(log.String(), "boom") {
+		t.Errorf("expected the error to be logged, got %q", log.String())
+	}
+
+	// Responses that have started keep their status
+	expectResponse(t, serve(server, "GET", "/late"), 200, "partial")
+
+	var caught object.Value
+	mustCall(t, server, "on_error", builtin(func(args ...object.Value) object.Value {
+		caught = args[2]
+		return call(t, args[0], "json", hashOf(map[string]object.Value{"error": object.NewString(args[2].AsError().Message)}))
+	}))
+
+	log.Reset()
+	expectResponse(t, serve(server, "GET", "/boom"), 500, `{"error":"RuntimeError: boom"}`)
+	if !caught.IsError() || caught.AsError().Propagating {
+		t.Errorf("expected the handler to get the error as a value, got %s", caught.Inspect())
+	}
+	if log.Len() != 0 {
+		t.Errorf("expected handled errors not to be logged, got %q", log.String())
+	}
+
+	// Mounted routers use the error handler of the server unless they have their own
+	api := createRouter(object.NewCallContext(0, 0))
+	mustCall(t, api, "get", object.NewString("/boom"), fail("api boom"))
+	mustCall(t, server, "mount", object.NewString("/api"), api)
+	expectResponse(t, serve(server, "GET", "/api/boom"), 500, `{"error":"RuntimeError: api boom"}`)
+
+	admin := createRouter(object.NewCallContext(0, 0))
+	mustCall(t, admin, "use", fail("denied"))
+	mustCall(t, admin, "get", object.NewString("/"), reply(t, "admin"))
+	mustCall(t, admin, "on_error", builtin(func(args ...object.Value) object.Value {
+		call(t, args[0], "status", object.NewInt(403))
+		return call(t, args[0], "send", object.NewString("forbidden"))
+	}))
+	mustCall(t, server, "mount", object.NewString("/admin"), admin)
+	expectResponse(t, serve(server, "GET", "/admin/"), 403, "forbidden")
+
+	// Errors of error handlers go to the router above, and then to the default
+	mustCall(t, admin, "on_error", fail("handler boom"))
+	expectResponse(t, serve(server, "GET", "/admin/"), 500, `{"error":"RuntimeError: handler boom"}`)
+	mustCall(t, server, "on_error", fail("handler boom"))
+	expectResponse(t, serve(server, "GET", "/boom"), 500, "500 internal server error")
+}
+
+func TestRequestContext(t *testing.T) {
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "use", builtin(func(args ...object.Value) object.Value {
+		context := get(t, args[1], "context").AsHash()
+		key := object.NewString("user")
+		context.Pairs[key.HashKey()] = object.NewHashPair(key, object.NewString("ada"))
+		return args[2].AsBuiltin().Fn(object.NewCallContext(0, 0))
+	}))
+	mustCall(t, server, "get", object.NewString("/"), builtin(func(args ...object.Value) object.Value {
+		return call(t, args[0], "send", get(t, get(t, args[1], "context"), "user"))
+	}))
+
+	expectResponse(t, serve(server, "GET", "/"), 200, "ada")
+}
+
+func TestRequestID(t *testing.T) {
+	var id object.Value
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "get", object.NewString("/"), builtin(func(args ...object.Value) object.Value {
+		id = call(t, args[1], "id")
+		return object.NewNull()
+	}))
+
+	w := serve(server, "GET", "/")
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(id.AsString().Value) {
+		t.Errorf("expected a random ID, got %s", id.Inspect())
+	}
+	if header := w.Header().Get("X-Request-Id"); header != id.AsString().Value {
+		t.Errorf("expected X-Request-Id: %s, got %q", id.AsString().Value, header)
+	}
+
+	first := id.AsString().Value
+	serve(server, "GET", "/")
+	if id.AsString().Value == first {
+		t.Errorf("expected every request to get its own ID, got %s twice", first)
+	}
+
+	tests := []struct {
+		given string
+		kept  bool
+	}{
+		{"req-42", true},
+		{"has spaces", false},
+		{strings.Repeat("x", 200), false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Request-Id", tt.given)
+		serveRequest(server, r)
+		if kept := id.AsString().Value == tt.given; kept != tt.kept {
+			t.Errorf("%q: expected it to be kept: %v, got %s", tt.given, tt.kept, id.Inspect())
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	log := captureLog(t)
+
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "post", object.NewString("/users"), builtin(func(args ...object.Value) object.Value {
+		call(t, args[0], "status", object.NewInt(201))
+		return call(t, args[0], "send", object.NewString("created"))
+	}))
+
+	ctx := object.NewCallContext(0, 0)
+	accessLog, err := accessLogger(ctx, object.NewBool(true))
+	mustSucceed(t, err)
+
+	h := &handler{ctx: ctx, router: mustRouter(t, server), accessLog: accessLog}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/users?page=1", nil))
+
+	var entry map[string]any
+	if err := gojson.Unmarshal(log.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON line, got %q (%v)", log.String(), err)
+	}
+	expected := map[string]any{"method": "POST", "path": "/users", "status": 201.0, "id": w.Header().Get("X-Request-Id")}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, entry[key])
+		}
+	}
+	if _, ok := entry["latency_ms"].(float64); !ok {
+		t.Errorf("expected a latency, got %v", entry["latency_ms"])
+	}
+
+	// Functions get every entry instead
+	var entries []object.Value
+	accessLog, err = accessLogger(ctx, builtin(func(args ...object.Value) object.Value {
+		entries = append(entries, args[0])
+		return object.NewNull()
+	}))
+	mustSucceed(t, err)
+
+	h.accessLog = accessLog
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	if len(entries) != 1 || get(t, entries[0], "status").AsInt() != 404 || get(t, entries[0], "path").AsString().Value != "/missing" {
+		t.Errorf("expected an entry for GET /missing, got %v", entries)
+	}
+
+	if _, err := accessLogger(ctx, object.NewString("yes")); !err.IsError() {
+		t.Errorf("expected an error for a string, got %s", err.Inspect())
+	}
+}
@@ -8,29 +8,36 @@ import (
 	"github.com/radeqq007/sunbird/internal/object"
 	"io"
 	"net/http"
-	"strings"
 )
 
 type request struct {
 	r             *http.Request
+	w             *statusWriter
+	limited       *limitedBody
+	id            string
 	bodyCache     *string
 	bodyJSONCache object.Value
+	multipartErr  error
 }
 
-func newRequest(r *http.Request) object.Value {
-	req := &request{r: r}
+func newRequest(r *http.Request, w *statusWriter, limited *limitedBody, id string) object.Value {
+	req := &request{r: r, w: w, limited: limited, id: id, bodyJSONCache: object.NewNull()}
 
 	return modbuilder.NewHashBuilder().
 		AddFunction("path_param", req.pathParam).
 		AddFunction("query_param", req.queryParam).
 		AddFunction("body", req.body).
 		AddFunction("json", req.json).
+		AddFunction("form", req.form).
+		AddFunction("multipart", req.multipart).
 		AddFunction("method", req.method).
 		AddFunction("url", req.url).
 		AddFunction("header", req.header).
 		AddFunction("headers", req.headers).
 		AddFunction("cookie", req.cookie).
 		AddFunction("cookies", req.cookies).
+		AddFunction("id", req.requestID).
+		AddValue("context", object.NewHash(make(map[object.HashKey]object.HashPair))).
 		Build()
 }
 
@@ -74,19 +81,32 @@ func (req *request) body(ctx object.CallContext, args ...object.Value) object.Va
 		return err
 	}
 
+	body, err := req.readBody(ctx)
+	if err.IsError() {
+		return err
+	}
+
+	return object.NewString(body)
+}
+
+// readBody reads the whole body, once
+func (req *request) readBody(ctx object.CallContext) (string, object.Value) {
 	if req.bodyCache != nil {
-		return object.NewString(*req.bodyCache)
+		return *req.bodyCache, object.NewNull()
 	}
 
-	byteData, errGo := io.ReadAll(req.r.Body)
+	var byteData []byte
+	var errGo error
+	ctx.Runtime().Block(func() {
+		byteData, errGo = io.ReadAll(req.r.Body)
+	})
 	if errGo != nil {
-		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+		return "", req.bodyError(ctx, errGo)
 	}
-	defer req.r.Body.Close()
 
 	bodyString := string(byteData)
 	req.bodyCache = &bodyString
-	return object.NewString(bodyString)
+	return bodyString, object.NewNull()
 }
 
 func (req *request) json(ctx object.CallContext, args ...object.Value) object.Value {
@@ -99,18 +119,13 @@ func (req *request) json(ctx object.CallContext, args ...object.Value) object.Va
 		return req.bodyJSONCache
 	}
 
-	if req.bodyCache == nil {
-		byteData, errGo := io.ReadAll(req.r.Body)
-		if errGo != nil {
-			return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
-		}
-		defer req.r.Body.Close()
-		bodyString := string(byteData)
-		req.bodyCache = &bodyString
+	body, err := req.readBody(ctx)
+	if err.IsError() {
+		return err
 	}
 
 	var data any
-	errGo := gojson.Unmarshal([]byte(*req.bodyCache), &data)
+	errGo := gojson.Unmarshal([]byte(body), &data)
 	if errGo != nil {
 		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
 	}
@@ -162,16 +177,7 @@ func (req *request) headers(ctx object.CallContext, args ...object.Value) object
 		return err
 	}
 
-	pairs := make(map[object.HashKey]object.HashPair)
-	for key, values := range req.r.Header {
-		keyObj := object.NewString(key)
-		hashKey := keyObj.HashKey()
-
-		valueObj := object.NewString(strings.Join(values, ", "))
-		pairs[hashKey] = object.HashPair{Key: keyObj, Value: valueObj}
-	}
-
-	return object.NewHash(pairs)
+	return headerHash(req.r.Header)
 }
 
 func (req *request) cookie(ctx object.CallContext, args ...object.Value) object.Value {
@@ -208,3 +214,13 @@ func (req *request) cookies(ctx object.CallContext, args ...object.Value) object
 
 	return object.NewHash(pairs)
 }
+
+// requestID returns the ID of the request, sent back in the X-Request-Id header
+func (req *request) requestID(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+	if err.IsError() {
+		return err
+	}
+
+	return object.NewString(req.id)
+}
@@ -0,0 +1,628 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+// router sends requests to the handlers registered for their method and path.
+// Every server has its own, and routers made by create_router can be mounted
+// on a server or on each other.
+//
+// Routers are only changed and used by Sunbird code, which holds the runtime
+// lock, so they don't need a lock of their own.
+type router struct {
+	routes     []*route
+	mounts     []mount
+	middleware []object.Value
+
+	// Handlers for requests no route matches, Null for the default ones
+	notFound         object.Value
+	methodNotAllowed object.Value
+
+	// onError handles the errors of the routes and routers below, Null to leave them to the router above
+	onError object.Value
+
+	// maxBodyBytes overrides the body size limit of the server, -1 until set
+	maxBodyBytes int64
+}
+
+type route struct {
+	method   string
+	pattern  string
+	segments []segment
+	handler  object.Value
+
+	// dir is the directory served by routes made by serve_static, which have no handler
+	dir string
+
+	// websocket is set for routes made by ws, whose handler gets a connection
+	websocket bool
+}
+
+// segment is a part of a route pattern between slashes
+type segment struct {
+	literal string
+	param   string // name of the parameter for {param} segments
+	rest    bool   // whether the parameter captures the rest of the path, {param...}
+}
+
+type segmentKind int
+
+// Kinds of segments, from the most specific to the least
+const (
+	literalSegment segmentKind = iota
+	paramSegment
+	restSegment
+)
+
+func (s segment) kind() segmentKind {
+	switch {
+	case s.rest:
+		return restSegment
+	case s.param != "":
+		return paramSegment
+	default:
+		return literalSegment
+	}
+}
+
+type mount struct {
+	prefix string
+	router *router
+}
+
+func newRouter() *router {
+	return &router{notFound: object.NewNull(), methodNotAllowed: object.NewNull(), onError: object.NewNull(), maxBodyBytes: -1}
+}
+
+func createRouter(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+	if err.IsError() {
+		return err
+	}
+
+	return newRouter().hash(modbuilder.NewHashBuilder())
+}
+
+// hash adds the methods of the router to hb and builds it
+func (rt *router) hash(hb *modbuilder.HashBuilder) object.Value {
+	for _, method := range []string{
+		http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch,
+		http.MethodHead, http.MethodOptions, http.MethodConnect, http.MethodTrace,
+	} {
+		hb.AddFunction(strings.ToLower(method), func(ctx object.CallContext, args ...object.Value) object.Value {
+			return rt.addRoute(ctx, method, args...)
+		})
+	}
+
+	value := hb.
+		AddFunction("use", rt.use).
+		AddFunction("mount", rt.mount).
+		AddFunction("serve_static", rt.serveStatic).
+		AddFunction("ws", rt.ws).
+		AddFunction("not_found", rt.setNotFound).
+		AddFunction("method_not_allowed", rt.setMethodNotAllowed).
+		AddFunction("on_error", rt.setOnError).
+		AddFunction("max_body_bytes", rt.setMaxBodyBytes).
+		Build()
+
+	value.AsHash().Native = rt
+	return value
+}
+
+// routerOf returns the router behind a router or server hash
+func routerOf(value object.Value) (*router, bool) {
+	rt, ok := value.AsHash().Native.(*router)
+	return rt, ok
+}
+
+func (rt *router) addRoute(ctx object.CallContext, method string, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 2, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.StringKind)
+	if err.IsError() {
+		return err
+	}
+
+	err = expectCallable(ctx, args[1])
+	if err.IsError() {
+		return err
+	}
+
+	pattern := args[0].AsString().Value
+	segments, errGo := parsePattern(pattern)
+	if errGo != nil {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+
+	return rt.add(ctx, &route{method: method, pattern: pattern, segments: segments, handler: args[1]})
+}
+
+// add adds r to the routes, unless another route has the same method and shape
+func (rt *router) add(ctx object.CallContext, r *route) object.Value {
+	for _, other := range rt.routes {
+		if other.method == r.method && sameShape(other.segments, r.segments) {
+			return errors.NewRuntimeError(ctx.Line, ctx.Col,
+				"%s %s conflicts with %s %s, registered before", r.method, r.pattern, other.method, other.pattern)
+		}
+	}
+
+	rt.routes = append(rt.routes, r)
+	return object.NewNull()
+}
+
+// expectCallable checks that a handler is a function, either from Sunbird or built in
+func expectCallable(ctx object.CallContext, handler object.Value) object.Value {
+	return errors.ExpectOneOfTypes(ctx.Line, ctx.Col, handler, object.FunctionKind, object.BuiltinKind)
+}
+
+// parsePattern splits a route pattern like /users/{id} into segments
+func parsePattern(pattern string) ([]segment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("invalid route %q: it has to start with /", pattern)
+	}
+
+	parts := strings.Split(pattern[1:], "/")
+	segments := make([]segment, len(parts))
+	seen := make(map[string]bool)
+
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			if strings.ContainsAny(part, "{}") {
+				return nil, fmt.Errorf("invalid route %q: {param} has to be a whole segment", pattern)
+			}
+			segments[i] = segment{literal: part}
+			continue
+		}
+
+		name := part[1 : len(part)-1]
+		rest := strings.HasSuffix(name, "...")
+		name = strings.TrimSuffix(name, "...")
+
+		switch {
+		case name == "" || strings.ContainsAny(name, "{}"):
+			return nil, fmt.Errorf("invalid route %q: bad parameter %s", pattern, part)
+		case seen[name]:
+			return nil, fmt.Errorf("invalid route %q: parameter %s appears twice", pattern, name)
+		case rest && i != len(parts)-1:
+			return nil, fmt.Errorf("invalid route %q: %s has to be the last segment", pattern, part)
+		}
+
+		seen[name] = true
+		segments[i] = segment{param: name, rest: rest}
+	}
+
+	return segments, nil
+}
+
+// sameShape reports whether two patterns match exactly the same paths
+func sameShape(a, b []segment) bool {
+	return slices.EqualFunc(a, b, func(x, y segment) bool {
+		return x.kind() == y.kind() && x.literal == y.literal
+	})
+}
+
+// moreSpecific reports whether the pattern a wins over b when both match a path.
+// At the first segment where they differ, literals win over {param} and {param} over {param...}.
+func moreSpecific(a, b []segment) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if ka, kb := a[i].kind(), b[i].kind(); ka != kb {
+			return ka < kb
+		}
+	}
+	return len(a) > len(b)
+}
+
+// match matches the escaped path, split at slashes, against the pattern,
+// returning the unescaped parameters
+func match(segments []segment, parts []string) (map[string]string, bool) {
+	params := make(map[string]string)
+
+	for i, seg := range segments {
+		if seg.rest {
+			value, err := url.PathUnescape(strings.Join(parts[i:], "/"))
+			params[seg.param] = value
+			return params, err == nil
+		}
+
+		if i >= len(parts) {
+			return nil, false
+		}
+
+		part, err := url.PathUnescape(parts[i])
+		if err != nil {
+			return nil, false
+		}
+
+		if seg.param == "" {
+			if part != seg.literal {
+				return nil, false
+			}
+			continue
+		}
+
+		if part == "" {
+			return nil, false
+		}
+		params[seg.param] = part
+	}
+
+	return params, len(parts) == len(segments)
+}
+
+// use adds middleware, which runs before the handler of every request the router
+// gets. It's called with the writer, the request and a next function, which runs
+// the rest of the chain. Not calling next ends the request there.
+func (rt *router) use(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = expectCallable(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
+
+	rt.middleware = append(rt.middleware, args[0])
+	return object.NewNull()
+}
+
+// mount sends every request under a prefix to another router,
+// which sees the path with the prefix removed
+func (rt *router) mount(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 2, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.StringKind)
+	if err.IsError() {
+		return err
+	}
+
+	err = errors.ExpectType(ctx.Line, ctx.Col, args[1], object.HashKind)
+	if err.IsError() {
+		return err
+	}
+
+	prefix := strings.TrimSuffix(args[0].AsString().Value, "/")
+	if !strings.HasPrefix(prefix, "/") {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "invalid prefix %q: it has to start with /", args[0].AsString().Value)
+	}
+
+	sub, ok := routerOf(args[1])
+	if !ok {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "mount expects a router from http.create_router or http.create_server")
+	}
+
+	if sub.contains(rt) {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "can't mount a router inside itself")
+	}
+
+	for _, m := range rt.mounts {
+		if m.prefix == prefix {
+			return errors.NewRuntimeError(ctx.Line, ctx.Col, "a router is mounted at %s already", prefix)
+		}
+	}
+
+	rt.mounts = append(rt.mounts, mount{prefix: prefix, router: sub})
+
+	// Longer prefixes are more specific, so they're tried first
+	slices.SortStableFunc(rt.mounts, func(a, b mount) int { return len(b.prefix) - len(a.prefix) })
+	return object.NewNull()
+}
+
+// contains reports whether other is rt or mounted somewhere inside it
+func (rt *router) contains(other *router) bool {
+	if rt == other {
+		return true
+	}
+	return slices.ContainsFunc(rt.mounts, func(m mount) bool { return m.router.contains(other) })
+}
+
+func (rt *router) setNotFound(ctx object.CallContext, args ...object.Value) object.Value {
+	return setFallback(ctx, &rt.notFound, args...)
+}
+
+func (rt *router) setMethodNotAllowed(ctx object.CallContext, args ...object.Value) object.Value {
+	return setFallback(ctx, &rt.methodNotAllowed, args...)
+}
+
+func (rt *router) setOnError(ctx object.CallContext, args ...object.Value) object.Value {
+	return setFallback(ctx, &rt.onError, args...)
+}
+
+func setFallback(ctx object.CallContext, handler *object.Value, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = expectCallable(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
+
+	*handler = args[0]
+	return object.NewNull()
+}
+
+// exchange is a request being served, along with the writer and request hashes
+// every handler of it gets
+type exchange struct {
+	ctx object.CallContext
+	w   *statusWriter
+	r   *http.Request
+	res object.Value
+	req object.Value
+
+	id   string
+	body *limitedBody
+}
+
+// fallbacks are the not found and method not allowed handlers in effect,
+// which mounted routers inherit unless they have their own
+type fallbacks struct {
+	notFound         object.Value
+	methodNotAllowed object.Value
+}
+
+// serve runs the middleware of the router and then the handler for path,
+// the escaped path of the request below the prefix the router is mounted at
+func (rt *router) serve(ex *exchange, path string, inherited fallbacks) object.Value {
+	if !rt.notFound.IsNull() {
+		inherited.notFound = rt.notFound
+	}
+	if !rt.methodNotAllowed.IsNull() {
+		inherited.methodNotAllowed = rt.methodNotAllowed
+	}
+	if rt.maxBodyBytes >= 0 {
+		ex.body.limit = rt.maxBodyBytes
+	}
+
+	var next func(i int) object.Value
+	next = func(i int) object.Value {
+		if i == len(rt.middleware) {
+			return rt.dispatch(ex, path, inherited)
+		}
+
+		called := false
+		nextFn := object.NewBuiltin(func(ctx object.CallContext, args ...object.Value) object.Value {
+			err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+			if err.IsError() {
+				return err
+			}
+			if called {
+				return errors.NewRuntimeError(ctx.Line, ctx.Col, "next was called twice")
+			}
+			called = true
+			return next(i + 1)
+		})
+
+		return object.ApplyFunction(ex.ctx, rt.middleware[i], []object.Value{ex.res, ex.req, nextFn})
+	}
+
+	result := next(0)
+	if result.IsError() && !rt.onError.IsNull() {
+		return ex.handleError(rt.onError, result)
+	}
+	return result
+}
+
+// dispatch finds the handler for path, after the middleware has run
+func (rt *router) dispatch(ex *exchange, path string, inherited fallbacks) object.Value {
+	for _, m := range rt.mounts {
+		if path == m.prefix || strings.HasPrefix(path, m.prefix+"/") {
+			rest := strings.TrimPrefix(path, m.prefix)
+			if rest == "" {
+				rest = "/"
+			}
+			return m.router.serve(ex, rest, inherited)
+		}
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	// The most specific route wins, then the one registered first
+	var matched []*route
+	var matchedParams []map[string]string
+	for _, candidate := range rt.routes {
+		params, ok := match(candidate.segments, parts)
+		if !ok {
+			continue
+		}
+
+		i := 0
+		for i < len(matched) && !moreSpecific(candidate.segments, matched[i].segments) {
+			i++
+		}
+		matched = slices.Insert(matched, i, candidate)
+		matchedParams = slices.Insert(matchedParams, i, params)
+	}
+
+	if len(matched) == 0 {
+		return ex.fallback(inherited.notFound, http.StatusNotFound)
+	}
+
+	method := ex.r.Method
+	for _, try := range []string{method, http.MethodGet} {
+		for i, candidate := range matched {
+			if candidate.method != try {
+				continue
+			}
+
+			if ex.body.declaredTooLarge(ex.r) {
+				rejectBody(ex.w)
+				return object.NewNull()
+			}
+
+			if candidate.dir != "" {
+				return ex.serveStatic(candidate.dir, matchedParams[i][staticParam], inherited)
+			}
+
+			for name, value := range matchedParams[i] {
+				ex.r.SetPathValue(name, value)
+			}
+			if candidate.websocket {
+				return ex.serveWebSocket(candidate.handler)
+			}
+			return ex.call(candidate.handler)
+		}
+
+		// HEAD requests are answered by GET handlers if there are no HEAD ones
+		if method != http.MethodHead {
+			break
+		}
+	}
+
+	var allowed []string
+	for _, candidate := range matched {
+		allowed = append(allowed, candidate.method)
+		if candidate.method == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	slices.Sort(allowed)
+	ex.w.Header().Set("Allow", strings.Join(slices.Compact(allowed), ", "))
+
+	return ex.fallback(inherited.methodNotAllowed, http.StatusMethodNotAllowed)
+}
+
+func (ex *exchange) call(handler object.Value) object.Value {
+	return object.ApplyFunction(ex.ctx, handler, []object.Value{ex.res, ex.req})
+}
+
+// fallback answers with status, using handler if there's one
+func (ex *exchange) fallback(handler object.Value, status int) object.Value {
+	if handler.IsNull() {
+		http.Error(ex.w, fmt.Sprintf("%d %s", status, strings.ToLower(http.StatusText(status))), status)
+		return object.NewNull()
+	}
+
+	ex.w.defaultStatus = status
+	return ex.call(handler)
+}
+
+// handleError calls handler with the error a handler or middleware failed with.
+// The status is 500, unless the handler sets another one.
+func (ex *exchange) handleError(handler object.Value, err object.Value) object.Value {
+	ex.w.defaultStatus = http.StatusInternalServerError
+
+	caught := object.NewError(err.AsError().Message, err.AsError().Line, err.AsError().Col, false)
+	result := object.ApplyFunction(ex.ctx, handler, []object.Value{ex.res, ex.req, caught})
+	if result.IsError() {
+		return result
+	}
+	return object.NewNull()
+}
+
+// fail answers 500 for an error no on_error handler took care of, and logs it
+func (ex *exchange) fail(err object.Value) {
+	if ex.w.status == 0 {
+		http.Error(ex.w, "500 internal server error", http.StatusInternalServerError)
+	}
+
+	// Bodies over the limit have been answered with 413 already
+	if ex.body.exceeded {
+		return
+	}
+	fmt.Fprintf(logOutput, "http: error serving %s %s (request %s): %s\n", ex.r.Method, ex.r.URL.Path, ex.id, err.Inspect())
+}
+
+// handler serves the routes of a server on behalf of the script that created it
+type handler struct {
+	ctx    object.CallContext
+	router *router
+
+	// maxBodyBytes is the size of the largest request body, 0 for no limit
+	maxBodyBytes int64
+
+	// accessLog gets an entry for every request served, if it's set
+	accessLog func(entry accessEntry)
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if object.ApplyFunction == nil {
+		return
+	}
+
+	// Handlers run on their own goroutines, so they have to wait for their turn on the event loop
+	rt := h.ctx.Runtime()
+	rt.Acquire()
+	defer rt.Release()
+
+	// Every request gets the whole step budget, rather than what earlier
+	// requests left of it, and isn't failed by the error one of them hit
+	rt.Begin(rt.Context())
+
+	start := time.Now()
+	id := requestID(r)
+
+	sw := &statusWriter{ResponseWriter: w, defaultStatus: http.StatusOK}
+	sw.Header().Set(requestIDHeader, id)
+
+	body := &limitedBody{ReadCloser: r.Body, limit: h.maxBodyBytes}
+	r.Body = body
+	ex := &exchange{ctx: h.ctx, w: sw, r: r, res: newWriter(sw, r), req: newRequest(r, sw, body, id), id: id, body: body}
+
+	result := h.router.serve(ex, r.URL.EscapedPath(), fallbacks{notFound: object.NewNull(), methodNotAllowed: object.NewNull()})
+	if result.IsError() {
+		ex.fail(result)
+	}
+
+	if sw.status == 0 {
+		sw.WriteHeader(sw.defaultStatus)
+	}
+
+	if h.accessLog != nil {
+		h.accessLog(accessEntry{
+			Time:    start,
+			ID:      id,
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Status:  sw.status,
+			Latency: time.Since(start),
+		})
+	}
+}
+
+// statusWriter keeps track of the status of the response. Responses written
+// without setting one get defaultStatus, e.g. 404 for not found handlers.
+type statusWriter struct {
+	http.ResponseWriter
+	status        int // 0 until the header is written
+	defaultStatus int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(w.defaultStatus)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
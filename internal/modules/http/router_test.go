@@ -0,0 +1,290 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+func init() {
+	// The evaluator isn't part of these tests, so handlers are builtins
+	if object.ApplyFunction == nil {
+		object.ApplyFunction = func(ctx object.CallContext, fn object.Value, args []object.Value) object.Value {
+			return fn.AsBuiltin().Fn(ctx, args...)
+		}
+	}
+}
+
+// get looks up a key of a hash made by the module
+func get(t *testing.T, hash object.Value, key string) object.Value {
+	t.Helper()
+
+	pair, ok := hash.AsHash().Pairs[object.NewString(key).HashKey()]
+	if !ok {
+		t.Fatalf("no %s in %s", key, hash.Inspect())
+	}
+	return pair.Value
+}
+
+// call calls the function under key in a hash made by the module
+func call(t *testing.T, hash object.Value, key string, args ...object.Value) object.Value {
+	t.Helper()
+	return get(t, hash, key).AsBuiltin().Fn(object.NewCallContext(0, 0), args...)
+}
+
+// mustCall is like call, failing the test if the function returns an error
+func mustCall(t *testing.T, hash object.Value, key string, args ...object.Value) object.Value {
+	t.Helper()
+
+	result := call(t, hash, key, args...)
+	if result.IsError() {
+		t.Fatalf("%s: %s", key, result.Inspect())
+	}
+	return result
+}
+
+// mustRouter returns the router behind a server or router hash
+func mustRouter(t *testing.T, value object.Value) *router {
+	t.Helper()
+
+	rt, ok := routerOf(value)
+	if !ok {
+		t.Fatalf("no router behind %s", value.Inspect())
+	}
+	return rt
+}
+
+func builtin(fn func(args ...object.Value) object.Value) object.Value {
+	return object.NewBuiltin(func(ctx object.CallContext, args ...object.Value) object.Value {
+		return fn(args...)
+	})
+}
+
+// reply is a handler sending text, followed by the path parameters named in params
+func reply(t *testing.T, text string, params ...string) object.Value {
+	return builtin(func(args ...object.Value) object.Value {
+		body := text
+		for _, name := range params {
+			body += " " + call(t, args[1], "path_param", object.NewString(name)).AsString().Value
+		}
+		return call(t, args[0], "send", object.NewString(body))
+	})
+}
+
+// serve sends a request to the router of server
+func serve(server object.Value, method, target string) *httptest.ResponseRecorder {
+	return serveRequest(server, httptest.NewRequest(method, target, nil))
+}
+
+func serveRequest(server object.Value, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	rt, _ := routerOf(server)
+	h := &handler{ctx: object.NewCallContext(0, 0), router: rt}
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func expectResponse(t *testing.T, w *httptest.ResponseRecorder, status int, body string) {
+	t.Helper()
+
+	if w.Code != status || strings.TrimSpace(w.Body.String()) != body {
+		t.Errorf("expected %d %q, got %d %q", status, body, w.Code, w.Body.String())
+	}
+}
+
+func TestRouting(t *testing.T) {
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "get", object.NewString("/"), reply(t, "index"))
+	mustCall(t, server, "get", object.NewString("/users/{id}"), reply(t, "user", "id"))
+	mustCall(t, server, "post", object.NewString("/users/{id}"), reply(t, "update", "id"))
+	mustCall(t, server, "get", object.NewString("/users/me"), reply(t, "me"))
+	mustCall(t, server, "get", object.NewString("/users/{id}/posts/{post}"), reply(t, "post", "id", "post"))
+	mustCall(t, server, "get", object.NewString("/files/{path...}"), reply(t, "file", "path"))
+
+	// Servers don't share routes
+	other := createServer(object.NewCallContext(0, 0))
+	mustCall(t, other, "get", object.NewString("/"), reply(t, "other"))
+
+	tests := []struct {
+		method string
+		target string
+		status int
+		body   string
+	}{
+		{"GET", "/", 200, "index"},
+		{"GET", "/users/42", 200, "user 42"},
+		{"POST", "/users/42", 200, "update 42"},
+		{"GET", "/users/me", 200, "me"},
+		{"GET", "/users/a%2Fb", 200, "user a/b"},
+		{"GET", "/users/1/posts/2", 200, "post 1 2"},
+		{"GET", "/files/css/site.css", 200, "file css/site.css"},
+		{"HEAD", "/users/me", 200, "me"}, // the server drops the body, the recorder doesn't
+		{"GET", "/users", 404, "404 not found"},
+		{"GET", "/users/", 404, "404 not found"},
+		{"DELETE", "/users/42", 405, "405 method not allowed"},
+		{"POST", "/users/me", 200, "update me"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			expectResponse(t, serve(server, tt.method, tt.target), tt.status, tt.body)
+		})
+	}
+
+	if allow := serve(server, "DELETE", "/users/42").Header().Get("Allow"); allow != "GET, HEAD, POST" {
+		t.Errorf("expected Allow: GET, HEAD, POST, got %q", allow)
+	}
+
+	expectResponse(t, serve(other, "GET", "/"), 200, "other")
+	expectResponse(t, serve(other, "GET", "/users/me"), 404, "404 not found")
+}
+
+func TestRouteErrors(t *testing.T) {
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "get", object.NewString("/users/{id}"), reply(t, "user"))
+
+	tests := []struct {
+		method   string
+		pattern  string
+		expected string
+	}{
+		{"get", "/users/{name}", "conflicts with GET /users/{id}"},
+		{"get", "users", "has to start with /"},
+		{"get", "/users/{id", "has to be a whole segment"},
+		{"get", "/a/x{id}", "has to be a whole segment"},
+		{"get", "/{}", "bad parameter"},
+		{"get", "/{id}/{id}", "appears twice"},
+		{"get", "/{path...}/edit", "has to be the last segment"},
+	}
+
+	for _, tt := range tests {
+		result := call(t, server, tt.method, object.NewString(tt.pattern), reply(t, ""))
+		if !result.IsError() || !strings.Contains(result.AsError().Message, tt.expected) {
+			t.Errorf("%s: expected an error containing %q, got %s", tt.pattern, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var order []string
+	logger := func(name string) object.Value {
+		return builtin(func(args ...object.Value) object.Value {
+			order = append(order, name)
+			return args[2].AsBuiltin().Fn(object.NewCallContext(0, 0))
+		})
+	}
+
+	// Middleware that doesn't call next ends the request
+	auth := builtin(func(args ...object.Value) object.Value {
+		if call(t, args[1], "header", object.NewString("Authorization")).IsNull() {
+			call(t, args[0], "status", object.NewInt(http.StatusUnauthorized))
+			return call(t, args[0], "send", object.NewString("denied"))
+		}
+		return args[2].AsBuiltin().Fn(object.NewCallContext(0, 0))
+	})
+
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "use", logger("first"))
+	mustCall(t, server, "use", logger("second"))
+	mustCall(t, server, "use", auth)
+	mustCall(t, server, "get", object.NewString("/"), builtin(func(args ...object.Value) object.Value {
+		order = append(order, "handler")
+		return call(t, args[0], "send", object.NewString("ok"))
+	}))
+
+	expectResponse(t, serve(server, "GET", "/"), 401, "denied")
+	if strings.Join(order, ",") != "first,second" {
+		t.Errorf("expected first,second, got %v", order)
+	}
+
+	order = nil
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "secret")
+	expectResponse(t, serveRequest(server, r), 200, "ok")
+	if strings.Join(order, ",") != "first,second,handler" {
+		t.Errorf("expected first,second,handler, got %v", order)
+	}
+
+	// Custom fallbacks answer with their status unless they set another one
+	mustCall(t, server, "not_found", reply(t, "nothing here"))
+	r = httptest.NewRequest("GET", "/missing", nil)
+	r.Header.Set("Authorization", "secret")
+	expectResponse(t, serveRequest(server, r), 404, "nothing here")
+}
+
+func TestMount(t *testing.T) {
+	var seen []string
+	track := func(name string) object.Value {
+		return builtin(func(args ...object.Value) object.Value {
+			seen = append(seen, name)
+			return args[2].AsBuiltin().Fn(object.NewCallContext(0, 0))
+		})
+	}
+
+	users := createRouter(object.NewCallContext(0, 0))
+	mustCall(t, users, "use", track("users"))
+	mustCall(t, users, "get", object.NewString("/"), reply(t, "list"))
+	mustCall(t, users, "get", object.NewString("/{id}"), reply(t, "user", "id"))
+
+	api := createRouter(object.NewCallContext(0, 0))
+	mustCall(t, api, "mount", object.NewString("/users/"), users)
+	mustCall(t, api, "not_found", reply(t, "no such endpoint"))
+
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "use", track("server"))
+	mustCall(t, server, "mount", object.NewString("/api"), api)
+	mustCall(t, server, "get", object.NewString("/apis"), reply(t, "not the api"))
+
+	expectResponse(t, serve(server, "GET", "/api/users"), 200, "list")
+	expectResponse(t, serve(server, "GET", "/api/users/7"), 200, "user 7")
+	expectResponse(t, serve(server, "GET", "/apis"), 200, "not the api")
+
+	// Mounted routers use the fallbacks of the routers they're mounted on
+	expectResponse(t, serve(server, "GET", "/api/users/7/x"), 404, "no such endpoint")
+	expectResponse(t, serve(server, "GET", "/api/other"), 404, "no such endpoint")
+	expectResponse(t, serve(server, "POST", "/api/users"), 405, "405 method not allowed")
+
+	seen = nil
+	serve(server, "GET", "/api/users/7")
+	if strings.Join(seen, ",") != "server,users" {
+		t.Errorf("expected the middleware of server, then users, got %v", seen)
+	}
+
+	if result := call(t, users, "mount", object.NewString("/loop"), server); !result.IsError() {
+		t.Errorf("expected an error mounting a router inside itself, got %s", result.Inspect())
+	}
+	if result := call(t, server, "mount", object.NewString("/x"), object.NewHash(nil)); !result.IsError() {
+		t.Errorf("expected an error mounting a hash that isn't a router, got %s", result.Inspect())
+	}
+}
+
+func TestStepBudgetPerRequest(t *testing.T) {
+	captureLog(t)
+
+	env := object.NewEnvironment()
+	env.Runtime().SetLimits(object.Limits{MaxSteps: 5})
+
+	// Every request takes 3 steps, so two of them would use up the budget
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "get", object.NewString("/"), object.NewBuiltin(func(ctx object.CallContext, args ...object.Value) object.Value {
+		for range 3 {
+			if err := ctx.Runtime().Step(); err != nil {
+				return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", err.Error())
+			}
+		}
+		return call(t, args[0], "send", object.NewString("ok"))
+	}))
+
+	h := &handler{ctx: object.CallContext{Env: env}, router: mustRouter(t, server)}
+	for i := range 4 {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != 200 || w.Body.String() != "ok" {
+			t.Fatalf("request %d: expected 200 \"ok\", got %d %q", i+1, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	goerrors "errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+// defaultShutdownTimeout is how long a server waits for the requests in flight
+// when it's shut down, unless listen is given a shutdown_timeout
+const defaultShutdownTimeout = 10 * time.Second
+
+// server is a server started by listen
+type server struct {
+	srv             *http.Server
+	ln              net.Listener
+	tls             bool
+	shutdownTimeout time.Duration
+	maxBodyBytes    int64
+	accessLog       func(entry accessEntry)
+
+	once    sync.Once
+	stopped chan struct{} // closed once the server has stopped
+	err     error
+}
+
+// listen starts serving the requests to the port given by the arguments with
+// the router rt, and returns a handle to stop the server with. The server is a
+// task of the runtime, so the run lasts until it's stopped. Serving stops with
+// the run, and the server shuts down when the process is interrupted.
+//
+// listen takes a port, a hash of options or both. The options are host, port,
+// tls_cert, tls_key, read_timeout, read_header_timeout, write_timeout,
+// idle_timeout, max_header_bytes, max_body_bytes, shutdown_timeout and access_log.
+func listen(ctx object.CallContext, rt *router, args ...object.Value) object.Value {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "expected 1 or 2 arguments, got %d", len(args))
+	}
+
+	err := errors.ExpectOneOfTypes(ctx.Line, ctx.Col, args[0], object.IntKind, object.HashKind)
+	if err.IsError() {
+		return err
+	}
+
+	port, options := args[0], optionsArg(args, 1)
+	if port.IsHash() {
+		if len(args) == 2 {
+			return errors.NewArgumentError(ctx.Line, ctx.Col, "expected the options after the port")
+		}
+		options = port
+
+		var ok bool
+		if port, ok = option(options, "port"); !ok {
+			return errors.NewArgumentError(ctx.Line, ctx.Col, "listen expects a port")
+		}
+	}
+
+	if err := errors.ExpectType(ctx.Line, ctx.Col, port, object.IntKind); err.IsError() {
+		return err
+	}
+	if port.AsInt() < 0 || port.AsInt() > 65535 {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "invalid port %d", port.AsInt())
+	}
+
+	if !options.IsNull() {
+		if err := errors.ExpectType(ctx.Line, ctx.Col, options, object.HashKind); err.IsError() {
+			return err
+		}
+	}
+
+	if errGo := ctx.Runtime().Policy().CheckNet(); errGo != nil {
+		return errors.NewPermissionError(ctx.Line, ctx.Col, errGo)
+	}
+
+	s, err := newServer(ctx, options)
+	if err.IsError() {
+		return err
+	}
+	s.srv.Handler = &handler{ctx: ctx, router: rt, maxBodyBytes: s.maxBodyBytes, accessLog: s.accessLog}
+
+	host := ""
+	if value, ok := option(options, "host"); ok {
+		if err := errors.ExpectType(ctx.Line, ctx.Col, value, object.StringKind); err.IsError() {
+			return err
+		}
+		host = value.AsString().Value
+	}
+
+	ln, errGo := net.Listen("tcp", net.JoinHostPort(host, strconv.FormatInt(port.AsInt(), 10)))
+	if errGo != nil {
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+	s.ln = ln
+
+	s.start(ctx.Runtime())
+	return s.hash()
+}
+
+// newServer makes a server following the TLS, timeout, size and logging options
+func newServer(ctx object.CallContext, options object.Value) (*server, object.Value) {
+	s := &server{
+		srv:             &http.Server{},
+		shutdownTimeout: defaultShutdownTimeout,
+		maxBodyBytes:    defaultMaxBodyBytes,
+		stopped:         make(chan struct{}),
+	}
+
+	durations := []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"read_timeout", &s.srv.ReadTimeout},
+		{"read_header_timeout", &s.srv.ReadHeaderTimeout},
+		{"write_timeout", &s.srv.WriteTimeout},
+		{"idle_timeout", &s.srv.IdleTimeout},
+		{"shutdown_timeout", &s.shutdownTimeout},
+	}
+
+	for _, d := range durations {
+		if value, ok := option(options, d.name); ok {
+			duration, err := seconds(ctx, value)
+			if err.IsError() {
+				return nil, err
+			}
+			*d.target = duration
+		}
+	}
+
+	if value, ok := option(options, "max_header_bytes"); ok {
+		if err := errors.ExpectType(ctx.Line, ctx.Col, value, object.IntKind); err.IsError() {
+			return nil, err
+		}
+		s.srv.MaxHeaderBytes = int(value.AsInt())
+	}
+
+	if value, ok := option(options, "max_body_bytes"); ok {
+		if err := errors.ExpectType(ctx.Line, ctx.Col, value, object.IntKind); err.IsError() {
+			return nil, err
+		}
+		if value.AsInt() < 0 {
+			return nil, errors.NewArgumentError(ctx.Line, ctx.Col, "invalid max_body_bytes %d", value.AsInt())
+		}
+		s.maxBodyBytes = value.AsInt()
+	}
+
+	if value, ok := option(options, "access_log"); ok {
+		accessLog, err := accessLogger(ctx, value)
+		if err.IsError() {
+			return nil, err
+		}
+		s.accessLog = accessLog
+	}
+
+	certValue, hasCert := option(options, "tls_cert")
+	keyValue, hasKey := option(options, "tls_key")
+	if hasCert != hasKey {
+		return nil, errors.NewArgumentError(ctx.Line, ctx.Col, "tls_cert and tls_key have to be given together")
+	}
+
+	if hasCert {
+		cert, err := readOptionFile(ctx, certValue)
+		if err.IsError() {
+			return nil, err
+		}
+		key, err := readOptionFile(ctx, keyValue)
+		if err.IsError() {
+			return nil, err
+		}
+
+		pair, errGo := tls.X509KeyPair(cert, key)
+		if errGo != nil {
+			return nil, errors.NewArgumentError(ctx.Line, ctx.Col, "tls_cert: %s", errGo.Error())
+		}
+
+		s.srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{pair}}
+		s.tls = true
+	}
+
+	return s, object.NewNull()
+}
+
+// start serves in a task of rt until the server stops, and stops the server
+// when the run ends or the process is interrupted
+func (s *server) start(rt *object.Runtime) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := rt.Context().Done()
+	go func() {
+		defer signal.Stop(signals)
+
+		select {
+		case <-signals:
+			s.shutdown(s.shutdownTimeout)
+		case <-done:
+			s.close()
+		case <-s.stopped:
+		}
+	}()
+
+	rt.Go(func() {
+		rt.Block(func() {
+			var errGo error
+			if s.tls {
+				errGo = s.srv.ServeTLS(s.ln, "", "")
+			} else {
+				errGo = s.srv.Serve(s.ln)
+			}
+
+			// Serve returns as soon as the server starts shutting down,
+			// but the task lasts until the requests in flight are done
+			if !goerrors.Is(errGo, http.ErrServerClosed) {
+				s.close()
+			}
+			<-s.stopped
+		})
+	})
+}
+
+// shutdown stops accepting connections and waits up to timeout for the requests
+// in flight to finish before closing the connections left. If the server is
+// stopping already, it waits for that instead.
+func (s *server) shutdown(timeout time.Duration) error {
+	s.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		if s.srv.Shutdown(ctx) != nil {
+			s.srv.Close()
+			s.err = fmt.Errorf("requests still running after %s were cut off", timeout)
+		}
+		close(s.stopped)
+	})
+
+	<-s.stopped
+	return s.err
+}
+
+// close stops the server right away, cutting off the requests in flight
+func (s *server) close() {
+	// Closing the server also ends a shutdown in progress
+	s.srv.Close()
+	s.once.Do(func() {
+		close(s.stopped)
+	})
+	<-s.stopped
+}
+
+// hash returns the handle listen gives scripts
+func (s *server) hash() object.Value {
+	addr := s.ln.Addr().(*net.TCPAddr)
+
+	return modbuilder.NewHashBuilder().
+		AddString("host", addr.IP.String()).
+		AddInteger("port", int64(addr.Port)).
+		AddFunction("close", func(ctx object.CallContext, args ...object.Value) object.Value {
+			err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+			if err.IsError() {
+				return err
+			}
+
+			ctx.Runtime().Block(s.close)
+			return object.NewNull()
+		}).
+		AddFunction("shutdown", func(ctx object.CallContext, args ...object.Value) object.Value {
+			if len(args) > 1 {
+				return errors.NewArgumentError(ctx.Line, ctx.Col, "expected 0 or 1 arguments, got %d", len(args))
+			}
+
+			timeout := s.shutdownTimeout
+			if len(args) == 1 {
+				var err object.Value
+				if timeout, err = seconds(ctx, args[0]); err.IsError() {
+					return err
+				}
+			}
+
+			// Handlers can shut their server down too, which only works if they
+			// return before the shutdown is over, so it settles a promise
+			result := object.NewPromise()
+			go func() {
+				if errGo := s.shutdown(timeout); errGo != nil {
+					result.AsPromise().Settle(errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error()))
+					return
+				}
+				result.AsPromise().Settle(object.NewNull())
+			}()
+			return result
+		}).
+		Build()
+}
@@ -0,0 +1,289 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+// callLocked calls the function under key like a script does, holding the runtime lock
+func callLocked(t *testing.T, ctx object.CallContext, hash object.Value, key string, args ...object.Value) object.Value {
+	t.Helper()
+
+	ctx.Runtime().Acquire()
+	defer ctx.Runtime().Release()
+	return get(t, hash, key).AsBuiltin().Fn(ctx, args...)
+}
+
+// startServer makes a server with the routes of routes and starts it with the
+// options in options, on a free port of the loopback interface
+func startServer(t *testing.T, ctx object.CallContext, routes map[string]object.Value, options map[string]object.Value) (object.Value, string) {
+	t.Helper()
+
+	server := createServer(ctx)
+	for path, handler := range routes {
+		mustCall(t, server, "get", object.NewString(path), handler)
+	}
+
+	if options == nil {
+		options = map[string]object.Value{}
+	}
+	options["host"] = object.NewString("127.0.0.1")
+	options["port"] = object.NewInt(0)
+
+	handle := mustSucceed(t, callLocked(t, ctx, server, "listen", hashOf(options)))
+	t.Cleanup(func() { callLocked(t, ctx, handle, "close") })
+
+	return handle, fmt.Sprintf("127.0.0.1:%d", get(t, handle, "port").AsInt())
+}
+
+// waitForTasks fails the test unless every task of the runtime of ctx ends soon
+func waitForTasks(t *testing.T, ctx object.CallContext) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		ctx.Runtime().Acquire()
+		ctx.Runtime().Wait()
+		ctx.Runtime().Release()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server is still running")
+	}
+}
+
+// slowHandler is a handler waiting for release to be closed before answering,
+// closing started once it's running
+func slowHandler(t *testing.T, started, release chan struct{}) object.Value {
+	return object.NewBuiltin(func(ctx object.CallContext, args ...object.Value) object.Value {
+		close(started)
+		ctx.Runtime().Block(func() { <-release })
+		return call(t, args[0], "send", object.NewString("slow"))
+	})
+}
+
+func fetchText(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestListen(t *testing.T) {
+	ctx := object.CallContext{Env: object.NewEnvironment()}
+	handle, addr := startServer(t, ctx, map[string]object.Value{"/": reply(t, "index")}, nil)
+
+	if text, err := fetchText(http.DefaultClient, "http://"+addr+"/"); err != nil || text != "index" {
+		t.Fatalf("expected \"index\", got %q (%v)", text, err)
+	}
+
+	if host := get(t, handle, "host").AsString().Value; host != "127.0.0.1" {
+		t.Errorf("expected the server to listen on 127.0.0.1, got %s", host)
+	}
+
+	callLocked(t, ctx, handle, "close")
+	waitForTasks(t, ctx)
+
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("expected the server to stop listening")
+	}
+}
+
+func TestListenOptions(t *testing.T) {
+	ctx := object.CallContext{Env: object.NewEnvironment()}
+	_, addr := startServer(t, ctx, map[string]object.Value{"/": reply(t, "index")}, map[string]object.Value{
+		"max_header_bytes": object.NewInt(1),
+		"read_timeout":     object.NewFloat(0.5),
+	})
+
+	// Go allows some bytes more than max_header_bytes
+	r, _ := http.NewRequest("GET", "http://"+addr+"/", nil)
+	r.Header.Set("X-Large", strings.Repeat("a", 8<<10))
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("expected status 431, got %d", resp.StatusCode)
+	}
+
+	tests := []struct {
+		args     []object.Value
+		expected string
+	}{
+		{[]object.Value{object.NewInt(-1)}, "invalid port"},
+		{[]object.Value{hashOf(map[string]object.Value{"host": object.NewString("127.0.0.1")})}, "expects a port"},
+		{[]object.Value{object.NewInt(0), hashOf(map[string]object.Value{"tls_cert": object.NewString("cert.pem")})}, "have to be given together"},
+		{[]object.Value{object.NewInt(0), hashOf(map[string]object.Value{"write_timeout": object.NewString("1s")})}, "expected"},
+		{[]object.Value{hashOf(map[string]object.Value{"port": object.NewInt(0)}), object.NewNull()}, "expected the options after the port"},
+	}
+
+	server := createServer(ctx)
+	for _, tt := range tests {
+		result := callLocked(t, ctx, server, "listen", tt.args...)
+		if !result.IsError() || !strings.Contains(result.AsError().Message, tt.expected) {
+			t.Errorf("expected an error containing %q, got %s", tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestListenTLS(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, cert, key)
+
+	ctx := object.CallContext{Env: object.NewEnvironment()}
+	_, addr := startServer(t, ctx, map[string]object.Value{"/": reply(t, "secure")}, map[string]object.Value{
+		"tls_cert": object.NewString(cert),
+		"tls_key":  object.NewString(key),
+	})
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	if text, err := fetchText(client, "https://"+addr+"/"); err != nil || text != "secure" {
+		t.Errorf("expected \"secure\", got %q (%v)", text, err)
+	}
+
+	if text, _ := fetchText(http.DefaultClient, "http://"+addr+"/"); text == "secure" {
+		t.Error("expected requests without TLS to be turned down")
+	}
+}
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 and its key
+func writeCertificate(t *testing.T, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0o644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0o600)
+}
+
+func TestShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+
+	ctx := object.CallContext{Env: object.NewEnvironment()}
+	handle, addr := startServer(t, ctx, map[string]object.Value{"/slow": slowHandler(t, started, release)}, nil)
+
+	responses := make(chan string)
+	go func() {
+		text, _ := fetchText(http.DefaultClient, "http://"+addr+"/slow")
+		responses <- text
+	}()
+	<-started
+
+	promise := callLocked(t, ctx, handle, "shutdown", object.NewInt(5)).AsPromise()
+
+	// The server stops taking connections, but finishes the request in flight
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("expected the server to stop listening")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if promise.Settled() {
+		t.Fatal("expected the shutdown to wait for the request in flight")
+	}
+
+	close(release)
+	if text := <-responses; text != "slow" {
+		t.Errorf("expected the request in flight to be answered, got %q", text)
+	}
+
+	<-promise.Done()
+	if result := promise.Result(); result.IsError() {
+		t.Errorf("expected the shutdown to succeed, got %s", result.Inspect())
+	}
+	waitForTasks(t, ctx)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+
+	ctx := object.CallContext{Env: object.NewEnvironment()}
+	handle, addr := startServer(t, ctx, map[string]object.Value{"/slow": slowHandler(t, started, release)}, nil)
+
+	go fetchText(http.DefaultClient, "http://"+addr+"/slow")
+	<-started
+
+	promise := callLocked(t, ctx, handle, "shutdown", object.NewFloat(0.05)).AsPromise()
+	<-promise.Done()
+	if result := promise.Result(); !result.IsError() || !strings.Contains(result.AsError().Message, "cut off") {
+		t.Errorf("expected the shutdown to time out, got %s", result.Inspect())
+	}
+}
+
+func TestShutdownOnSignal(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+
+	ctx := object.CallContext{Env: object.NewEnvironment()}
+	_, addr := startServer(t, ctx, map[string]object.Value{"/slow": slowHandler(t, started, release)}, nil)
+
+	responses := make(chan string)
+	go func() {
+		text, _ := fetchText(http.DefaultClient, "http://"+addr+"/slow")
+		responses <- text
+	}()
+	<-started
+
+	process, _ := os.FindProcess(os.Getpid())
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		close(release)
+		t.Skipf("can't send signals here: %v", err)
+	}
+
+	close(release)
+	if text := <-responses; text != "slow" {
+		t.Errorf("expected the request in flight to be answered, got %q", text)
+	}
+	waitForTasks(t, ctx)
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/vfs"
+)
+
+// staticParam is the parameter holding the path of the file in serve_static routes
+const staticParam = "file"
+
+// serveStatic serves the files in a directory under a prefix, so that
+// serve_static("/assets", "public") serves public/app.js at /assets/app.js.
+// Directories are served by their index.html.
+func (rt *router) serveStatic(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 2, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.StringKind)
+	if err.IsError() {
+		return err
+	}
+
+	err = errors.ExpectType(ctx.Line, ctx.Col, args[1], object.StringKind)
+	if err.IsError() {
+		return err
+	}
+
+	prefix := args[0].AsString().Value
+	if !strings.HasPrefix(prefix, "/") || strings.ContainsAny(prefix, "{}") {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "invalid prefix %q: it has to start with / and can't have parameters", prefix)
+	}
+
+	dir := scriptPath(ctx, args[1].AsString().Value)
+	if errGo := ctx.Runtime().Policy().CheckRead(dir); errGo != nil {
+		return errors.NewPermissionError(ctx.Line, ctx.Col, errGo)
+	}
+
+	if info, errGo := vfs.Stat(dir); errGo != nil || !info.IsDir() {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "%s is not a directory", args[1].AsString().Value)
+	}
+
+	pattern := strings.TrimSuffix(prefix, "/") + "/{" + staticParam + "...}"
+	segments, errGo := parsePattern(pattern)
+	if errGo != nil {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+
+	return rt.add(ctx, &route{method: http.MethodGet, pattern: pattern, segments: segments, dir: dir})
+}
+
+// serveStatic answers with the file at name in dir, or with the not found
+// handler if there's none. The sandbox is checked on every request, on the
+// file links lead to, so links in dir can't reach files the script can't read.
+func (ex *exchange) serveStatic(dir, name string, inherited fallbacks) object.Value {
+	file := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+name)))
+	policy := ex.ctx.Runtime().Policy()
+
+	var f vfs.File
+	var info fs.FileInfo
+	var errGo, denied error
+	ex.ctx.Runtime().Block(func() {
+		info, errGo = vfs.Stat(file)
+		if errGo != nil {
+			return
+		}
+
+		if info.IsDir() {
+			// Like http.FileServer, make relative links in the index work
+			if !strings.HasSuffix(ex.r.URL.Path, "/") {
+				return
+			}
+
+			file = filepath.Join(file, "index.html")
+			if info, errGo = vfs.Stat(file); errGo != nil {
+				return
+			}
+		}
+
+		if denied = policy.CheckRead(file); denied != nil {
+			return
+		}
+
+		f, errGo = vfs.Open(file)
+	})
+
+	switch {
+	case denied != nil:
+		return ex.fallback(object.NewNull(), http.StatusForbidden)
+	case errGo != nil:
+		return ex.fallback(inherited.notFound, http.StatusNotFound)
+	case info.IsDir():
+		target := ex.r.URL.Path + "/"
+		if ex.r.URL.RawQuery != "" {
+			target += "?" + ex.r.URL.RawQuery
+		}
+		http.Redirect(ex.w, ex.r, target, http.StatusMovedPermanently)
+		return object.NewNull()
+	}
+
+	ex.ctx.Runtime().Block(func() {
+		defer f.Close()
+		serveContent(ex.w, ex.r, file, info, f)
+	})
+	return object.NewNull()
+}
+
+// serveContent answers with content, the file at name described by info,
+// taking care of conditional requests and ranges. Content types come from
+// the extension of name, or from the content itself.
+func serveContent(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo, content io.ReadSeeker) {
+	if w.Header().Get("ETag") == "" {
+		w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	}
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// scriptPath resolves path relative to the directory of the calling file
+func scriptPath(ctx object.CallContext, path string) string {
+	if !filepath.IsAbs(path) && ctx.Env != nil {
+		return filepath.Join(ctx.Env.Dir(), path)
+	}
+	return path
+}
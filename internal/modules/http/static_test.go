@@ -0,0 +1,262 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/sandbox"
+)
+
+// writeFiles writes files, by slash-separated path, to a new directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestServeStatic(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app.js":          "console.log(1)",
+		"index.html":      "<h1>home</h1>",
+		"docs/index.html": "<h1>docs</h1>",
+		"img/logo.txt":    "0123456789",
+	})
+	secret := writeFiles(t, map[string]string{"secret.txt": "secret"})
+
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "serve_static", object.NewString("/assets"), object.NewString(dir))
+	mustCall(t, server, "not_found", reply(t, "missing"))
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/assets/app.js", 200, "console.log(1)"},
+		{"/assets/", 200, "<h1>home</h1>"},
+		{"/assets/docs/", 200, "<h1>docs</h1>"},
+		{"/assets/img/logo.txt", 200, "0123456789"},
+		{"/assets/nothing.js", 404, "missing"},
+		{"/assets/img/", 404, "missing"},
+		{"/assets/%2e%2e/" + filepath.Base(secret) + "/secret.txt", 404, "missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			expectResponse(t, serve(server, "GET", tt.target), tt.status, tt.body)
+		})
+	}
+
+	w := serve(server, "GET", "/assets/app.js")
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/javascript") {
+		t.Errorf("expected a JavaScript content type, got %q", contentType)
+	}
+
+	w = serve(server, "GET", "/assets/docs?page=1")
+	if location := w.Header().Get("Location"); w.Code != 301 || location != "/assets/docs/?page=1" {
+		t.Errorf("expected a redirect to /assets/docs/?page=1, got %d %q", w.Code, location)
+	}
+
+	if result := call(t, server, "serve_static", object.NewString("/x"), object.NewString(filepath.Join(dir, "app.js"))); !result.IsError() {
+		t.Errorf("expected an error serving a file as a directory, got %s", result.Inspect())
+	}
+	if result := call(t, server, "serve_static", object.NewString("/{x}"), object.NewString(dir)); !result.IsError() {
+		t.Errorf("expected an error for a prefix with parameters, got %s", result.Inspect())
+	}
+}
+
+func TestServeStaticPolicy(t *testing.T) {
+	secret := writeFiles(t, map[string]string{"secret.txt": "secret"})
+	dir := writeFiles(t, map[string]string{"app.js": "console.log(1)"})
+	if err := os.Symlink(filepath.Join(secret, "secret.txt"), filepath.Join(dir, "leak.txt")); err != nil {
+		t.Skip("symlinks aren't supported: ", err)
+	}
+
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "serve_static", object.NewString("/"), object.NewString(dir))
+
+	// Without a sandbox, links are followed like any other file
+	expectResponse(t, serve(server, "GET", "/leak.txt"), 200, "secret")
+
+	policy := sandbox.New()
+	if err := policy.AllowRead(dir); err != nil {
+		t.Fatal(err)
+	}
+	env := object.NewEnvironment()
+	env.Runtime().SetPolicy(policy)
+
+	rt, _ := routerOf(server)
+	h := &handler{ctx: object.CallContext{Env: env}, router: rt}
+	serveSandboxed := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	expectResponse(t, serveSandboxed("/app.js"), 200, "console.log(1)")
+	expectResponse(t, serveSandboxed("/leak.txt"), 403, "403 forbidden")
+}
+
+func TestServeStaticConditional(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"logo.txt": "0123456789"})
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "logo.txt"), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "serve_static", object.NewString("/"), object.NewString(dir))
+
+	w := serve(server, "GET", "/logo.txt")
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+	if lastModified := w.Header().Get("Last-Modified"); lastModified != modified.Format(http.TimeFormat) {
+		t.Errorf("expected Last-Modified: %s, got %q", modified.Format(http.TimeFormat), lastModified)
+	}
+
+	r := httptest.NewRequest("GET", "/logo.txt", nil)
+	r.Header.Set("If-None-Match", etag)
+	expectResponse(t, serveRequest(server, r), 304, "")
+
+	r = httptest.NewRequest("GET", "/logo.txt", nil)
+	r.Header.Set("If-Modified-Since", modified.Add(time.Hour).Format(http.TimeFormat))
+	expectResponse(t, serveRequest(server, r), 304, "")
+
+	r = httptest.NewRequest("GET", "/logo.txt", nil)
+	r.Header.Set("If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat))
+	expectResponse(t, serveRequest(server, r), 200, "0123456789")
+
+	r = httptest.NewRequest("GET", "/logo.txt", nil)
+	r.Header.Set("Range", "bytes=2-5")
+	w = serveRequest(server, r)
+	expectResponse(t, w, 206, "2345")
+	if contentRange := w.Header().Get("Content-Range"); contentRange != "bytes 2-5/10" {
+		t.Errorf("expected Content-Range: bytes 2-5/10, got %q", contentRange)
+	}
+}
+
+func TestWriterFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"report.csv": "a,b\n1,2\n"})
+
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "get", object.NewString("/report"), builtin(func(args ...object.Value) object.Value {
+		return call(t, args[0], "file", object.NewString(filepath.Join(dir, "report.csv")))
+	}))
+
+	w := serve(server, "GET", "/report")
+	expectResponse(t, w, 200, "a,b\n1,2")
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/csv") {
+		t.Errorf("expected a CSV content type, got %q", contentType)
+	}
+
+	r := httptest.NewRequest("GET", "/report", nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	expectResponse(t, serveRequest(server, r), 304, "")
+
+	var result object.Value
+	mustCall(t, server, "get", object.NewString("/missing"), builtin(func(args ...object.Value) object.Value {
+		result = call(t, args[0], "file", object.NewString(filepath.Join(dir, "missing.csv")))
+		return result
+	}))
+	serve(server, "GET", "/missing")
+	if !result.IsError() {
+		t.Errorf("expected an error for a missing file, got %s", result.Inspect())
+	}
+}
+
+func TestWriterStreaming(t *testing.T) {
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "get", object.NewString("/events"), builtin(func(args ...object.Value) object.Value {
+		call(t, get(t, args[0], "header"), "set", object.NewString("Content-Type"), object.NewString("text/event-stream"))
+		for _, event := range []string{"one", "two"} {
+			mustCall(t, args[0], "write", object.NewString("data: "+event+"\n\n"))
+			mustCall(t, args[0], "flush")
+		}
+		return object.NewNull()
+	}))
+
+	w := serve(server, "GET", "/events")
+	if !w.Flushed || w.Body.String() != "data: one\n\ndata: two\n\n" {
+		t.Errorf("expected two flushed events, got %q (flushed: %v)", w.Body.String(), w.Flushed)
+	}
+}
+
+func TestWriterRedirect(t *testing.T) {
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "get", object.NewString("/old"), builtin(func(args ...object.Value) object.Value {
+		return call(t, args[0], "redirect", object.NewString("/new"))
+	}))
+	mustCall(t, server, "get", object.NewString("/moved"), builtin(func(args ...object.Value) object.Value {
+		return call(t, args[0], "redirect", object.NewString("https://example.com/"), object.NewInt(http.StatusMovedPermanently))
+	}))
+
+	var result object.Value
+	mustCall(t, server, "get", object.NewString("/bad"), builtin(func(args ...object.Value) object.Value {
+		result = call(t, args[0], "redirect", object.NewString("/new"), object.NewInt(http.StatusOK))
+		return result
+	}))
+
+	tests := []struct {
+		target   string
+		status   int
+		location string
+	}{
+		{"/old", 302, "/new"},
+		{"/moved", 301, "https://example.com/"},
+	}
+
+	for _, tt := range tests {
+		w := serve(server, "GET", tt.target)
+		if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s: expected %d to %s, got %d to %q", tt.target, tt.status, tt.location, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	serve(server, "GET", "/bad")
+	if !result.IsError() {
+		t.Errorf("expected an error for a status that isn't a redirect, got %s", result.Inspect())
+	}
+}
+
+func TestWriterJSON(t *testing.T) {
+	values := []struct {
+		value    object.Value
+		expected string
+	}{
+		{object.NewArray([]object.Value{object.NewInt(1), object.NewNull()}), "[1,null]"},
+		{object.NewString("hi"), `"hi"`},
+		{object.NewNull(), "null"},
+		{hashOf(map[string]object.Value{"a": object.NewNull()}), `{"a":null}`},
+	}
+
+	for _, tt := range values {
+		server := createServer(object.NewCallContext(0, 0))
+		mustCall(t, server, "get", object.NewString("/"), builtin(func(args ...object.Value) object.Value {
+			return call(t, args[0], "json", tt.value)
+		}))
+
+		w := serve(server, "GET", "/")
+		expectResponse(t, w, 200, tt.expected)
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("expected application/json, got %q", contentType)
+		}
+	}
+}
@@ -0,0 +1,701 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	gojson "encoding/json"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/modules/json"
+	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+// Opcodes of WebSocket frames, from RFC 6455
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Status codes of close frames
+const (
+	closeNormal        = 1000
+	closeProtocolError = 1002
+	closeNoStatus      = 1005
+	closeInvalidData   = 1007
+	closeTooBig        = 1009
+	closeInternalError = 1011
+)
+
+// wsGUID is appended to the key of a handshake to compute the accept key
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize bounds the size of the messages a connection takes
+const maxMessageSize = 16 << 20
+
+// closeTimeout is how long closing a connection waits for the other side to agree
+const closeTimeout = time.Second
+
+// errClosed is returned by reads and writes once the connection is closed
+var errClosed = goerrors.New("connection closed")
+
+// protocolError is a violation of the protocol by the other side,
+// which fails the connection with code
+type protocolError struct {
+	code    int
+	message string
+}
+
+func (e *protocolError) Error() string {
+	return "websocket: " + e.message
+}
+
+// wsConn is an open WebSocket connection, from either side
+type wsConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	client bool // clients mask the frames they send, servers don't
+
+	readMu sync.Mutex
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	closed atomic.Bool
+
+	// onMessage is the handler of on_message, Null until it's set.
+	// It's only used by Sunbird code, which holds the runtime lock.
+	onMessage object.Value
+
+	// logError logs an error the handler of on_message failed with
+	logError func(err object.Value)
+}
+
+func newWSConn(conn net.Conn, r *bufio.Reader, client bool) *wsConn {
+	return &wsConn{conn: conn, r: r, client: client, onMessage: object.NewNull(), logError: logMessageError}
+}
+
+func logMessageError(err object.Value) {
+	fmt.Fprintf(logOutput, "http: error handling a WebSocket message: %s\n", err.Inspect())
+}
+
+// acceptKey computes the Sec-WebSocket-Accept header answering key
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// readMessage reads the next text or binary message, answering pings and
+// close frames along the way. Once the connection is closed, it returns errClosed.
+func (c *wsConn) readMessage() (string, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	var message []byte
+	text, fragmented := false, false
+
+	for !c.closed.Load() {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			var perr *protocolError
+			if goerrors.As(err, &perr) {
+				c.fail(perr)
+				return "", err
+			}
+			c.shut()
+			return "", errClosed
+		}
+
+		switch op {
+		case opPing:
+			c.writeFrame(opPong, payload)
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := closeNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.writeClose(code, "")
+			c.shut()
+			return "", errClosed
+		case opText, opBinary:
+			if fragmented {
+				err = &protocolError{closeProtocolError, "message started before the last one ended"}
+			}
+			message, text = payload, op == opText
+		case opContinuation:
+			if !fragmented {
+				err = &protocolError{closeProtocolError, "continuation without a message"}
+			}
+			message = append(message, payload...)
+		default:
+			err = &protocolError{closeProtocolError, fmt.Sprintf("unknown opcode %d", op)}
+		}
+
+		if err == nil && len(message) > maxMessageSize {
+			err = &protocolError{closeTooBig, "message too big"}
+		}
+		if err == nil && fin && text && !utf8.Valid(message) {
+			err = &protocolError{closeInvalidData, "text message isn't valid UTF-8"}
+		}
+		if err != nil {
+			c.fail(err.(*protocolError))
+			return "", err
+		}
+
+		if fin {
+			return string(message), nil
+		}
+		fragmented = true
+	}
+
+	return "", errClosed
+}
+
+// readFrame reads a frame, unmasking its payload
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = header[0]&0x80 != 0
+	op = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, &protocolError{closeProtocolError, "reserved bits set"}
+	}
+	if masked == c.client {
+		return false, 0, nil, &protocolError{closeProtocolError, "frames from clients have to be masked, and frames from servers can't be"}
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.r, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.r, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+
+	if op >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, &protocolError{closeProtocolError, "invalid control frame"}
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, &protocolError{closeTooBig, "message too big"}
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.r, key[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// writeFrame sends payload in a single frame, unless a close frame has been sent
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return errClosed
+	}
+	return c.writeFrameLocked(op, payload)
+}
+
+func (c *wsConn) writeFrameLocked(op byte, payload []byte) error {
+	frame := []byte{0x80 | op}
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	if c.client {
+		var key [4]byte
+		rand.Read(key[:])
+		frame = append(frame, key[:]...)
+
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range payload {
+			frame[start+i] ^= key[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// writeClose sends a close frame, unless one has been sent already
+func (c *wsConn) writeClose(code int, reason string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return c.writeFrameLocked(opClose, append(payload, reason...))
+}
+
+// fail closes the connection after a protocol error
+func (c *wsConn) fail(err *protocolError) {
+	c.writeClose(err.code, err.message)
+	c.shut()
+}
+
+// shut closes the underlying connection
+func (c *wsConn) shut() {
+	c.closed.Store(true)
+	c.conn.Close()
+}
+
+// close sends a close frame and closes the connection once the other side
+// answers, or after closeTimeout
+func (c *wsConn) close(code int, reason string) error {
+	if c.closed.Load() {
+		return nil
+	}
+
+	err := c.writeClose(code, reason)
+	c.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+
+	// A read in progress sees the answer and closes the connection, otherwise wait for it here
+	if !c.readMu.TryLock() {
+		return err
+	}
+	defer c.readMu.Unlock()
+
+	for {
+		_, op, _, errRead := c.readFrame()
+		if errRead != nil || op == opClose {
+			break
+		}
+	}
+	c.shut()
+	return err
+}
+
+// hash returns the connection hash scripts use
+func (c *wsConn) hash() object.Value {
+	return modbuilder.NewHashBuilder().
+		AddFunction("send", c.send).
+		AddFunction("recv", c.recv).
+		AddFunction("close", c.closeFn).
+		AddFunction("on_message", c.setOnMessage).
+		Build()
+}
+
+// send sends a message: strings as they are, other values as JSON
+func (c *wsConn) send(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
+	if err.IsError() {
+		return err
+	}
+
+	var data []byte
+	if args[0].IsString() {
+		data = []byte(args[0].AsString().Value)
+	} else {
+		var errGo error
+		if data, errGo = gojson.Marshal(json.FromObject(args[0])); errGo != nil {
+			return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+		}
+	}
+
+	var errGo error
+	ctx.Runtime().Block(func() {
+		errGo = c.writeFrame(opText, data)
+	})
+	if errGo != nil {
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+	return object.NewNull()
+}
+
+// recv waits for the next message, returning null once the connection is closed
+func (c *wsConn) recv(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+	if err.IsError() {
+		return err
+	}
+
+	if !c.onMessage.IsNull() {
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "messages go to the on_message handler")
+	}
+
+	var message string
+	var errGo error
+	ctx.Runtime().Block(func() {
+		message, errGo = c.readMessage()
+	})
+
+	switch {
+	case goerrors.Is(errGo, errClosed):
+		return object.NewNull()
+	case errGo != nil:
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+	return object.NewString(message)
+}
+
+// closeFn closes the connection, with 1000 unless another status code is given
+func (c *wsConn) closeFn(ctx object.CallContext, args ...object.Value) object.Value {
+	if len(args) > 2 {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "expected 0 to 2 arguments, got %d", len(args))
+	}
+
+	code, reason := closeNormal, ""
+	if len(args) > 0 {
+		if err := errors.ExpectType(ctx.Line, ctx.Col, args[0], object.IntKind); err.IsError() {
+			return err
+		}
+		code = int(args[0].AsInt())
+		if code < 1000 || code > 4999 || code == closeNoStatus {
+			return errors.NewArgumentError(ctx.Line, ctx.Col, "invalid close code %d", code)
+		}
+	}
+	if len(args) > 1 {
+		if err := errors.ExpectType(ctx.Line, ctx.Col, args[1], object.StringKind); err.IsError() {
+			return err
+		}
+		reason = args[1].AsString().Value
+		if len(reason) > 123 {
+			return errors.NewArgumentError(ctx.Line, ctx.Col, "close reasons can be 123 bytes long at most")
+		}
+	}
+
+	var errGo error
+	ctx.Runtime().Block(func() {
+		errGo = c.close(code, reason)
+	})
+	if errGo != nil {
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+	return object.NewNull()
+}
+
+// setOnMessage sends every message from now on to a handler, which a task of
+// the runtime calls until the connection is closed
+func (c *wsConn) setOnMessage(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = expectCallable(ctx, args[0])
+	if err.IsError() {
+		return err
+	}
+
+	started := !c.onMessage.IsNull()
+	c.onMessage = args[0]
+	if started {
+		return object.NewNull()
+	}
+
+	rt := ctx.Runtime()
+	rt.Go(func() {
+		for {
+			var message string
+			var errGo error
+			rt.Block(func() {
+				message, errGo = c.readMessage()
+			})
+			if errGo != nil {
+				return
+			}
+
+			// Like requests, every message gets the whole step budget
+			rt.Begin(rt.Context())
+			result := object.ApplyFunction(ctx, c.onMessage, []object.Value{object.NewString(message)})
+			if result.IsError() {
+				c.logError(result)
+				rt.Block(func() {
+					c.close(closeInternalError, "internal error")
+				})
+				return
+			}
+		}
+	})
+	return object.NewNull()
+}
+
+// ws registers a WebSocket endpoint. GET requests for the path asking for an
+// upgrade are handled by calling the handler with the connection and the
+// request; the connection is closed when it returns, unless on_message is used.
+func (rt *router) ws(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 2, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.StringKind)
+	if err.IsError() {
+		return err
+	}
+
+	err = expectCallable(ctx, args[1])
+	if err.IsError() {
+		return err
+	}
+
+	pattern := args[0].AsString().Value
+	segments, errGo := parsePattern(pattern)
+	if errGo != nil {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+
+	return rt.add(ctx, &route{method: http.MethodGet, pattern: pattern, segments: segments, handler: args[1], websocket: true})
+}
+
+// serveWebSocket upgrades the request to a WebSocket connection and calls handler with it
+func (ex *exchange) serveWebSocket(handler object.Value) object.Value {
+	r := ex.r
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		ex.w.Header().Set("Upgrade", "websocket")
+		http.Error(ex.w, "426 upgrade required", http.StatusUpgradeRequired)
+		return object.NewNull()
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		ex.w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(ex.w, "426 upgrade required", http.StatusUpgradeRequired)
+		return object.NewNull()
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(ex.w, "400 bad request", http.StatusBadRequest)
+		return object.NewNull()
+	}
+
+	conn, brw, errGo := http.NewResponseController(ex.w).Hijack()
+	if errGo != nil {
+		return errors.NewRuntimeError(ex.ctx.Line, ex.ctx.Col, "%s", errGo.Error())
+	}
+	ex.w.status = http.StatusSwitchingProtocols
+
+	// Headers set by middleware, like cookies, go along with the upgrade
+	header := ex.w.Header().Clone()
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", acceptKey(key))
+
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(brw)
+	brw.WriteString("\r\n")
+	if errGo := brw.Flush(); errGo != nil {
+		conn.Close()
+		return errors.NewRuntimeError(ex.ctx.Line, ex.ctx.Col, "%s", errGo.Error())
+	}
+
+	c := newWSConn(conn, brw.Reader, false)
+	c.logError = ex.fail
+	result := object.ApplyFunction(ex.ctx, handler, []object.Value{c.hash(), ex.req})
+
+	if c.onMessage.IsNull() {
+		ex.ctx.Runtime().Block(func() {
+			c.close(closeNormal, "")
+		})
+	}
+	return result
+}
+
+// headerHasToken reports whether the comma-separated header name holds token
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsConnect opens a WebSocket connection to a ws:// or wss:// URL. The options
+// are headers, timeout for opening the connection, and the TLS options of fetch.
+func wsConnect(ctx object.CallContext, args ...object.Value) object.Value {
+	err := expectURLAndOptions(ctx, 1, args)
+	if err.IsError() {
+		return err
+	}
+	options := optionsArg(args, 1)
+
+	if errGo := ctx.Runtime().Policy().CheckNet(); errGo != nil {
+		return errors.NewPermissionError(ctx.Line, ctx.Col, errGo)
+	}
+
+	rawURL := args[0].AsString().Value
+	u, errGo := url.Parse(rawURL)
+	if errGo != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "invalid WebSocket URL %q", rawURL)
+	}
+
+	var headers map[string]string
+	if value, ok := option(options, "headers"); ok {
+		if headers, err = stringHash(ctx, value, "headers"); err.IsError() {
+			return err
+		}
+	}
+
+	var timeout time.Duration
+	if value, ok := option(options, "timeout"); ok {
+		if timeout, err = seconds(ctx, value); err.IsError() {
+			return err
+		}
+	}
+
+	var config *tls.Config
+	if u.Scheme == "wss" {
+		if config, err = tlsConfig(ctx, options); err.IsError() {
+			return err
+		}
+		if config == nil {
+			config = &tls.Config{}
+		}
+		config.ServerName = u.Hostname()
+	}
+
+	var c *wsConn
+	ctx.Runtime().Block(func() {
+		c, errGo = dialWebSocket(ctx.Runtime().Context(), u, headers, config, timeout)
+	})
+	if errGo != nil {
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "ws_connect %s: %s", u.Redacted(), errGo.Error())
+	}
+	return c.hash()
+}
+
+// dialWebSocket connects to u and performs the opening handshake,
+// over TLS if config isn't nil
+func dialWebSocket(ctx context.Context, u *url.URL, headers map[string]string, config *tls.Config, timeout time.Duration) (*wsConn, error) {
+	addr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if config != nil {
+			port = "443"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if config != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: config}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	target := *u
+	target.Scheme = "http"
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("the server answered %s instead of upgrading", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, goerrors.New("the server answered the handshake with the wrong key")
+	}
+
+	conn.SetDeadline(time.Time{})
+	return newWSConn(conn, r, true), nil
+}
@@ -0,0 +1,301 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/sandbox"
+)
+
+// startWSServer serves server over loopback and returns its ws:// URL
+func startWSServer(t *testing.T, server object.Value, tls bool) string {
+	h := &handler{ctx: object.NewCallContext(0, 0), router: mustRouter(t, server)}
+
+	var s *httptest.Server
+	if tls {
+		s = httptest.NewTLSServer(h)
+	} else {
+		s = httptest.NewServer(h)
+	}
+	t.Cleanup(s.Close)
+
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// echo is a WebSocket handler answering every message until the connection is closed
+func echo(t *testing.T) object.Value {
+	return builtin(func(args ...object.Value) object.Value {
+		for {
+			message := call(t, args[0], "recv")
+			if message.IsNull() || message.IsError() {
+				return message
+			}
+			call(t, args[0], "send", object.NewString("echo "+message.AsString().Value))
+		}
+	})
+}
+
+func TestWebSocket(t *testing.T) {
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "ws", object.NewString("/echo"), echo(t))
+	mustCall(t, server, "ws", object.NewString("/rooms/{room}"), builtin(func(args ...object.Value) object.Value {
+		room := call(t, args[1], "path_param", object.NewString("room"))
+		return call(t, args[0], "send", object.NewString("welcome to "+room.AsString().Value))
+	}))
+	base := startWSServer(t, server, false)
+
+	ctx := scriptContext(t, nil)
+	conn := mustSucceed(t, wsConnect(ctx, object.NewString(base+"/echo")))
+
+	exchange := func(message object.Value, expected string) {
+		t.Helper()
+
+		mustSucceed(t, get(t, conn, "send").AsBuiltin().Fn(ctx, message))
+		reply := mustSucceed(t, get(t, conn, "recv").AsBuiltin().Fn(ctx))
+		if reply.AsString().Value != expected {
+			t.Errorf("expected %q, got %s", expected, reply.Inspect())
+		}
+	}
+
+	exchange(object.NewString("hi"), "echo hi")
+	exchange(hashOf(map[string]object.Value{"n": object.NewInt(1)}), `echo {"n":1}`)
+	exchange(object.NewString(strings.Repeat("x", 70000)), "echo "+strings.Repeat("x", 70000))
+
+	mustSucceed(t, get(t, conn, "close").AsBuiltin().Fn(ctx))
+	if result := get(t, conn, "recv").AsBuiltin().Fn(ctx); !result.IsNull() {
+		t.Errorf("expected null from a closed connection, got %s", result.Inspect())
+	}
+	if result := get(t, conn, "send").AsBuiltin().Fn(ctx, object.NewString("late")); !result.IsError() {
+		t.Errorf("expected an error sending on a closed connection, got %s", result.Inspect())
+	}
+
+	// The connection closes when the handler returns
+	conn = mustSucceed(t, wsConnect(ctx, object.NewString(base+"/rooms/lobby")))
+	if message := get(t, conn, "recv").AsBuiltin().Fn(ctx); message.AsString().Value != "welcome to lobby" {
+		t.Errorf("expected a welcome, got %s", message.Inspect())
+	}
+	if message := get(t, conn, "recv").AsBuiltin().Fn(ctx); !message.IsNull() {
+		t.Errorf("expected the server to close the connection, got %s", message.Inspect())
+	}
+
+	// Plain requests to WebSocket routes are turned down
+	resp, err := http.Get("http" + strings.TrimPrefix(base, "ws") + "/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("expected 426, got %d", resp.StatusCode)
+	}
+
+	if result := wsConnect(ctx, object.NewString(base+"/missing")); !result.IsError() || !strings.Contains(result.AsError().Message, "404") {
+		t.Errorf("expected the handshake to fail with 404, got %s", result.Inspect())
+	}
+	if result := wsConnect(ctx, object.NewString("http://example.com")); !result.IsError() {
+		t.Errorf("expected an error for a URL that isn't ws://, got %s", result.Inspect())
+	}
+}
+
+func TestWebSocketOnMessage(t *testing.T) {
+	// On the server, the handler returns but the connection stays open
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "ws", object.NewString("/upper"), builtin(func(args ...object.Value) object.Value {
+		conn := args[0]
+		return call(t, conn, "on_message", builtin(func(args ...object.Value) object.Value {
+			return call(t, conn, "send", object.NewString(strings.ToUpper(args[0].AsString().Value)))
+		}))
+	}))
+	mustCall(t, server, "ws", object.NewString("/count"), builtin(func(args ...object.Value) object.Value {
+		for _, n := range []string{"1", "2", "3"} {
+			call(t, args[0], "send", object.NewString(n))
+		}
+		return object.NewNull()
+	}))
+	base := startWSServer(t, server, false)
+
+	ctx := scriptContext(t, nil)
+	conn := mustSucceed(t, wsConnect(ctx, object.NewString(base+"/upper")))
+	for _, message := range []string{"a", "b"} {
+		get(t, conn, "send").AsBuiltin().Fn(ctx, object.NewString(message))
+		if reply := get(t, conn, "recv").AsBuiltin().Fn(ctx); reply.AsString().Value != strings.ToUpper(message) {
+			t.Errorf("expected %q, got %s", strings.ToUpper(message), reply.Inspect())
+		}
+	}
+	get(t, conn, "close").AsBuiltin().Fn(ctx)
+
+	// On the client, a task of the runtime delivers the messages until the connection closes
+	var received []string
+	conn = mustSucceed(t, wsConnect(ctx, object.NewString(base+"/count")))
+	mustSucceed(t, get(t, conn, "on_message").AsBuiltin().Fn(ctx, builtin(func(args ...object.Value) object.Value {
+		received = append(received, args[0].AsString().Value)
+		return object.NewNull()
+	})))
+
+	if result := get(t, conn, "recv").AsBuiltin().Fn(ctx); !result.IsError() {
+		t.Errorf("expected recv to fail once on_message is used, got %s", result.Inspect())
+	}
+
+	ctx.Runtime().Wait()
+	if strings.Join(received, ",") != "1,2,3" {
+		t.Errorf("expected 1,2,3, got %v", received)
+	}
+}
+
+func TestWebSocketOnMessageErrors(t *testing.T) {
+	log := captureLog(t)
+
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "ws", object.NewString("/messages"), builtin(func(args ...object.Value) object.Value {
+		return call(t, args[0], "on_message", fail("bad message"))
+	}))
+	base := startWSServer(t, server, false)
+
+	// The error is logged like those of routes, then the connection is closed with 1011
+	c := rawConnect(t, base+"/messages")
+	writeRaw(t, c, true, opText, "hi", false)
+
+	_, op, payload, err := c.readFrame()
+	if err != nil || op != opClose || len(payload) < 2 {
+		t.Fatalf("expected a close frame, got %d %q (%v)", op, payload, err)
+	}
+	if code := int(binary.BigEndian.Uint16(payload)); code != closeInternalError {
+		t.Errorf("expected close code %d, got %d", closeInternalError, code)
+	}
+
+	if !strings.Contains(log.String(), "http: error serving GET /messages") || !strings.Contains(log.String(), "bad message") {
+		t.Errorf("expected the error to be logged, got %q", log.String())
+	}
+}
+
+// rawConnect opens a connection to url the way ws_connect does, for writing frames by hand
+func rawConnect(t *testing.T, rawURL string) *wsConn {
+	u, _ := url.Parse(rawURL)
+	c, err := dialWebSocket(t.Context(), u, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.shut)
+	return c
+}
+
+// writeRaw writes a frame, masked unless unmasked is set
+func writeRaw(t *testing.T, c *wsConn, fin bool, op byte, payload string, unmasked bool) {
+	first := op
+	if fin {
+		first |= 0x80
+	}
+
+	frame := []byte{first, byte(len(payload))}
+	if !unmasked {
+		var key [4]byte
+		rand.Read(key[:])
+		frame[1] |= 0x80
+		frame = append(frame, key[:]...)
+		for i := range len(payload) {
+			frame = append(frame, payload[i]^key[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebSocketProtocol(t *testing.T) {
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "ws", object.NewString("/echo"), echo(t))
+	base := startWSServer(t, server, false)
+
+	// Fragments make up a single message, with pings answered in between
+	c := rawConnect(t, base+"/echo")
+	writeRaw(t, c, false, opText, "hel", false)
+	writeRaw(t, c, true, opPing, "ping", false)
+	writeRaw(t, c, true, opContinuation, "lo", false)
+
+	_, op, payload, err := c.readFrame()
+	if err != nil || op != opPong || string(payload) != "ping" {
+		t.Errorf("expected a pong, got %d %q (%v)", op, payload, err)
+	}
+	if message, err := c.readMessage(); err != nil || message != "echo hello" {
+		t.Errorf("expected \"echo hello\", got %q (%v)", message, err)
+	}
+
+	tests := []struct {
+		name  string
+		write func(c *wsConn)
+		code  int
+	}{
+		{"unmasked", func(c *wsConn) { writeRaw(t, c, true, opText, "hi", true) }, closeProtocolError},
+		{"continuation", func(c *wsConn) { writeRaw(t, c, true, opContinuation, "hi", false) }, closeProtocolError},
+		{"invalid UTF-8", func(c *wsConn) { writeRaw(t, c, true, opText, "\xff", false) }, closeInvalidData},
+		{"unknown opcode", func(c *wsConn) { writeRaw(t, c, true, 0x3, "", false) }, closeProtocolError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := rawConnect(t, base+"/echo")
+			tt.write(c)
+
+			_, op, payload, err := c.readFrame()
+			if err != nil || op != opClose || len(payload) < 2 {
+				t.Fatalf("expected a close frame, got %d %q (%v)", op, payload, err)
+			}
+			if code := int(binary.BigEndian.Uint16(payload)); code != tt.code {
+				t.Errorf("expected close code %d, got %d", tt.code, code)
+			}
+		})
+	}
+
+	// Handshakes have to be valid
+	r, _ := http.NewRequest("GET", "http"+strings.TrimPrefix(base, "ws")+"/echo", nil)
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", "short")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad key, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebSocketTLS(t *testing.T) {
+	server := createServer(object.NewCallContext(0, 0))
+	mustCall(t, server, "ws", object.NewString("/echo"), echo(t))
+	base := startWSServer(t, server, true)
+
+	ctx := scriptContext(t, nil)
+	if result := wsConnect(ctx, object.NewString(base+"/echo")); !result.IsError() || !strings.Contains(result.AsError().Message, "certificate") {
+		t.Errorf("expected a certificate error, got %s", result.Inspect())
+	}
+
+	conn := mustSucceed(t, wsConnect(ctx, object.NewString(base+"/echo"), hashOf(map[string]object.Value{
+		"insecure": object.NewBool(true),
+	})))
+	get(t, conn, "send").AsBuiltin().Fn(ctx, object.NewString("secure"))
+	if reply := get(t, conn, "recv").AsBuiltin().Fn(ctx); reply.AsString().Value != "echo secure" {
+		t.Errorf("expected \"echo secure\", got %s", reply.Inspect())
+	}
+	get(t, conn, "close").AsBuiltin().Fn(ctx)
+}
+
+func TestWebSocketPolicy(t *testing.T) {
+	policy := sandbox.New()
+	policy.SetNet(false)
+
+	result := wsConnect(scriptContext(t, policy), object.NewString("ws://127.0.0.1:1/"))
+	if !result.IsError() || !strings.Contains(result.AsError().Message, "not allowed") {
+		t.Errorf("expected a permission error, got %s", result.Inspect())
+	}
+}
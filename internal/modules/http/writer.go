@@ -2,22 +2,30 @@ package http
 
 import (
 	gojson "encoding/json"
+	"fmt"
 	"github.com/radeqq007/sunbird/internal/errors"
 	"github.com/radeqq007/sunbird/internal/modules/json"
 	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
 	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/vfs"
+	"io/fs"
 	"net/http"
 )
 
 type responseWriter struct {
 	w http.ResponseWriter
+	r *http.Request
 }
 
-func newWriter(w http.ResponseWriter) object.Value {
-	rw := &responseWriter{w: w}
+func newWriter(w http.ResponseWriter, r *http.Request) object.Value {
+	rw := &responseWriter{w: w, r: r}
 	return modbuilder.NewHashBuilder().
 		AddFunction("send", rw.send).
 		AddFunction("json", rw.json).
+		AddFunction("write", rw.write).
+		AddFunction("flush", rw.flush).
+		AddFunction("redirect", rw.redirect).
+		AddFunction("file", rw.file).
 		AddValue("header", rw.newHeader()).
 		AddFunction("add", rw.add).
 		AddFunction("status", rw.status).
@@ -36,9 +44,12 @@ func (rw *responseWriter) send(ctx object.CallContext, args ...object.Value) obj
 		return err
 	}
 
-	_, errGo := rw.w.Write([]byte(args[0].AsString().Value))
+	var errGo error
+	ctx.Runtime().Block(func() {
+		_, errGo = rw.w.Write([]byte(args[0].AsString().Value))
+	})
 	if errGo != nil {
-		return errors.New(errors.RuntimeError, 0, 0, "%s", errGo.Error())
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
 	}
 
 	return object.NewNull()
@@ -50,24 +61,133 @@ func (rw *responseWriter) json(ctx object.CallContext, args ...object.Value) obj
 		return err
 	}
 
-	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.HashKind)
+	data := json.FromObject(args[0])
+	bytes, errGo := gojson.Marshal(data)
+	if errGo != nil {
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+
+	rw.w.Header().Set("Content-Type", "application/json")
+
+	ctx.Runtime().Block(func() {
+		_, errGo = rw.w.Write(bytes)
+	})
+	if errGo != nil {
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+
+	return object.NewNull()
+}
+
+// write writes a chunk of the response, which stays open for more.
+// Along with flush, it lets handlers stream responses like server-sent events.
+func (rw *responseWriter) write(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
 	if err.IsError() {
 		return err
 	}
 
-	data := json.FromObject(args[0])
-	bytes, errGo := gojson.Marshal(data)
+	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.StringKind)
+	if err.IsError() {
+		return err
+	}
+
+	var errGo error
+	ctx.Runtime().Block(func() {
+		_, errGo = rw.w.Write([]byte(args[0].AsString().Value))
+	})
 	if errGo != nil {
 		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
 	}
 
-	rw.w.Header().Set("Content-Type", "application/json")
+	return object.NewNull()
+}
+
+// flush sends what has been written so far to the client
+func (rw *responseWriter) flush(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 0, args)
+	if err.IsError() {
+		return err
+	}
+
+	var errGo error
+	ctx.Runtime().Block(func() {
+		errGo = http.NewResponseController(rw.w).Flush()
+	})
+	if errGo != nil {
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
+	}
+
+	return object.NewNull()
+}
 
-	_, errGo = rw.w.Write(bytes)
+// redirect sends the client to another URL, with 302 Found unless another
+// redirect status is given
+func (rw *responseWriter) redirect(ctx object.CallContext, args ...object.Value) object.Value {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.NewArgumentError(ctx.Line, ctx.Col, "expected 1 or 2 arguments, got %d", len(args))
+	}
+
+	err := errors.ExpectType(ctx.Line, ctx.Col, args[0], object.StringKind)
+	if err.IsError() {
+		return err
+	}
+
+	status := http.StatusFound
+	if len(args) == 2 {
+		err = errors.ExpectType(ctx.Line, ctx.Col, args[1], object.IntKind)
+		if err.IsError() {
+			return err
+		}
+
+		status = int(args[1].AsInt())
+		if status < 300 || status > 399 {
+			return errors.NewArgumentError(ctx.Line, ctx.Col, "%d is not a redirect status", status)
+		}
+	}
+
+	http.Redirect(rw.w, rw.r, args[0].AsString().Value, status)
+	return object.NewNull()
+}
+
+// file answers with the file at a path relative to the calling file, taking
+// care of conditional requests and ranges like serve_static
+func (rw *responseWriter) file(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.StringKind)
+	if err.IsError() {
+		return err
+	}
+
+	path := scriptPath(ctx, args[0].AsString().Value)
+	if errGo := ctx.Runtime().Policy().CheckRead(path); errGo != nil {
+		return errors.NewPermissionError(ctx.Line, ctx.Col, errGo)
+	}
+
+	var f vfs.File
+	var info fs.FileInfo
+	var errGo error
+	ctx.Runtime().Block(func() {
+		info, errGo = vfs.Stat(path)
+		if errGo == nil && info.IsDir() {
+			errGo = fmt.Errorf("%s is a directory", args[0].AsString().Value)
+		}
+		if errGo == nil {
+			f, errGo = vfs.Open(path)
+		}
+	})
 	if errGo != nil {
 		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", errGo.Error())
 	}
 
+	ctx.Runtime().Block(func() {
+		defer f.Close()
+		serveContent(rw.w, rw.r, path, info, f)
+	})
 	return object.NewNull()
 }
 
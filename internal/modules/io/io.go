@@ -22,12 +22,26 @@ func New() object.Value {
 		AddFunction("sprintf", sprintf).
 		AddFunction("clear", clearScreen).
 		AddFunction("beep", beep).
-		AddValue("args", getArgsArray()).
+		AddValue("args", scriptArgs).
 		Build()
 }
 
 var stdin = bufio.NewReader(os.Stdin)
 
+// scriptArgs is the array exposed as io.args
+var scriptArgs = getArgsArray()
+
+// SetArgs replaces the contents of io.args. It's used when the script
+// arguments don't start at their usual position in os.Args, e.g. because
+// interpreter flags come first.
+func SetArgs(args []string) {
+	arr := scriptArgs.AsArray()
+	arr.Elements = arr.Elements[:0]
+	for _, arg := range args {
+		arr.Elements = append(arr.Elements, object.NewString(arg))
+	}
+}
+
 func printObject(ctx object.CallContext, args ...object.Value) object.Value {
 	for i, arg := range args {
 		if i > 0 {
@@ -99,7 +99,7 @@ func FromObject(obj object.Value) any {
 	case object.BoolKind:
 		return obj.AsBool()
 	case object.NullKind:
-		return object.NewNull()
+		return nil
 	case object.ArrayKind:
 		o := obj.AsArray()
 		elements := make([]any, len(o.Elements))
@@ -17,6 +17,12 @@ func (mb *ModuleBuilder) AddFunction(name string, fn object.BuiltinFunction) *Mo
 	return mb
 }
 
+// AddGoFunction adds an ordinary Go function, see Wrap for the supported signatures.
+// It panics if fn can't be wrapped.
+func (mb *ModuleBuilder) AddGoFunction(name string, fn any) *ModuleBuilder {
+	return mb.AddFunction(name, MustWrap(fn))
+}
+
 func (mb *ModuleBuilder) AddValue(name string, value object.Value) *ModuleBuilder {
 	mb.pairs[name] = value
 	return mb
@@ -60,6 +66,12 @@ func (hb *HashBuilder) AddFunction(name string, fn object.BuiltinFunction) *Hash
 	return hb
 }
 
+// AddGoFunction adds an ordinary Go function, see Wrap for the supported signatures.
+// It panics if fn can't be wrapped.
+func (hb *HashBuilder) AddGoFunction(name string, fn any) *HashBuilder {
+	return hb.AddFunction(name, MustWrap(fn))
+}
+
 func (hb *HashBuilder) AddValue(name string, value object.Value) *HashBuilder {
 	key := object.NewString(name)
 	hashKey := key.HashKey()
@@ -0,0 +1,216 @@
+package modbuilder
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+// Function is a Go function that can be called from Sunbird as is.
+// A returned error is raised as a RuntimeError in the calling script.
+type Function = func(args ...object.Value) (object.Value, error)
+
+// ToValue converts a Go value into a Sunbird value.
+//
+// Booleans, numbers and strings map onto their Sunbird counterparts, slices and
+// arrays become arrays, and maps and structs become hashes. Struct fields can be
+// renamed with a `sunbird:"name"` tag or skipped with `sunbird:"-"`.
+// Other functions than Function and object.BuiltinFunction are bound with Wrap.
+func ToValue(v any) (object.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return object.NewNull(), nil
+	case object.Value:
+		return v, nil
+	case object.BuiltinFunction:
+		return object.NewBuiltin(v), nil
+	case Function:
+		return object.NewBuiltin(wrapFunction(v)), nil
+	}
+
+	return reflectToValue(reflect.ValueOf(v))
+}
+
+func wrapFunction(fn Function) object.BuiltinFunction {
+	return func(ctx object.CallContext, args ...object.Value) object.Value {
+		result, err := fn(args...)
+		if err != nil {
+			return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", err)
+		}
+		return result
+	}
+}
+
+func reflectToValue(rv reflect.Value) (object.Value, error) {
+	switch rv.Kind() {
+	case reflect.Bool:
+		return object.NewBool(rv.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return object.NewInt(rv.Int()), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return object.NewNull(), fmt.Errorf("%d overflows a Sunbird integer", rv.Uint())
+		}
+		return object.NewInt(int64(rv.Uint())), nil
+
+	case reflect.Float32, reflect.Float64:
+		return object.NewFloat(rv.Float()), nil
+
+	case reflect.String:
+		return object.NewString(rv.String()), nil
+
+	case reflect.Slice:
+		if rv.IsNil() {
+			return object.NewNull(), nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return object.NewString(string(rv.Bytes())), nil
+		}
+		return sliceToValue(rv)
+
+	case reflect.Array:
+		return sliceToValue(rv)
+
+	case reflect.Map:
+		if rv.IsNil() {
+			return object.NewNull(), nil
+		}
+		return mapToValue(rv)
+
+	case reflect.Struct:
+		return structToValue(rv)
+
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return object.NewNull(), nil
+		}
+		return ToValue(rv.Elem().Interface())
+
+	case reflect.Func:
+		if rv.IsNil() {
+			return object.NewNull(), nil
+		}
+		builtin, err := Wrap(rv.Interface())
+		if err != nil {
+			return object.NewNull(), err
+		}
+		return object.NewBuiltin(builtin), nil
+
+	case reflect.Invalid:
+		return object.NewNull(), nil
+
+	default:
+		return object.NewNull(), fmt.Errorf("cannot convert %s to a Sunbird value", rv.Type())
+	}
+}
+
+func sliceToValue(rv reflect.Value) (object.Value, error) {
+	elements := make([]object.Value, rv.Len())
+	for i := range elements {
+		el, err := ToValue(rv.Index(i).Interface())
+		if err != nil {
+			return object.NewNull(), err
+		}
+		elements[i] = el
+	}
+
+	return object.NewArray(elements), nil
+}
+
+func mapToValue(rv reflect.Value) (object.Value, error) {
+	pairs := make(map[object.HashKey]object.HashPair, rv.Len())
+
+	iter := rv.MapRange()
+	for iter.Next() {
+		key, err := ToValue(iter.Key().Interface())
+		if err != nil {
+			return object.NewNull(), err
+		}
+		if !key.IsString() && !key.IsInt() {
+			return object.NewNull(), fmt.Errorf("cannot use %s as a hash key", iter.Key().Type())
+		}
+
+		val, err := ToValue(iter.Value().Interface())
+		if err != nil {
+			return object.NewNull(), err
+		}
+
+		pairs[key.HashKey()] = object.NewHashPair(key, val)
+	}
+
+	return object.NewHash(pairs), nil
+}
+
+func structToValue(rv reflect.Value) (object.Value, error) {
+	hb := NewHashBuilder()
+
+	for _, field := range reflect.VisibleFields(rv.Type()) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("sunbird"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+
+		fieldValue, err := rv.FieldByIndexErr(field.Index)
+		if err != nil {
+			// Promoted through a nil embedded pointer
+			continue
+		}
+
+		val, err := ToValue(fieldValue.Interface())
+		if err != nil {
+			return object.NewNull(), fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		hb.AddValue(name, val)
+	}
+
+	return hb.Build(), nil
+}
+
+// FromValue converts a Sunbird value into a plain Go value: nil, bool, int64,
+// float64, string, []any or map[string]any. Values without a Go counterpart,
+// such as functions, are returned unchanged.
+func FromValue(v object.Value) any {
+	switch v.Kind() {
+	case object.NullKind:
+		return nil
+	case object.BoolKind:
+		return v.AsBool()
+	case object.IntKind:
+		return v.AsInt()
+	case object.FloatKind:
+		return v.AsFloat()
+	case object.StringKind:
+		return v.AsString().Value
+	case object.ArrayKind:
+		elements := make([]any, len(v.AsArray().Elements))
+		for i, el := range v.AsArray().Elements {
+			elements[i] = FromValue(el)
+		}
+		return elements
+	case object.HashKind:
+		result := make(map[string]any, len(v.AsHash().Pairs))
+		for _, pair := range v.AsHash().Pairs {
+			key := pair.Key.Inspect()
+			if pair.Key.IsString() {
+				key = pair.Key.AsString().Value
+			}
+			result[key] = FromValue(pair.Value)
+		}
+		return result
+	default:
+		return v
+	}
+}
@@ -0,0 +1,327 @@
+package modbuilder
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+var (
+	valueType       = reflect.TypeFor[object.Value]()
+	callContextType = reflect.TypeFor[object.CallContext]()
+	errorType       = reflect.TypeFor[error]()
+)
+
+// Wrap turns an ordinary Go function into a builtin.
+//
+// Arguments are checked and converted to the parameter types of fn, which may be
+// booleans, numbers, strings, slices, maps with string keys, structs, any or
+// object.Value. Variadic functions are supported, and fn may take an
+// object.CallContext as its first parameter. fn returns nothing, a value, an
+// error, or a value and an error; a non-nil error is raised as a RuntimeError.
+func Wrap(fn any) (object.BuiltinFunction, error) {
+	rv := reflect.ValueOf(fn)
+	if rv.Kind() != reflect.Func {
+		return nil, fmt.Errorf("expected a function, got %T", fn)
+	}
+
+	t := rv.Type()
+	if err := checkResults(t); err != nil {
+		return nil, err
+	}
+
+	params := make([]reflect.Type, t.NumIn())
+	for i := range params {
+		params[i] = t.In(i)
+	}
+
+	takesContext := len(params) > 0 && params[0] == callContextType
+	if takesContext {
+		params = params[1:]
+	}
+
+	return func(ctx object.CallContext, args ...object.Value) object.Value {
+		in, err := convertArgs(ctx, params, t.IsVariadic(), args)
+		if err.IsError() {
+			return err
+		}
+
+		if takesContext {
+			in = append([]reflect.Value{reflect.ValueOf(ctx)}, in...)
+		}
+
+		return convertResults(ctx, rv.Call(in))
+	}, nil
+}
+
+// MustWrap is like Wrap, but panics if fn can't be wrapped.
+// It's meant for module definitions, where that is a programming error.
+func MustWrap(fn any) object.BuiltinFunction {
+	builtin, err := Wrap(fn)
+	if err != nil {
+		panic(err)
+	}
+	return builtin
+}
+
+func checkResults(t reflect.Type) error {
+	switch t.NumOut() {
+	case 0, 1:
+		return nil
+	case 2:
+		if t.Out(1) != errorType {
+			return fmt.Errorf("the second result of %s must be an error", t)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s returns too many values", t)
+	}
+}
+
+func convertArgs(
+	ctx object.CallContext,
+	params []reflect.Type,
+	variadic bool,
+	args []object.Value,
+) ([]reflect.Value, object.Value) {
+	if variadic {
+		err := errors.ExpectMinNumberOfArguments(ctx.Line, ctx.Col, len(params)-1, args)
+		if err.IsError() {
+			return nil, err
+		}
+	} else {
+		err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, len(params), args)
+		if err.IsError() {
+			return nil, err
+		}
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		t := params[min(i, len(params)-1)]
+		if variadic && i >= len(params)-1 {
+			t = t.Elem()
+		}
+
+		val, err := Decode(arg, t)
+		if err != nil {
+			return nil, errors.NewTypeError(ctx.Line, ctx.Col, "argument %d: %s", i+1, err)
+		}
+		in[i] = val
+	}
+
+	return in, object.NewNull()
+}
+
+func convertResults(ctx object.CallContext, out []reflect.Value) object.Value {
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", err)
+		}
+		out = out[:len(out)-1]
+	}
+
+	if len(out) == 0 {
+		return object.NewNull()
+	}
+
+	result, err := ToValue(out[0].Interface())
+	if err != nil {
+		return errors.NewRuntimeError(ctx.Line, ctx.Col, "%s", err)
+	}
+	return result
+}
+
+// Decode converts a Sunbird value into a Go value of type t
+func Decode(v object.Value, t reflect.Type) (reflect.Value, error) {
+	if t == valueType {
+		return reflect.ValueOf(v), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			break
+		}
+		goValue := FromValue(v)
+		if goValue == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(goValue), nil
+
+	case reflect.Bool:
+		if v.IsBool() {
+			return reflect.ValueOf(v.AsBool()).Convert(t), nil
+		}
+		return reflect.Value{}, mismatch(object.BoolKind, v)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !v.IsInt() {
+			return reflect.Value{}, mismatch(object.IntKind, v)
+		}
+		result := reflect.New(t).Elem()
+		if result.OverflowInt(v.AsInt()) {
+			return reflect.Value{}, fmt.Errorf("%d is out of range for %s", v.AsInt(), t)
+		}
+		result.SetInt(v.AsInt())
+		return result, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if !v.IsInt() {
+			return reflect.Value{}, mismatch(object.IntKind, v)
+		}
+		result := reflect.New(t).Elem()
+		if v.AsInt() < 0 || result.OverflowUint(uint64(v.AsInt())) {
+			return reflect.Value{}, fmt.Errorf("%d is out of range for %s", v.AsInt(), t)
+		}
+		result.SetUint(uint64(v.AsInt()))
+		return result, nil
+
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch {
+		case v.IsFloat():
+			f = v.AsFloat()
+		case v.IsInt():
+			f = float64(v.AsInt())
+		default:
+			return reflect.Value{}, mismatch(object.FloatKind, v)
+		}
+		if t.Kind() == reflect.Float32 && math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+			return reflect.Value{}, fmt.Errorf("%v is out of range for %s", f, t)
+		}
+		return reflect.ValueOf(f).Convert(t), nil
+
+	case reflect.String:
+		if v.IsString() {
+			return reflect.ValueOf(v.AsString().Value).Convert(t), nil
+		}
+		return reflect.Value{}, mismatch(object.StringKind, v)
+
+	case reflect.Slice:
+		return decodeSlice(v, t)
+
+	case reflect.Map:
+		return decodeMap(v, t)
+
+	case reflect.Struct:
+		return decodeStruct(v, t)
+
+	case reflect.Pointer:
+		if v.IsNull() {
+			return reflect.Zero(t), nil
+		}
+		elem, err := Decode(v, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", v.Kind(), t)
+}
+
+func decodeSlice(v object.Value, t reflect.Type) (reflect.Value, error) {
+	if t.Elem().Kind() == reflect.Uint8 && v.IsString() {
+		return reflect.ValueOf([]byte(v.AsString().Value)).Convert(t), nil
+	}
+
+	if !v.IsArray() {
+		return reflect.Value{}, mismatch(object.ArrayKind, v)
+	}
+
+	elements := v.AsArray().Elements
+	result := reflect.MakeSlice(t, len(elements), len(elements))
+	for i, el := range elements {
+		val, err := Decode(el, t.Elem())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+		}
+		result.Index(i).Set(val)
+	}
+
+	return result, nil
+}
+
+func decodeMap(v object.Value, t reflect.Type) (reflect.Value, error) {
+	if !v.IsHash() {
+		return reflect.Value{}, mismatch(object.HashKind, v)
+	}
+
+	result := reflect.MakeMapWithSize(t, len(v.AsHash().Pairs))
+	for _, pair := range v.AsHash().Pairs {
+		key, err := Decode(pair.Key, t.Key())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+		}
+
+		val, err := Decode(pair.Value, t.Elem())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+		}
+
+		result.SetMapIndex(key, val)
+	}
+
+	return result, nil
+}
+
+func decodeStruct(v object.Value, t reflect.Type) (reflect.Value, error) {
+	if !v.IsHash() {
+		return reflect.Value{}, mismatch(object.HashKind, v)
+	}
+
+	result := reflect.New(t).Elem()
+	pairs := v.AsHash().Pairs
+
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("sunbird"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+
+		pair, ok := pairs[object.NewString(name).HashKey()]
+		if !ok {
+			continue
+		}
+
+		val, err := Decode(pair.Value, field.Type)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("field %s: %w", name, err)
+		}
+		fieldByIndexAlloc(result, field.Index).Set(val)
+	}
+
+	return result, nil
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex, but allocates
+// nil embedded pointers on the way instead of panicking
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func mismatch(expected object.ValueKind, got object.Value) error {
+	return fmt.Errorf("expected %s, got %s", expected, got.Kind())
+}
@@ -0,0 +1,124 @@
+package modbuilder
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+func TestWrap(t *testing.T) {
+	repeat := MustWrap(func(s string, n int) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		result := ""
+		for range n {
+			result += s
+		}
+		return result, nil
+	})
+
+	sum := MustWrap(func(first float64, rest ...float64) float64 {
+		for _, x := range rest {
+			first += x
+		}
+		return first
+	})
+
+	line := MustWrap(func(ctx object.CallContext) int {
+		return ctx.Line
+	})
+
+	ctx := object.CallContext{Line: 3, Col: 7}
+
+	tests := []struct {
+		fn       object.BuiltinFunction
+		args     []object.Value
+		expected string
+	}{
+		{repeat, []object.Value{object.NewString("ab"), object.NewInt(3)}, `"ababab"`},
+		{repeat, []object.Value{object.NewString("ab")}, "ArgumentError: expected 2 arguments, got 1 (at line 3, col 7)"},
+		{repeat, []object.Value{object.NewString("ab"), object.NewString("3")}, "TypeError: argument 2: expected Integer, got String (at line 3, col 7)"},
+		{repeat, []object.Value{object.NewString("ab"), object.NewInt(-1)}, "RuntimeError: negative count (at line 3, col 7)"},
+		{sum, []object.Value{object.NewInt(1)}, "1"},
+		{sum, []object.Value{object.NewInt(1), object.NewFloat(0.5), object.NewInt(2)}, "3.5"},
+		{sum, []object.Value{}, "ArgumentError: expected at least 1 arguments, got 0 (at line 3, col 7)"},
+		{line, []object.Value{}, "3"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.fn(ctx, tt.args...).Inspect(); got != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, got)
+		}
+	}
+}
+
+func TestWrapInvalid(t *testing.T) {
+	invalid := []any{
+		42,
+		func() (int, int) { return 0, 0 },
+		func() (int, string, error) { return 0, "", nil },
+	}
+
+	for _, fn := range invalid {
+		if _, err := Wrap(fn); err == nil {
+			t.Errorf("expected an error wrapping %T", fn)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	type point struct {
+		X int `sunbird:"x"`
+		Y int `sunbird:"y"`
+	}
+
+	x, y := object.NewString("x"), object.NewString("y")
+	hash := object.NewHash(map[object.HashKey]object.HashPair{
+		x.HashKey(): object.NewHashPair(x, object.NewInt(1)),
+		y.HashKey(): object.NewHashPair(y, object.NewInt(2)),
+	})
+
+	tests := []struct {
+		input    object.Value
+		expected any
+	}{
+		{object.NewInt(5), int8(5)},
+		{object.NewInt(5), 5.0},
+		{object.NewString("hi"), []byte("hi")},
+		{object.NewArray([]object.Value{object.NewInt(1), object.NewInt(2)}), []uint{1, 2}},
+		{hash, point{X: 1, Y: 2}},
+		{hash, &point{X: 1, Y: 2}},
+		{hash, map[string]int{"x": 1, "y": 2}},
+		{object.NewBool(true), any(true)},
+	}
+
+	for _, tt := range tests {
+		got, err := Decode(tt.input, reflect.TypeOf(tt.expected))
+		if err != nil {
+			t.Errorf("Decode(%s): unexpected error: %s", tt.input.Inspect(), err)
+			continue
+		}
+		if !reflect.DeepEqual(got.Interface(), tt.expected) {
+			t.Errorf("Decode(%s): expected %#v, got %#v", tt.input.Inspect(), tt.expected, got.Interface())
+		}
+	}
+
+	failing := []struct {
+		input object.Value
+		t     reflect.Type
+	}{
+		{object.NewInt(300), reflect.TypeFor[uint8]()},
+		{object.NewInt(-1), reflect.TypeFor[uint]()},
+		{object.NewFloat(1.5), reflect.TypeFor[int]()},
+		{object.NewArray([]object.Value{object.NewString("a")}), reflect.TypeFor[[]int]()},
+	}
+
+	for _, tt := range failing {
+		if _, err := Decode(tt.input, tt.t); err == nil {
+			t.Errorf("expected an error decoding %s into %s", tt.input.Inspect(), tt.t)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package promise
+
+import (
+	"time"
+
+	"github.com/radeqq007/sunbird/internal/errors"
+	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+func New() object.Value {
+	return modbuilder.NewModuleBuilder().
+		AddFunction("all", all).
+		AddFunction("race", race).
+		AddFunction("timeout", timeout).
+		Build()
+}
+
+// toPromise wraps plain values in an already settled promise
+func toPromise(val object.Value) *object.Promise {
+	if val.IsPromise() {
+		return val.AsPromise()
+	}
+	return object.NewResolvedPromise(val).AsPromise()
+}
+
+// all resolves to an array of results once every promise has settled,
+// or to the first error encountered
+func all(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.ArrayKind)
+	if err.IsError() {
+		return err
+	}
+
+	elements := args[0].AsArray().Elements
+	promises := make([]*object.Promise, len(elements))
+	for i, el := range elements {
+		promises[i] = toPromise(el)
+	}
+
+	result := object.NewPromise()
+	go func() {
+		values := make([]object.Value, len(promises))
+		for i, p := range promises {
+			<-p.Done()
+			val := p.Result()
+			if val.IsError() {
+				result.AsPromise().Settle(val)
+				return
+			}
+			values[i] = val
+		}
+		result.AsPromise().Settle(object.NewArray(values))
+	}()
+
+	return result
+}
+
+// race settles with the result of whichever promise settles first
+func race(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 1, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = errors.ExpectType(ctx.Line, ctx.Col, args[0], object.ArrayKind)
+	if err.IsError() {
+		return err
+	}
+
+	elements := args[0].AsArray().Elements
+	if len(elements) == 0 {
+		return object.NewResolvedPromise(object.NewNull())
+	}
+
+	result := object.NewPromise()
+	for _, el := range elements {
+		p := toPromise(el)
+		go func() {
+			<-p.Done()
+			result.AsPromise().Settle(p.Result())
+		}()
+	}
+
+	return result
+}
+
+// timeout settles with the result of the promise, or with a runtime error
+// if it doesn't settle within the given number of seconds
+func timeout(ctx object.CallContext, args ...object.Value) object.Value {
+	err := errors.ExpectNumberOfArguments(ctx.Line, ctx.Col, 2, args)
+	if err.IsError() {
+		return err
+	}
+
+	err = errors.ExpectOneOfTypes(ctx.Line, ctx.Col, args[1], object.IntKind, object.FloatKind)
+	if err.IsError() {
+		return err
+	}
+
+	var d time.Duration
+	switch args[1].Kind() {
+	case object.IntKind:
+		d = time.Duration(args[1].AsInt()) * time.Second
+	case object.FloatKind:
+		d = time.Duration(args[1].AsFloat() * float64(time.Second))
+	}
+
+	p := toPromise(args[0])
+	result := object.NewPromise()
+	go func() {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-p.Done():
+			result.AsPromise().Settle(p.Result())
+		case <-timer.C:
+			result.AsPromise().Settle(
+				errors.NewRuntimeError(ctx.Line, ctx.Col, "promise timed out after %s", d),
+			)
+		}
+	}()
+
+	return result
+}
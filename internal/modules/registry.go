@@ -1,6 +1,10 @@
 package modules
 
 import (
+	"fmt"
+	"slices"
+	"sync"
+
 	"github.com/radeqq007/sunbird/internal/modules/array"
 	"github.com/radeqq007/sunbird/internal/modules/errors"
 	"github.com/radeqq007/sunbird/internal/modules/fs"
@@ -8,10 +12,12 @@ import (
 	"github.com/radeqq007/sunbird/internal/modules/io"
 	"github.com/radeqq007/sunbird/internal/modules/json"
 	"github.com/radeqq007/sunbird/internal/modules/math"
+	"github.com/radeqq007/sunbird/internal/modules/promise"
 	"github.com/radeqq007/sunbird/internal/modules/random"
 	"github.com/radeqq007/sunbird/internal/modules/str"
 	"github.com/radeqq007/sunbird/internal/modules/time"
 	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/sandbox"
 )
 
 func init() {
@@ -25,9 +31,13 @@ func init() {
 	registerModule("http", http.New())
 	registerModule("fs", fs.New())
 	registerModule("time", time.New())
+	registerModule("promise", promise.New())
 }
 
-var BuiltinModules = make(map[string]object.Value)
+var (
+	BuiltinModules = make(map[string]object.Value)
+	mu             sync.RWMutex
+)
 
 func registerModule(name string, module object.Value) {
 	if module.IsModule() {
@@ -37,7 +47,56 @@ func registerModule(name string, module object.Value) {
 	}
 }
 
+// Register adds a built-in module at runtime, e.g. from a program embedding
+// the interpreter. It fails if a module with the same name already exists.
+func Register(name string, module object.Value) error {
+	if !module.IsModule() {
+		return fmt.Errorf("expected a module, got %s", module.Kind())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := BuiltinModules[name]; ok {
+		return fmt.Errorf("module %q is already registered", name)
+	}
+
+	registerModule(name, module)
+	return nil
+}
+
 func Get(name string) (object.Value, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
 	module, ok := BuiltinModules[name]
 	return module, ok
 }
+
+// Names returns the names of all built-in modules in alphabetical order
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(BuiltinModules))
+	for name := range BuiltinModules {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Import looks up the built-in module called name, failing if policy
+// doesn't allow scripts to import it
+func Import(name string, policy *sandbox.Policy) (object.Value, bool, error) {
+	module, ok := Get(name)
+	if !ok {
+		return object.NewNull(), false, nil
+	}
+
+	if err := policy.CheckImport(name); err != nil {
+		return object.NewNull(), true, err
+	}
+
+	return module, true, nil
+}
@@ -73,7 +73,7 @@ func sleep(ctx object.CallContext, args ...object.Value) object.Value {
 		d = time.Duration(args[0].AsFloat() * float64(time.Second))
 	}
 
-	time.Sleep(d)
+	ctx.Runtime().Sleep(d)
 	return object.NewNull()
 }
 
@@ -1,17 +1,109 @@
 package object
 
+import (
+	"maps"
+	"path/filepath"
+
+	"github.com/radeqq007/sunbird/internal/ast"
+)
+
+// Deferred is the body of a defer statement together with the scope it was declared in
+type Deferred struct {
+	Body ast.Node
+	Env  *Environment
+}
+
 type Environment struct {
 	store     map[string]Value
 	constants map[string]bool
-	exports   map[string]bool
+	exports   map[string]string // names exported, and the variables they export
+	reexports map[string]Value
 	outer     *Environment
+	runtime   *Runtime
+	depth     int
+	file      string
+
+	// Set on the environment holding the parameters of a function call
+	isFunctionScope bool
+	deferred        []Deferred
 }
 
 func NewEnvironment() *Environment {
+	return NewRuntimeEnvironment(NewRuntime())
+}
+
+// NewRuntimeEnvironment creates a top-level environment that shares an existing runtime,
+// e.g. for a module imported by a running program
+func NewRuntimeEnvironment(rt *Runtime) *Environment {
 	s := make(map[string]Value)
 	c := make(map[string]bool)
-	e := make(map[string]bool)
-	return &Environment{store: s, constants: c, exports: e}
+	e := make(map[string]string)
+	return &Environment{store: s, constants: c, exports: e, runtime: rt}
+}
+
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
+}
+
+// File is the absolute path of the source file the environment's code comes from,
+// or "" if it doesn't come from a file, e.g. in the REPL
+func (e *Environment) File() string {
+	return e.file
+}
+
+// Dir is the directory relative paths are resolved from: the directory of the
+// environment's file, or "" (the working directory) if there is none
+func (e *Environment) Dir() string {
+	if e.file == "" {
+		return ""
+	}
+	return filepath.Dir(e.file)
+}
+
+// SetFile records that the code evaluated in the environment comes from the
+// file at path, which scripts can read as __file__ and __dir__
+func (e *Environment) SetFile(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	e.file = path
+	e.SetConst("__file__", NewString(path))
+	e.SetConst("__dir__", NewString(filepath.Dir(path)))
+}
+
+// Depth is the number of function calls active when the environment was created
+func (e *Environment) Depth() int {
+	return e.depth
+}
+
+func (e *Environment) SetDepth(depth int) {
+	e.depth = depth
+}
+
+// MarkAsFunctionScope makes e the scope that collects deferred expressions
+// of the function call it belongs to
+func (e *Environment) MarkAsFunctionScope() {
+	e.isFunctionScope = true
+}
+
+// Defer registers body to run when the enclosing function returns.
+// It reports false if e isn't inside a function.
+func (e *Environment) Defer(body ast.Node) bool {
+	for scope := e; scope != nil; scope = scope.outer {
+		if scope.isFunctionScope {
+			scope.deferred = append(scope.deferred, Deferred{Body: body, Env: e})
+			return true
+		}
+	}
+	return false
+}
+
+// TakeDeferred returns the expressions deferred in the function scope e and forgets them
+func (e *Environment) TakeDeferred() []Deferred {
+	deferred := e.deferred
+	e.deferred = nil
+	return deferred
 }
 
 func (e *Environment) Get(name string) (Value, bool) {
@@ -73,24 +165,54 @@ func (e *Environment) GetStore() map[string]Value {
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
+	env := NewRuntimeEnvironment(outer.runtime)
 	env.outer = outer
+	env.depth = outer.depth
+	env.file = outer.file
 	return env
 }
 
 func (e *Environment) MarkAsExported(name string) {
-	e.exports[name] = true
+	e.exports[name] = name
+}
+
+// ExportAs exports the variable called name under another name, which a variable
+// can have several of. It reports false if as already exports another variable.
+func (e *Environment) ExportAs(name, as string) bool {
+	if exported, ok := e.exports[as]; ok && exported != name {
+		return false
+	}
+	e.exports[as] = name
+	return true
 }
 
 func (e *Environment) IsExported(name string) bool {
-	return e.exports[name]
+	for _, exported := range e.exports {
+		if exported == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Reexport exports a value of another module. Variables
+// exported under the same name take precedence.
+func (e *Environment) Reexport(name string, val Value) {
+	if e.reexports == nil {
+		e.reexports = make(map[string]Value)
+	}
+	e.reexports[name] = val
 }
 
 func (e *Environment) GetExports() map[string]Value {
-	exports := make(map[string]Value)
-	for name, value := range e.store {
-		if e.exports[name] {
-			exports[name] = value
+	exports := maps.Clone(e.reexports)
+	if exports == nil {
+		exports = make(map[string]Value)
+	}
+
+	for as, name := range e.exports {
+		if value, ok := e.Get(name); ok {
+			exports[as] = value
 		}
 	}
 	return exports
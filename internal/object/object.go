@@ -12,7 +12,7 @@ import (
 )
 
 // ApplyFunction is a hook to allow calling functions from modules
-var ApplyFunction func(fn Value, args []Value) Value
+var ApplyFunction func(ctx CallContext, fn Value, args []Value) Value
 
 type ValueKind uint8
 
@@ -32,6 +32,7 @@ const (
 	ContinueKind
 	RangeKind
 	ModuleKind
+	PromiseKind
 )
 
 func (vk ValueKind) String() string {
@@ -66,6 +67,8 @@ func (vk ValueKind) String() string {
 		return "Range"
 	case ModuleKind:
 		return "Module"
+	case PromiseKind:
+		return "Promise"
 	default:
 		return "Unknown"
 	}
@@ -90,6 +93,10 @@ type Array struct {
 type Hash struct {
 	Pairs map[HashKey]HashPair
 	Proto *Hash
+
+	// Native is the Go value behind a hash made by a module, like the router
+	// of an HTTP server, for the module to find it again. Scripts can't see it.
+	Native any
 }
 
 type HashKey struct {
@@ -106,17 +113,27 @@ type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	IsAsync    bool
 }
 
 type CallContext struct {
 	Line int
 	Col  int
+	Env  *Environment // environment of the caller, nil when called from Go
 }
 
 func NewCallContext(line, col int) CallContext {
 	return CallContext{Line: line, Col: col}
 }
 
+// Runtime returns the runtime of the caller, or nil if there is none
+func (ctx CallContext) Runtime() *Runtime {
+	if ctx.Env == nil {
+		return nil
+	}
+	return ctx.Env.Runtime()
+}
+
 type BuiltinFunction func(ctx CallContext, args ...Value) Value
 
 type Builtin struct {
@@ -125,6 +142,16 @@ type Builtin struct {
 
 type ReturnValue struct {
 	Value Value
+	// Tail is set when the statement returned the result of a call in tail
+	// position. The call is made by the caller once the current frame is gone.
+	Tail *TailCall
+}
+
+type TailCall struct {
+	Fn        Value
+	Args      []Value
+	Env       *Environment
+	Line, Col int
 }
 
 type Error struct {
@@ -236,6 +263,13 @@ func (v Value) Inspect() string {
 		m := v.AsModule()
 		return "<module " + m.Name + ">"
 
+	case PromiseKind:
+		p := v.AsPromise()
+		if !p.Settled() {
+			return "<promise pending>"
+		}
+		return "<promise " + p.Result().Inspect() + ">"
+
 	default:
 		return "unknown"
 	}
@@ -270,6 +304,7 @@ func (v Value) IsBuiltin() bool  { return v.kind == BuiltinKind }
 func (v Value) IsError() bool    { return v.kind == ErrorKind }
 func (v Value) IsRange() bool    { return v.kind == RangeKind }
 func (v Value) IsModule() bool   { return v.kind == ModuleKind }
+func (v Value) IsPromise() bool  { return v.kind == PromiseKind }
 
 // Getters
 func (v Value) AsInt() int64 {
@@ -320,6 +355,10 @@ func (v Value) AsModule() *Module {
 	return (*Module)(v.ptr)
 }
 
+func (v Value) AsPromise() *Promise {
+	return (*Promise)(v.ptr)
+}
+
 func NewInt(val int64) Value {
 	return Value{kind: IntKind, bits: uint64(val)}
 }
@@ -409,6 +448,18 @@ func NewReturnValue(val Value) Value {
 	}
 }
 
+// NewTailCall returns a return value that still has to make the call fn(args...)
+func NewTailCall(fn Value, args []Value, env *Environment, line, col int) Value {
+	rv := &ReturnValue{
+		Value: NewNull(),
+		Tail:  &TailCall{Fn: fn, Args: args, Env: env, Line: line, Col: col},
+	}
+	return Value{
+		kind: ReturnValueKind,
+		ptr:  unsafe.Pointer(rv),
+	}
+}
+
 func NewError(message string, line, col int, propagating bool) Value {
 	err := &Error{
 		Message:     message,
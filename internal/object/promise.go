@@ -0,0 +1,59 @@
+package object
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// Promise is the eventual result of an async function call.
+// A rejected promise is simply one that settled with an error value.
+type Promise struct {
+	once  sync.Once
+	done  chan struct{}
+	value Value
+}
+
+func NewPromise() Value {
+	p := &Promise{done: make(chan struct{})}
+	return Value{
+		kind: PromiseKind,
+		ptr:  unsafe.Pointer(p),
+	}
+}
+
+// NewResolvedPromise returns a promise that is already settled with val
+func NewResolvedPromise(val Value) Value {
+	p := NewPromise()
+	p.AsPromise().Settle(val)
+	return p
+}
+
+// Settle sets the result of the promise. Only the first call has any effect.
+func (p *Promise) Settle(val Value) {
+	p.once.Do(func() {
+		p.value = val
+		close(p.done)
+	})
+}
+
+// Done returns a channel that is closed once the promise has settled
+func (p *Promise) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *Promise) Settled() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Result returns the settled value, or null if the promise is still pending
+func (p *Promise) Result() Value {
+	if !p.Settled() {
+		return NewNull()
+	}
+	return p.value
+}
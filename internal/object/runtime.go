@@ -0,0 +1,251 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/radeqq007/sunbird/internal/sandbox"
+)
+
+// DefaultMaxDepth is the call depth allowed when no limit is configured.
+// It stays well below the point where the Go stack itself would overflow.
+const DefaultMaxDepth = 10_000
+
+// ErrStepLimit is reported by Step once the step budget is used up
+var ErrStepLimit = errors.New("step budget exhausted")
+
+// Limits bounds the resources a run may use.
+// A MaxSteps of zero means there is no step budget.
+type Limits struct {
+	MaxDepth int
+	MaxSteps int64
+}
+
+// Runtime is the state shared by every environment taking part in a single
+// interpreter run.
+//
+// It also acts as the event loop: async tasks run on their own goroutines,
+// but only the task holding the runtime lock may evaluate Sunbird code.
+// A task gives the lock up while it awaits a promise or blocks on I/O,
+// which lets other tasks make progress in the meantime.
+type Runtime struct {
+	mu    sync.Mutex
+	tasks sync.WaitGroup
+
+	ctx    context.Context
+	limits Limits
+	steps  int64
+	err    error
+	policy *sandbox.Policy
+
+	// Modules registered by the host program or imported during the run,
+	// by import path, or by absolute path for files
+	modules map[string]Value
+
+	// Files whose modules are being loaded, outermost first
+	importing []string
+}
+
+func NewRuntime() *Runtime {
+	return &Runtime{
+		ctx:     context.Background(),
+		limits:  Limits{MaxDepth: DefaultMaxDepth},
+		modules: make(map[string]Value),
+	}
+}
+
+// Acquire takes the runtime lock. It must be called before evaluating code
+// on a goroutine that does not hold the lock yet, e.g. an HTTP handler.
+func (rt *Runtime) Acquire() {
+	if rt != nil {
+		rt.mu.Lock()
+	}
+}
+
+func (rt *Runtime) Release() {
+	if rt != nil {
+		rt.mu.Unlock()
+	}
+}
+
+// Begin starts a new run: it installs ctx as the run's context
+// and resets the step budget.
+func (rt *Runtime) Begin(ctx context.Context) {
+	if rt == nil {
+		return
+	}
+
+	rt.ctx = ctx
+	rt.steps = 0
+	rt.err = nil
+}
+
+// Context returns the context of the current run
+func (rt *Runtime) Context() context.Context {
+	if rt == nil || rt.ctx == nil {
+		return context.Background()
+	}
+	return rt.ctx
+}
+
+func (rt *Runtime) Limits() Limits {
+	if rt == nil {
+		return Limits{MaxDepth: DefaultMaxDepth}
+	}
+	return rt.limits
+}
+
+// SetLimits replaces the limits of the runtime.
+// A non-positive MaxDepth falls back to DefaultMaxDepth.
+func (rt *Runtime) SetLimits(limits Limits) {
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = DefaultMaxDepth
+	}
+	rt.limits = limits
+}
+
+// Policy returns the sandbox policy of the run. A nil policy allows everything.
+func (rt *Runtime) Policy() *sandbox.Policy {
+	if rt == nil {
+		return nil
+	}
+	return rt.policy
+}
+
+func (rt *Runtime) SetPolicy(policy *sandbox.Policy) {
+	rt.policy = policy
+}
+
+// Module looks up a module registered by the host program
+// or already imported during the run
+func (rt *Runtime) Module(path string) (Value, bool) {
+	if rt == nil {
+		return NewNull(), false
+	}
+
+	module, ok := rt.modules[path]
+	return module, ok
+}
+
+// SetModule makes module importable under path
+func (rt *Runtime) SetModule(path string, module Value) {
+	rt.modules[path] = module
+}
+
+// BeginImport records that the module in the file at path is being loaded until
+// the returned function is called. If it's being loaded already, the module
+// imports itself; BeginImport then returns the chain of imports that led back to it.
+func (rt *Runtime) BeginImport(path string) (func(), []string) {
+	if slices.Contains(rt.importing, path) {
+		return func() {}, append(slices.Clone(rt.importing), path)
+	}
+
+	rt.importing = append(rt.importing, path)
+	return func() {
+		rt.importing = rt.importing[:len(rt.importing)-1]
+	}, nil
+}
+
+// Importing reports whether any module is being loaded
+func (rt *Runtime) Importing() bool {
+	return len(rt.importing) > 0
+}
+
+// Step counts one evaluation step and reports why the run has to stop,
+// if it does. Once a limit is hit every following step fails as well,
+// so the error cannot be swallowed by a catch block.
+func (rt *Runtime) Step() error {
+	if rt == nil {
+		return nil
+	}
+
+	if rt.err != nil {
+		return rt.err
+	}
+
+	rt.steps++
+	if rt.limits.MaxSteps > 0 && rt.steps > rt.limits.MaxSteps {
+		rt.err = ErrStepLimit
+		return rt.err
+	}
+
+	// Checking the context is comparatively slow, so only do it every now and then
+	if rt.steps%1024 == 0 {
+		rt.err = rt.Context().Err()
+	}
+
+	return rt.err
+}
+
+// Block runs fn with the runtime lock released so other tasks can run
+// while fn waits on something outside the interpreter.
+func (rt *Runtime) Block(fn func()) {
+	if rt == nil {
+		fn()
+		return
+	}
+
+	rt.mu.Unlock()
+	defer rt.mu.Lock()
+	fn()
+}
+
+// Go schedules fn as a new task on the event loop
+func (rt *Runtime) Go(fn func()) {
+	if rt == nil {
+		fn()
+		return
+	}
+
+	rt.tasks.Add(1)
+	go func() {
+		defer rt.tasks.Done()
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+		fn()
+	}()
+}
+
+// Wait blocks until every scheduled task has finished
+func (rt *Runtime) Wait() {
+	if rt == nil {
+		return
+	}
+	rt.Block(rt.tasks.Wait)
+}
+
+// Await blocks the current task until p settles and returns its result.
+// It gives up early with the context's error if the run is cancelled.
+func (rt *Runtime) Await(p *Promise) (Value, error) {
+	if !p.Settled() {
+		done := rt.Context().Done()
+		rt.Block(func() {
+			select {
+			case <-p.Done():
+			case <-done:
+			}
+		})
+	}
+
+	if !p.Settled() {
+		return NewNull(), rt.Context().Err()
+	}
+	return p.Result(), nil
+}
+
+// Sleep pauses the current task for d, returning early if the run is cancelled
+func (rt *Runtime) Sleep(d time.Duration) {
+	done := rt.Context().Done()
+	rt.Block(func() {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-done:
+		}
+	})
+}
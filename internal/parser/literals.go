@@ -112,6 +112,21 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseAsyncFunctionLiteral() ast.Expression {
+	if !p.expectPeek(token.Function) {
+		return nil
+	}
+
+	lit, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+	if !ok {
+		return nil
+	}
+
+	lit.IsAsync = true
+
+	return lit
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
@@ -19,6 +19,16 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseAwaitExpression() ast.Expression {
+	expression := &ast.AwaitExpression{Token: p.curToken}
+
+	p.nextToken()
+
+	expression.Value = p.parseExpression(PREFIX)
+
+	return expression
+}
+
 // Infix
 var precedences = map[token.TokenType]int{
 	token.Assign:        ASSIGN,
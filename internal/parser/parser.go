@@ -53,6 +53,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LParen, p.parseGroupedExpression)
 	p.registerPrefix(token.If, p.parseIfExpression)
 	p.registerPrefix(token.Function, p.parseFunctionLiteral)
+	p.registerPrefix(token.Async, p.parseAsyncFunctionLiteral)
+	p.registerPrefix(token.Await, p.parseAwaitExpression)
 	p.registerPrefix(token.LBracket, p.parseArrayLiteral)
 	p.registerPrefix(token.LBrace, p.parseHashLiteral)
 	p.registerPrefix(token.Null, p.parseNullLiteral)
@@ -1313,3 +1313,150 @@ func TestExportInvalidTarget(t *testing.T) {
 		t.Error("expected parser errors for invalid export target, got none")
 	}
 }
+
+func TestSelectiveImportsAndExports(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import { get, post as p } from "http"`, "import { get, post as p } from http;"},
+		{`import { get, } from "http"`, "import { get } from http;"},
+		{`import { } from "http"`, "import {  } from http;"},
+		{`import * from "math"`, "import * from math;"},
+		{"import {\n  a,\n  b\n} from \"./utils\"", "import { a, b } from ./utils;"},
+		{`export { a, b as c }`, "export { a, b as c };"},
+		{`export * from "./utils"`, "export * from ./utils;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("expected 1 statement, got %d", len(program.Statements))
+		}
+
+		if got := program.Statements[0].String(); got != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, got)
+		}
+	}
+
+	invalid := []string{
+		`import { get } "http"`,
+		`import { get as } from "http"`,
+		`import * "math"`,
+		`import { a b } from "x"`,
+		`export * "./utils"`,
+		`export { 1 }`,
+	}
+
+	for _, input := range invalid {
+		p := parser.New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q, got none", input)
+		}
+	}
+}
+
+func TestAsyncFunctionLiteralParsing(t *testing.T) {
+	input := "async fn(x) { await x; }"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+
+	if !function.IsAsync {
+		t.Errorf("function.IsAsync is false")
+	}
+
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+	}
+
+	await, ok := bodyStmt.Expression.(*ast.AwaitExpression)
+	if !ok {
+		t.Fatalf("body expression is not ast.AwaitExpression. got=%T", bodyStmt.Expression)
+	}
+
+	testLiteralExpression(t, await.Value, "x")
+
+	if function.String() != "async fn(x) (await x)" {
+		t.Errorf("function.String() wrong. got=%q", function.String())
+	}
+}
+
+func TestAwaitPrecedence(t *testing.T) {
+	input := "await a + await b.c()"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := "((await a) + (await (b.c)()))"
+	if program.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestAsyncWithoutFunction(t *testing.T) {
+	l := lexer.New("async x")
+	p := parser.New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Error("expected parser errors for async without fn, got none")
+	}
+}
+
+func TestDeferStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		isBlock  bool
+	}{
+		{`defer close(f)`, "defer close(f)", false},
+		{`defer io.println("done");`, "defer (io.println)(done)", false},
+		{`defer { x = 1 }`, "defer x = 1;", true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("expected 1 statement, got %d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.DeferStatement)
+		if !ok {
+			t.Fatalf("statement is not *ast.DeferStatement, got=%T", program.Statements[0])
+		}
+
+		if _, ok := stmt.Body.(*ast.BlockStatement); ok != tt.isBlock {
+			t.Errorf("wrong body type for %q, got=%T", tt.input, stmt.Body)
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
@@ -37,6 +37,9 @@ func (p *Parser) parseStatement() ast.Statement {
 	case token.Try:
 		return p.parseTryCatchStatement()
 
+	case token.Defer:
+		return p.parseDeferStatement()
+
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -132,6 +135,27 @@ func (p *Parser) parseLoopStatement() *ast.LoopStatement {
 	return stmt
 }
 
+func (p *Parser) parseDeferStatement() *ast.DeferStatement {
+	stmt := &ast.DeferStatement{Token: p.curToken}
+	p.nextToken()
+
+	if p.curTokenIs(token.LBrace) {
+		stmt.Body = p.parseBlockStatement()
+	} else {
+		stmt.Body = p.parseExpression(LOWEST)
+	}
+
+	if stmt.Body == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseDeclarationExpression(left ast.Expression) ast.Expression {
 	exp := &ast.DeclarationExpression{
 		Token:   p.curToken,
@@ -154,13 +178,31 @@ func (p *Parser) parseDeclarationExpression(left ast.Expression) ast.Expression
 func (p *Parser) parseImportStatement() *ast.ImportStatement {
 	stmt := &ast.ImportStatement{Token: p.curToken}
 
+	switch {
+	case p.peekTokenIs(token.LBrace):
+		p.nextToken()
+		members, ok := p.parseModuleMembers()
+		if !ok || !p.expectPeek(token.From) {
+			return nil
+		}
+		stmt.Members = members
+
+	case p.peekTokenIs(token.Asterisk):
+		p.nextToken()
+		if !p.expectPeek(token.From) {
+			return nil
+		}
+		stmt.Wildcard = true
+	}
+
 	if !p.expectPeek(token.String) {
 		return nil
 	}
 
 	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 
-	if p.peekTokenIs(token.As) {
+	// Aliases only make sense when binding the whole module
+	if stmt.Members == nil && !stmt.Wildcard && p.peekTokenIs(token.As) {
 		p.nextToken()
 		if !p.expectPeek(token.Ident) {
 			return nil
@@ -175,9 +217,67 @@ func (p *Parser) parseImportStatement() *ast.ImportStatement {
 	return stmt
 }
 
+// parseModuleMembers parses the braced list of names of an import or export statement,
+// e.g. `{ get, post as p }`. The current token is the opening brace.
+func (p *Parser) parseModuleMembers() ([]*ast.ModuleMember, bool) {
+	members := []*ast.ModuleMember{}
+
+	for !p.peekTokenIs(token.RBrace) {
+		if !p.expectPeek(token.Ident) {
+			return nil, false
+		}
+		member := &ast.ModuleMember{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+
+		if p.peekTokenIs(token.As) {
+			p.nextToken()
+			if !p.expectPeek(token.Ident) {
+				return nil, false
+			}
+			member.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		}
+
+		members = append(members, member)
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBrace) {
+		return nil, false
+	}
+
+	return members, true
+}
+
 func (p *Parser) parseExportStatement() *ast.ExportStatement {
 	stmt := &ast.ExportStatement{Token: p.curToken}
 
+	switch {
+	case p.peekTokenIs(token.LBrace):
+		p.nextToken()
+		members, ok := p.parseModuleMembers()
+		if !ok {
+			return nil
+		}
+		stmt.Members = members
+
+	case p.peekTokenIs(token.Asterisk):
+		p.nextToken()
+		if !p.expectPeek(token.From) || !p.expectPeek(token.String) {
+			return nil
+		}
+		stmt.From = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if stmt.Members != nil || stmt.From != nil {
+		if p.peekTokenIs(token.Semicolon) {
+			p.nextToken() // skip the ;
+		}
+		return stmt
+	}
+
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
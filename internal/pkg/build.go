@@ -0,0 +1,425 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/radeqq007/sunbird/internal/modules"
+)
+
+// bundleMagic ends every executable built by 'sunbird build'. It follows the
+// files of the program, stored as a zip archive, and the size of that archive.
+const bundleMagic = "\x00sunbird-bundle1"
+
+// BundleManifest says how to run the program in a bundle.
+// It's stored as the comment of the zip archive.
+type BundleManifest struct {
+	// Entry is the file to run, relative to the root of the bundle
+	Entry string `toml:"entry"`
+
+	// Project is the directory of the project's sunbird.toml in the bundle,
+	// which the directories of the sandbox are relative to
+	Project string `toml:"project"`
+
+	Sandbox *SandboxConfig `toml:"sandbox,omitempty"`
+}
+
+// Bundle is a program built by 'sunbird build', read from its executable
+type Bundle struct {
+	BundleManifest
+
+	// Files holds the files of the program, at slash-separated paths
+	// relative to the root of the bundle
+	Files *zip.Reader
+
+	file *os.File
+}
+
+func (b *Bundle) Close() error {
+	return b.file.Close()
+}
+
+// OpenBundle reads the bundle at the end of the executable at path.
+// It returns nil if the executable doesn't carry one.
+func OpenBundle(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, size, ok, err := findBundle(f)
+	if err != nil || !ok {
+		f.Close()
+		return nil, err
+	}
+
+	files, err := zip.NewReader(io.NewSectionReader(f, offset, size), size)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("invalid bundle in %s: %w", path, err)
+	}
+
+	bundle := &Bundle{Files: files, file: f}
+	if _, err := toml.Decode(files.Comment, &bundle.BundleManifest); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("invalid bundle in %s: %w", path, err)
+	}
+	return bundle, nil
+}
+
+// findBundle locates the zip archive at the end of the executable f.
+// Without one, ok is false and offset is the size of f.
+func findBundle(f *os.File) (offset, size int64, ok bool, err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	trailer := make([]byte, 8+len(bundleMagic))
+	end := info.Size() - int64(len(trailer))
+	if end < 0 {
+		return info.Size(), 0, false, nil
+	}
+
+	if _, err := f.ReadAt(trailer, end); err != nil {
+		return 0, 0, false, err
+	}
+	if string(trailer[8:]) != bundleMagic {
+		return info.Size(), 0, false, nil
+	}
+
+	size = int64(binary.LittleEndian.Uint64(trailer[:8]))
+	if size < 0 || size > end {
+		return 0, 0, false, fmt.Errorf("invalid bundle in %s", f.Name())
+	}
+	return end - size, size, true, nil
+}
+
+// Build bundles entry, every file it imports and the packages they use into
+// a single executable at out, which runs entry when started, and returns its
+// path. The executable is a copy of pm.Sunbird with the files appended, so it
+// runs wherever that executable does. Files and directories matching the glob
+// patterns of include are bundled as well, for scripts that read them with
+// the fs module. By default, entry is the main file in sunbird.toml and the
+// executable is named after the project.
+func (pm *PackageManager) Build(entry, out string, include []string) (string, error) {
+	config, err := LoadConfig(pm.configPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		config = &Config{}
+	} else if err != nil {
+		return "", err
+	}
+
+	dir, err := filepath.Abs(pm.Dir)
+	if err != nil {
+		return "", err
+	}
+
+	if entry == "" {
+		entry = config.Package.Main
+	}
+	if entry == "" {
+		return "", errors.New("no file to build given, and sunbird.toml has no main file")
+	}
+	entry = resolveConfigPath(dir, entry)
+
+	if out == "" {
+		name := config.Package.Name
+		if name == "" {
+			name = filepath.Base(dir)
+		}
+		out = filepath.Join(dir, name)
+	}
+
+	if pm.Sunbird == "" {
+		return "", errors.New("can't find the sunbird executable to build with")
+	}
+
+	b := &bundler{packages: make(map[string]*bundledPackage), seen: make(map[string]bool)}
+	if err := b.addFile(entry, ""); err != nil {
+		return "", err
+	}
+	for _, pattern := range include {
+		if err := b.include(dir, pattern); err != nil {
+			return "", err
+		}
+	}
+
+	files, manifest, err := b.layout(dir, entry)
+	if err != nil {
+		return "", err
+	}
+	manifest.Sandbox = config.Sandbox
+
+	if err := writeBundle(out, pm.Sunbird, files, manifest); err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(pm.Out, "  ✓ %s (%d files, %d packages) -> %s\n",
+		filepath.Base(entry), len(b.project), len(b.packages), out)
+	return out, nil
+}
+
+// bundler collects the files of a program
+type bundler struct {
+	project  []string // files of the project, as absolute paths
+	packages map[string]*bundledPackage
+	seen     map[string]bool
+}
+
+// bundledPackage is a package the program imports, by the name it's imported as
+type bundledPackage struct {
+	dir    string
+	config *Config
+	files  []string
+}
+
+// addFile adds the file at path and everything it imports. The file belongs to
+// the package called owner, or to the project if owner is empty. Like 'sunbird
+// tidy', it only follows imports at the top level of files.
+func (b *bundler) addFile(path, owner string) error {
+	if b.seen[owner+":"+path] {
+		return nil
+	}
+	b.seen[owner+":"+path] = true
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Files that can't be parsed wouldn't run, so they aren't bundled
+	imports, err := fileImports(path, string(src))
+	if err != nil {
+		return err
+	}
+
+	if owner == "" {
+		b.project = append(b.project, path)
+	} else {
+		p := b.packages[owner]
+		if !isWithin(p.dir, path) {
+			return fmt.Errorf("package %s imports %s, which is outside of it", owner, path)
+		}
+		p.files = append(p.files, path)
+	}
+
+	dir := filepath.Dir(path)
+	for _, imported := range imports {
+		if _, builtin := modules.Get(imported); builtin {
+			continue
+		}
+
+		// Packages are looked up the way the module loader does
+		if packageDir, ok := FindPackage(dir, imported); ok {
+			if err := b.addPackage(imported, packageDir); err != nil {
+				return err
+			}
+			continue
+		}
+
+		target, ok := importedFile(dir, imported)
+		if !ok {
+			return fmt.Errorf("%s: module not found: %s", path, imported)
+		}
+		if err := b.addFile(target, owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importedFile finds the file imported as name from dir, with or without the .sb extension
+func importedFile(dir, name string) (string, bool) {
+	target := resolveConfigPath(dir, name)
+	for _, candidate := range []string{target, target + ".sb"} {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+func (b *bundler) addPackage(name, dir string) error {
+	if p, ok := b.packages[name]; ok {
+		if p.dir != dir {
+			return fmt.Errorf("both %s and %s are imported as %s", p.dir, dir, name)
+		}
+		return nil
+	}
+
+	config, err := LoadConfig(filepath.Join(dir, "sunbird.toml"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if config.Package.Main == "" {
+		return fmt.Errorf("%s: package has no main file", name)
+	}
+
+	b.packages[name] = &bundledPackage{dir: dir, config: config}
+	return b.addFile(filepath.Join(dir, config.Package.Main), name)
+}
+
+// include adds the files matching pattern, relative to dir, without following imports
+func (b *bundler) include(dir, pattern string) error {
+	matches, err := filepath.Glob(resolveConfigPath(dir, pattern))
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("%s matches no files", pattern)
+	}
+
+	for _, match := range matches {
+		err := filepath.WalkDir(match, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.Type().IsRegular() || b.seen[":"+p] {
+				return err
+			}
+			b.seen[":"+p] = true
+			b.project = append(b.project, p)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// layout decides where the collected files go in the bundle. Files of the project
+// keep their places relative to each other, under the closest directory holding
+// all of them and the project. Packages go in its .sb_modules, each with a
+// sunbird.toml giving only its name and main file, so that the module loader
+// finds them by the name they're imported as.
+func (b *bundler) layout(project, entry string) (map[string][]byte, BundleManifest, error) {
+	root := project
+	for _, file := range b.project {
+		for !isWithin(root, file) {
+			root = filepath.Dir(root)
+		}
+	}
+
+	files := make(map[string][]byte)
+	add := func(name, file string) error {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		files[name] = data
+		return nil
+	}
+
+	relative := func(dir, file string) string {
+		rel, _ := filepath.Rel(dir, file)
+		return filepath.ToSlash(rel)
+	}
+
+	for _, file := range b.project {
+		if err := add(relative(root, file), file); err != nil {
+			return nil, BundleManifest{}, err
+		}
+	}
+
+	for name, p := range b.packages {
+		dir := path.Join(strings.TrimSuffix(dependencyDirectory, "/"), name)
+		for _, file := range p.files {
+			if err := add(path.Join(dir, relative(p.dir, file)), file); err != nil {
+				return nil, BundleManifest{}, err
+			}
+		}
+
+		config := Config{Package: PackageInfo{
+			Name:    p.config.Package.Name,
+			Version: p.config.Package.Version,
+			Main:    relative(p.dir, filepath.Join(p.dir, p.config.Package.Main)),
+		}}
+
+		var data bytes.Buffer
+		if err := toml.NewEncoder(&data).Encode(config); err != nil {
+			return nil, BundleManifest{}, err
+		}
+		files[path.Join(dir, "sunbird.toml")] = data.Bytes()
+	}
+
+	manifest := BundleManifest{Entry: relative(root, entry), Project: relative(root, project)}
+	return files, manifest, nil
+}
+
+// writeBundle writes the executable at runtime followed by the bundle of files to out.
+// If runtime is a bundle itself, its files are left out.
+func writeBundle(out, runtime string, files map[string][]byte, manifest BundleManifest) error {
+	exe, err := os.Open(runtime)
+	if err != nil {
+		return err
+	}
+	defer exe.Close()
+
+	size, _, _, err := findBundle(exe)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(out), ".sunbird-build-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, io.NewSectionReader(exe, 0, size)); err != nil {
+		return err
+	}
+
+	var comment bytes.Buffer
+	if err := toml.NewEncoder(&comment).Encode(manifest); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(tmp)
+	if err := zw.SetComment(comment.String()); err != nil {
+		return err
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	end, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	trailer := binary.LittleEndian.AppendUint64(nil, uint64(end-size))
+	if _, err := tmp.Write(append(trailer, bundleMagic...)); err != nil {
+		return err
+	}
+
+	if err := tmp.Chmod(0o755); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), out)
+}
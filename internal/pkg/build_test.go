@@ -0,0 +1,156 @@
+package pkg_test
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/radeqq007/sunbird/internal/pkg"
+)
+
+// bundleFiles lists the files in the bundle of the executable at path
+func bundleFiles(t *testing.T, path string) (*pkg.Bundle, []string) {
+	t.Helper()
+
+	bundle, err := pkg.OpenBundle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle == nil {
+		t.Fatalf("%s has no bundle", path)
+	}
+	t.Cleanup(func() { bundle.Close() })
+
+	var names []string
+	err = fs.WalkDir(bundle.Files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			names = append(names, name)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundle, names
+}
+
+func TestBuild(t *testing.T) {
+	base := t.TempDir()
+	writeFiles(t, filepath.Join(base, "shared"), map[string]string{
+		"sunbird.toml":  "[package]\nname = \"shared\"\nmain = \"lib/main.sb\"\n\n[[package.dependencies]]\npath = \"../other\"\n",
+		"lib/main.sb":   `import "./helper"; export name :: helper.name`,
+		"lib/helper.sb": `export name :: "shared"`,
+		"unused.sb":     `export name :: "unused"`,
+	})
+
+	pm := newProject(t, pkg.DependencyInfo{Name: "shared", Path: filepath.Join(base, "shared")})
+	writeFiles(t, pm.Dir, map[string]string{
+		"main.sb":                            `import "io"; import "./src/util"; import "shared"; import "installed"`,
+		"src/util.sb":                        `load :: fn() { import "../../outside/extra"; return extra.name }`,
+		"src/unused.sb":                      `export name :: "unused"`,
+		"assets/index.html":                  "<h1>hi</h1>",
+		".sb_modules/installed/sunbird.toml": "[package]\nname = \"installed\"\nmain = \"main.sb\"\n",
+		".sb_modules/installed/main.sb":      `export name :: "installed"`,
+	})
+	writeFiles(t, filepath.Join(pm.Dir, "..", "outside"), map[string]string{"extra.sb": `export name :: "extra"`})
+
+	runtime := filepath.Join(base, "sunbird")
+	if err := os.WriteFile(runtime, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pm.Sunbird = runtime
+
+	out, err := pm.Build("", filepath.Join(base, "app"), []string{"assets"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files outside the project keep their places relative to it
+	project := filepath.Base(pm.Dir)
+	bundle, names := bundleFiles(t, out)
+	expected := []string{
+		".sb_modules/installed/main.sb",
+		".sb_modules/installed/sunbird.toml",
+		".sb_modules/shared/lib/helper.sb",
+		".sb_modules/shared/lib/main.sb",
+		".sb_modules/shared/sunbird.toml",
+		"outside/extra.sb",
+		project + "/assets/index.html",
+		project + "/main.sb",
+		project + "/src/util.sb",
+	}
+	slices.Sort(expected)
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected files %v, got %v", expected, names)
+	}
+
+	if bundle.Entry != project+"/main.sb" || bundle.Project != project {
+		t.Errorf("expected entry %s/main.sb in %s, got %s in %s", project, project, bundle.Entry, bundle.Project)
+	}
+
+	// Packages only keep their name and main file, since their dependencies are bundled
+	config, err := fs.ReadFile(bundle.Files, ".sb_modules/shared/sunbird.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(config), "other") || !strings.Contains(string(config), `main = "lib/main.sb"`) {
+		t.Errorf("unexpected sunbird.toml for shared:\n%s", config)
+	}
+
+	exe := readFile(t, out)
+	if !strings.HasPrefix(exe, "#!/bin/sh\n") {
+		t.Errorf("expected the executable to start with the runtime, got %q", exe[:10])
+	}
+
+	// Building with a bundled executable only keeps its runtime
+	pm.Sunbird = out
+	rebuilt, err := pm.Build("src/util.sb", filepath.Join(base, "util"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"outside/extra.sb", project + "/src/util.sb"}
+	slices.Sort(expected)
+	if _, names := bundleFiles(t, rebuilt); !slices.Equal(names, expected) {
+		t.Errorf("expected files %v, got %v", expected, names)
+	}
+	if !strings.HasPrefix(readFile(t, rebuilt), "#!/bin/sh\nPK") {
+		t.Errorf("expected the bundle to follow the runtime directly")
+	}
+
+	if bundle, err := pkg.OpenBundle(runtime); bundle != nil || err != nil {
+		t.Errorf("expected no bundle in the runtime, got %v, %v", bundle, err)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	pm := newProject(t)
+	pm.Sunbird = filepath.Join(pm.Dir, "main.sb")
+	pm.Out = io.Discard
+
+	writeFiles(t, pm.Dir, map[string]string{"main.sb": `import "missing"`})
+	if _, err := pm.Build("", "", nil); err == nil || !strings.Contains(err.Error(), "module not found: missing") {
+		t.Errorf("expected a module not found error, got %v", err)
+	}
+
+	writeFiles(t, pm.Dir, map[string]string{
+		"main.sb":       `import "./lib/broken"`,
+		"lib/broken.sb": `import { foo from "bar"`,
+	})
+	if _, err := pm.Build("", "", nil); err == nil || !strings.HasPrefix(err.Error(), filepath.Join(pm.Dir, "lib", "broken.sb")+": ") {
+		t.Errorf("expected a parse error naming lib/broken.sb, got %v", err)
+	}
+
+	writeFiles(t, pm.Dir, map[string]string{"main.sb": `x := (1`})
+	if _, err := pm.Build("", "", nil); err == nil || !strings.Contains(err.Error(), "main.sb: ") {
+		t.Errorf("expected a parse error for main.sb, got %v", err)
+	}
+
+	writeFiles(t, pm.Dir, map[string]string{"main.sb": `import "io"`})
+	if _, err := pm.Build("", "", []string{"*.txt"}); err == nil || !strings.Contains(err.Error(), "matches no files") {
+		t.Errorf("expected an error for a pattern without matches, got %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Cache keeps downloaded repositories and the files of installed packages
+// between runs. Repositories are stored by the hash of their URL and
+// packages by their checksum, so identical packages are only stored once.
+type Cache struct {
+	Dir string
+}
+
+// CacheEntry describes something stored in the cache
+type CacheEntry struct {
+	Kind string // "git" or "package"
+	Name string // URL of a repository, or name and checksum of a package
+	Size int64
+}
+
+// DefaultCacheDir is $SUNBIRD_CACHE, or the sunbird directory in the user's cache directory
+func DefaultCacheDir() (string, error) {
+	if dir := os.Getenv("SUNBIRD_CACHE"); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sunbird"), nil
+}
+
+func (c *Cache) repoDir(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, "git", hex.EncodeToString(sum[:]))
+}
+
+func (c *Cache) packageDir(checksum string) string {
+	return filepath.Join(c.Dir, "packages", strings.ReplaceAll(checksum, ":", "-"))
+}
+
+// repository opens the cached clone of the repository at url, fetching what's new
+// unless offline is set. Repositories that aren't cached yet are cloned.
+func (c *Cache) repository(url string, offline bool) (*git.Repository, error) {
+	dir := c.repoDir(url)
+
+	repo, err := git.PlainOpen(dir)
+	switch {
+	case err == nil && offline:
+		return repo, nil
+	case err == nil:
+		return repo, fetchRepository(repo, url)
+	case offline:
+		return nil, fmt.Errorf("%s isn't in the cache, run 'sunbird install' without --offline first", url)
+	}
+
+	// Clone next to the final directory, so that an interrupted clone is never used
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "clone-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if _, err := cloneRepository(url, filepath.Join(tmp, "repo")); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(filepath.Join(tmp, "repo"), dir); err != nil {
+		return nil, err
+	}
+
+	return git.PlainOpen(dir)
+}
+
+// fetchRepository brings the branches and tags of the cached clone up to date
+func fetchRepository(repo *git.Repository, url string) error {
+	err := repo.Fetch(&git.FetchOptions{
+		RefSpecs: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+		Tags:     git.AllTags,
+		Force:    true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+
+	// The clone only has a local branch for the default branch, which
+	// fetching doesn't move
+	head, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil || head.Type() != plumbing.SymbolicReference {
+		return err
+	}
+
+	remote, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", head.Target().Short()), true)
+	if err != nil {
+		return nil
+	}
+	return repo.Storer.SetReference(plumbing.NewHashReference(head.Target(), remote.Hash()))
+}
+
+// copyPackage copies the cached files of the package with the given checksum
+// to dir. It reports false if they aren't cached or have been changed.
+func (c *Cache) copyPackage(checksum, dir string) (bool, error) {
+	src := c.packageDir(checksum)
+	if _, err := os.Stat(src); err != nil {
+		return false, nil
+	}
+
+	if actual, err := checksumDir(src); err != nil || actual != checksum {
+		// Damaged entries are dropped and downloaded again
+		return false, os.RemoveAll(src)
+	}
+
+	return true, copyDirectory(src, dir)
+}
+
+// storePackage adds a copy of the package files in dir to the cache
+func (c *Cache) storePackage(checksum, dir string) error {
+	dst := c.packageDir(checksum)
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), "store-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := copyDirectory(dir, filepath.Join(tmp, "package")); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(tmp, "package"), dst)
+}
+
+// packageConfig reads the sunbird.toml of a cached package
+func (c *Cache) packageConfig(checksum string) (*Config, bool) {
+	config, err := LoadConfig(filepath.Join(c.packageDir(checksum), "sunbird.toml"))
+	return config, err == nil
+}
+
+// List describes the repositories and packages in the cache
+func (c *Cache) List() ([]CacheEntry, error) {
+	var entries []CacheEntry
+
+	repos, err := cacheDirs(filepath.Join(c.Dir, "git"))
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range repos {
+		name := filepath.Base(dir)
+		if repo, err := git.PlainOpen(dir); err == nil {
+			if remote, err := repo.Remote("origin"); err == nil && len(remote.Config().URLs) > 0 {
+				name = remote.Config().URLs[0]
+			}
+		}
+		entries = append(entries, CacheEntry{Kind: "git", Name: name, Size: dirSize(dir)})
+	}
+
+	packages, err := cacheDirs(filepath.Join(c.Dir, "packages"))
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range packages {
+		name := strings.Replace(filepath.Base(dir), "-", ":", 1)
+		if config, err := LoadConfig(filepath.Join(dir, "sunbird.toml")); err == nil {
+			name = config.Package.Name + " " + name
+		}
+		entries = append(entries, CacheEntry{Kind: "package", Name: name, Size: dirSize(dir)})
+	}
+
+	return entries, nil
+}
+
+// Clean removes everything from the cache
+func (c *Cache) Clean() error {
+	return os.RemoveAll(c.Dir)
+}
+
+// cacheDirs lists the finished entries in dir, leaving out interrupted ones
+func cacheDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), "clone-") && !strings.HasPrefix(entry.Name(), "store-") {
+			dirs = append(dirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// copyDirectory copies the regular files in src to dst, keeping their permissions
+func copyDirectory(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case entry.Type().IsRegular():
+			return copyFile(path, target)
+		default:
+			return nil
+		}
+	})
+}
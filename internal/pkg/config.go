@@ -2,12 +2,18 @@ package pkg
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/BurntSushi/toml"
+	"github.com/radeqq007/sunbird/internal/sandbox"
+	"github.com/radeqq007/sunbird/internal/vfs"
 )
 
 type Config struct {
-	Package PackageInfo `toml:"package"`
+	Package   PackageInfo       `toml:"package"`
+	Workspace *WorkspaceConfig  `toml:"workspace,omitempty"`
+	Sandbox   *SandboxConfig    `toml:"sandbox,omitempty"`
+	Scripts   map[string]Script `toml:"scripts,omitempty"`
 }
 
 type PackageInfo struct {
@@ -17,19 +23,114 @@ type PackageInfo struct {
 	Authors      []string         `toml:"authors"`
 	Main         string           `toml:"main"`
 	Dependencies []DependencyInfo `toml:"dependencies"`
+
+	// Ignore lists the files 'sunbird pack' leaves out, as glob patterns
+	Ignore []string `toml:"ignore,omitempty"`
 }
 
+// DependencyInfo describes a dependency listed in sunbird.toml.
+// Name is the name the package is imported as, filled in once it's installed.
+// Version is a constraint on the tags of the repository, like "^1.2".
+// Path points at a package on disk instead, relative to sunbird.toml,
+// which is used as it is without being installed. Archive is a package
+// built by 'sunbird pack', and Registry a directory of such archives.
 type DependencyInfo struct {
-	Git     string `toml:"git"`
-	Version string `toml:"version"`
-	Tag     string `toml:"tag"`
-	Path    string `toml:"path"`
+	Name     string `toml:"name,omitempty"`
+	Git      string `toml:"git,omitempty"`
+	Version  string `toml:"version,omitempty"`
+	Tag      string `toml:"tag,omitempty"`
+	Branch   string `toml:"branch,omitempty"`
+	Path     string `toml:"path,omitempty"`
+	Archive  string `toml:"archive,omitempty"`
+	Registry string `toml:"registry,omitempty"`
+}
+
+// spec describes the versions of the dependency that may be installed
+func (d *DependencyInfo) spec() string {
+	switch {
+	case d.Tag != "":
+		return "tag " + d.Tag
+	case d.Branch != "":
+		return "branch " + d.Branch
+	case d.Version != "":
+		return d.Version
+	case d.Path != "":
+		return "path " + d.Path
+	default:
+		return "any version"
+	}
+}
+
+// String identifies the dependency in messages
+func (d *DependencyInfo) String() string {
+	switch {
+	case d.Name != "":
+		return d.Name
+	case d.Path != "":
+		return d.Path
+	case d.Archive != "":
+		return d.Archive
+	default:
+		return d.Git
+	}
+}
+
+// SandboxConfig restricts what scripts of the project may do.
+// Directories are relative to the directory containing sunbird.toml.
+type SandboxConfig struct {
+	DenyAll     bool     `toml:"deny_all"`
+	AllowRead   []string `toml:"allow_read"`
+	AllowWrite  []string `toml:"allow_write"`
+	AllowNet    *bool    `toml:"allow_net"`
+	AllowExit   *bool    `toml:"allow_exit"`
+	DenyModules []string `toml:"deny_modules"`
+}
+
+// Policy builds the sandbox policy described by the config
+func (c *SandboxConfig) Policy(baseDir string) (*sandbox.Policy, error) {
+	policy := sandbox.New()
+	if c.DenyAll {
+		policy.DenyAll()
+	}
+
+	for _, dir := range c.AllowRead {
+		if err := policy.AllowRead(resolveConfigPath(baseDir, dir)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, dir := range c.AllowWrite {
+		if err := policy.AllowWrite(resolveConfigPath(baseDir, dir)); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.AllowNet != nil {
+		policy.SetNet(*c.AllowNet)
+	}
+
+	if c.AllowExit != nil {
+		policy.SetExit(*c.AllowExit)
+	}
+
+	for _, name := range c.DenyModules {
+		policy.DenyModule(name)
+	}
+
+	return policy, nil
+}
+
+func resolveConfigPath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
 }
 
 func LoadConfig(path string) (*Config, error) {
 	var config Config
 
-	data, err := os.ReadFile(path)
+	data, err := vfs.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,155 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// cloneRepository clones the repository at url into dir without checking out any files
+func cloneRepository(url, dir string) (*git.Repository, error) {
+	repo, err := git.PlainClone(dir, false, &git.CloneOptions{
+		URL:        url,
+		NoCheckout: true,
+		Tags:       git.AllTags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone %s: %w", url, err)
+	}
+	return repo, nil
+}
+
+// resolveRevision finds the commit a tag, branch or commit hash points at.
+// An empty revision means the default branch.
+func resolveRevision(repo *git.Repository, rev string) (plumbing.Hash, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	// Branches other than the default one only exist as remote branches in a clone
+	for _, candidate := range []string{rev, "origin/" + rev} {
+		hash, err := repo.ResolveRevision(plumbing.Revision(candidate))
+		if err != nil {
+			continue
+		}
+
+		// Annotated tags point at a tag object rather than at the commit
+		if tag, err := repo.TagObject(*hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			return commit.Hash, nil
+		}
+
+		return *hash, nil
+	}
+
+	return plumbing.ZeroHash, fmt.Errorf("unknown revision %q", rev)
+}
+
+// repositoryTags lists the names of the tags in the repository
+func repositoryTags(repo *git.Repository) ([]string, error) {
+	iter, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tags = append(tags, ref.Name().Short())
+		return nil
+	})
+	return tags, err
+}
+
+// readCommitConfig parses the sunbird.toml of the commit
+func readCommitConfig(repo *git.Repository, hash plumbing.Hash) (*Config, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := commit.File("sunbird.toml")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := toml.Unmarshal([]byte(data), &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// exportCommit writes the files of the commit to dir, which must not exist yet
+func exportCommit(repo *git.Repository, hash plumbing.Hash, dir string) error {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+
+	files, err := commit.Files()
+	if err != nil {
+		return err
+	}
+
+	return files.ForEach(func(file *object.File) error {
+		// Symlinks and submodules aren't part of a package
+		if file.Mode != filemode.Regular && file.Mode != filemode.Executable {
+			return nil
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(file.Name))
+		if !isWithin(dir, path) {
+			return fmt.Errorf("invalid file name in repository: %s", file.Name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+
+		perm := os.FileMode(0o644)
+		if file.Mode == filemode.Executable {
+			perm = 0o755
+		}
+
+		return writeBlob(file, path, perm)
+	})
+}
+
+func writeBlob(file *object.File, path string, perm os.FileMode) error {
+	reader, err := file.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, reader)
+	return errors.Join(err, out.Close())
+}
+
+// isWithin reports whether path is inside dir
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,326 @@
+package pkg
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Install fetches every package the project needs, directly or through other
+// packages, that isn't in .sb_modules yet. Packages listed in sunbird.lock are
+// installed at the commit recorded there while it still satisfies sunbird.toml,
+// and their files have to match the recorded checksum.
+func (pm *PackageManager) Install() error {
+	return pm.installAll(false)
+}
+
+// Update resolves every dependency again, ignoring sunbird.lock, moving each
+// to the newest version its constraint, tag or branch allows
+func (pm *PackageManager) Update() error {
+	return pm.installAll(true)
+}
+
+func (pm *PackageManager) lockPath() string {
+	return filepath.Join(pm.root(), lockfileName)
+}
+
+func (pm *PackageManager) installAll(update bool) error {
+	config, err := LoadConfig(pm.configPath())
+	if err != nil {
+		return err
+	}
+
+	lock, err := LoadLockfile(pm.lockPath())
+	if err != nil {
+		return err
+	}
+
+	return pm.sync(config, lock, update)
+}
+
+// sync installs the packages needed by the project, whose sunbird.toml is config,
+// along with the rest of its workspace, and records them in sunbird.lock. The
+// names of direct dependencies seen for the first time are saved to sunbird.toml.
+func (pm *PackageManager) sync(config *Config, lock *Lockfile, update bool) error {
+	frozen := pm.Frozen && !update
+	if frozen {
+		if _, err := os.Stat(pm.lockPath()); err != nil {
+			return fmt.Errorf("%s is missing, run 'sunbird install' without --frozen first", lockfileName)
+		}
+	}
+
+	packages, err := pm.localPackages(config)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range packages {
+		for _, dep := range p.config.Package.Dependencies {
+			if err := checkDependency(&dep); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Without a cache directory, downloads only last until the end of the run
+	cache := &Cache{Dir: pm.CacheDir}
+	if cache.Dir == "" {
+		tmp, err := os.MkdirTemp("", "sunbird-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		cache.Dir = tmp
+	}
+
+	var locked []LockedPackage
+	if !update {
+		locked = lock.Packages
+	}
+
+	root := pm.root()
+	r := newResolver(cache, root, pm.Offline, locked, frozen)
+	for _, p := range packages {
+		if err := r.setRequirements(p.dir, p.dir, []string{p.name()}, p.config.Package.Dependencies); err != nil {
+			return err
+		}
+	}
+	if err := r.resolve(); err != nil {
+		return err
+	}
+
+	if frozen && len(r.selected) != len(lock.Packages) {
+		return fmt.Errorf("%s is out of date: it lists packages the project doesn't need", lockfileName)
+	}
+
+	// Packages are imported by name, which has to be unique
+	names := make(map[string]string)
+	for _, p := range packages {
+		names[p.config.Package.Name] = p.dir
+	}
+
+	installed := &Lockfile{}
+	for _, key := range slices.Sorted(maps.Keys(r.selected)) {
+		sel := r.selected[key]
+		if other, ok := names[sel.name]; ok {
+			return fmt.Errorf("%s and %s both contain a package called %s", other, key, sel.name)
+		}
+		names[sel.name] = key
+
+		var prev *LockedPackage
+		if p, ok := lock.find(root, key); ok && sel.matches(p) {
+			prev = &p
+		}
+
+		result, err := pm.installSelection(r, sel, prev, frozen)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sel.name, err)
+		}
+		installed.Packages = append(installed.Packages, result)
+	}
+
+	if frozen {
+		return nil
+	}
+
+	for _, p := range packages {
+		if !p.member {
+			continue
+		}
+		if err := nameDependencies(p, packages, r.selected); err != nil {
+			return err
+		}
+	}
+
+	return SaveLockfile(pm.lockPath(), installed)
+}
+
+// checkDependency makes sure dep says where to get the package from, in exactly one way
+func checkDependency(dep *DependencyInfo) error {
+	sources := 0
+	for _, source := range []string{dep.Git, dep.Path, dep.Archive, dep.Registry} {
+		if source != "" {
+			sources++
+		}
+	}
+
+	switch {
+	case sources != 1:
+		return fmt.Errorf("%s: dependency needs exactly one of git, path, archive or registry", dep)
+	case dep.Registry != "" && dep.Name == "":
+		return fmt.Errorf("%s: dependencies from a registry need a name", dep)
+	}
+	return nil
+}
+
+// nameDependencies records the names of the dependencies of p installed
+// for the first time in its sunbird.toml
+func nameDependencies(p localPackage, packages []localPackage, selected map[string]*selection) error {
+	named := false
+
+	deps := p.config.Package.Dependencies
+	for i := range deps {
+		if deps[i].Name != "" {
+			continue
+		}
+
+		if deps[i].Path != "" {
+			target := resolveConfigPath(p.dir, deps[i].Path)
+			for _, local := range packages {
+				if local.dir == target {
+					deps[i].Name = local.config.Package.Name
+				}
+			}
+		} else if key, err := dependencyKey(deps[i], p.dir); err == nil {
+			if sel, ok := selected[key]; ok {
+				deps[i].Name = sel.name
+			}
+		}
+		named = named || deps[i].Name != ""
+	}
+
+	if !named {
+		return nil
+	}
+	return SaveConfig(filepath.Join(p.dir, "sunbird.toml"), p.config)
+}
+
+// installSelection makes sure the files of the selected commit are in .sb_modules.
+// If it's the locked commit, installed files matching the lock are kept, and
+// downloaded files have to match it. Changed files are only replaced if frozen
+// isn't set.
+func (pm *PackageManager) installSelection(r *resolver, sel *selection, locked *LockedPackage, frozen bool) (LockedPackage, error) {
+	result := LockedPackage{
+		Name:    sel.name,
+		Git:     sel.git,
+		Version: sel.version,
+		Branch:  sel.branch,
+	}
+
+	switch {
+	case sel.git != "":
+		result.Commit = sel.commit.String()
+	case sel.registry != "":
+		result.Registry = pm.relativePath(sel.registry)
+	default:
+		result.Archive = pm.relativePath(sel.archive)
+	}
+
+	dir := filepath.Join(pm.modulesDir(), sel.name)
+	if !isWithin(pm.modulesDir(), dir) {
+		return result, fmt.Errorf("invalid package name %q", sel.name)
+	}
+
+	if locked != nil && pm.isInstalled(sel.name) {
+		checksum, err := checksumDir(dir)
+		if err != nil {
+			return result, err
+		}
+
+		if checksum == locked.Checksum {
+			fmt.Fprintf(pm.Out, "  ✓ %s (already installed)\n", sel.name)
+			result.Checksum = checksum
+			return result, nil
+		}
+
+		if frozen {
+			return result, fmt.Errorf("the files in %s don't match the checksum in %s",
+				filepath.Join(dependencyDirectory, sel.name), lockfileName)
+		}
+	}
+
+	var cached string
+	if locked != nil {
+		cached = locked.Checksum
+	}
+
+	checksum, err := pm.exportSelection(r, sel, cached, dir)
+	if err != nil {
+		return result, err
+	}
+
+	if locked != nil && checksum != locked.Checksum {
+		return result, fmt.Errorf("checksum mismatch: %s has %s, but the downloaded files have %s",
+			lockfileName, locked.Checksum, checksum)
+	}
+
+	fmt.Fprintf(pm.Out, "  ✓ %s\n", sel.label())
+	result.Checksum = checksum
+	return result, nil
+}
+
+// exportSelection replaces dir with the files of the selected commit and checksums
+// them. The files are copied from the cache if it holds a package with the
+// checksum cached, and added to the cache otherwise.
+func (pm *PackageManager) exportSelection(r *resolver, sel *selection, cached string, dir string) (string, error) {
+	tmp, err := os.MkdirTemp("", "sunbird-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	staging := filepath.Join(tmp, "package")
+
+	copied := false
+	if cached != "" {
+		if copied, err = r.cache.copyPackage(cached, staging); err != nil {
+			return "", err
+		}
+	}
+
+	switch {
+	case copied:
+	case sel.archive != "":
+		if err := extractArchive(sel.archive, staging); err != nil {
+			return "", err
+		}
+	default:
+		repo, err := r.repository(sel.git)
+		if err != nil {
+			return "", err
+		}
+		if err := exportCommit(repo, sel.commit, staging); err != nil {
+			return "", fmt.Errorf("failed to check out %s: %w", sel.commit, err)
+		}
+	}
+
+	checksum, err := checksumDir(staging)
+	if err != nil {
+		return "", err
+	}
+
+	if !copied {
+		if err := r.cache.storePackage(checksum, staging); err != nil {
+			return "", fmt.Errorf("failed to cache package: %w", err)
+		}
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("failed to remove existing directory: %w", err)
+	}
+
+	if err := os.MkdirAll(pm.modulesDir(), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory for package: %w", err)
+	}
+
+	if err := moveDirectory(staging, dir); err != nil {
+		return "", fmt.Errorf("failed to move package to final location: %w", err)
+	}
+
+	return checksum, nil
+}
+
+// relativePath makes path relative to the directory of sunbird.lock, if possible
+func (pm *PackageManager) relativePath(path string) string {
+	if rel, err := filepath.Rel(pm.root(), path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+func (pm *PackageManager) isInstalled(name string) bool {
+	info, err := os.Stat(filepath.Join(pm.modulesDir(), name))
+	return err == nil && info.IsDir()
+}
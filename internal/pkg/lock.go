@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const lockfileName = "sunbird.lock"
+
+const lockfileHeader = "# This file is generated by sunbird. Do not edit it by hand.\n\n"
+
+// Lockfile records exactly what was installed for every dependency,
+// so that installing again reproduces the same files
+type Lockfile struct {
+	Packages []LockedPackage `toml:"package"`
+}
+
+// LockedPackage is a package installed from git, an archive or a registry.
+// Paths are relative to the directory containing sunbird.lock.
+type LockedPackage struct {
+	Name     string `toml:"name"`
+	Git      string `toml:"git,omitempty"`
+	Archive  string `toml:"archive,omitempty"`
+	Registry string `toml:"registry,omitempty"`
+	Version  string `toml:"version,omitempty"` // tag the commit was picked by, or version of the archive
+	Branch   string `toml:"branch,omitempty"`  // branch the commit was picked from
+	Commit   string `toml:"commit,omitempty"`
+	Checksum string `toml:"checksum"` // of the installed files, see checksumDir
+}
+
+// key identifies the locked package like dependencyKey does, with paths relative to root
+func (p *LockedPackage) key(root string) string {
+	switch {
+	case p.Archive != "":
+		return archivePrefix + resolveConfigPath(root, filepath.FromSlash(p.Archive))
+	case p.Registry != "":
+		return registryPrefix + filepath.Join(resolveConfigPath(root, filepath.FromSlash(p.Registry)), p.Name)
+	default:
+		return p.Git
+	}
+}
+
+// LoadLockfile reads the lockfile at path. A missing lockfile is empty.
+func LoadLockfile(path string) (*Lockfile, error) {
+	var lock Lockfile
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &lock, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := toml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Base(path), err)
+	}
+
+	return &lock, nil
+}
+
+func SaveLockfile(path string, lock *Lockfile) error {
+	slices.SortFunc(lock.Packages, func(a, b LockedPackage) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString(lockfileHeader)
+	if err := toml.NewEncoder(&buf).Encode(lock); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// find looks up the locked package with the given key, see dependencyKey
+func (l *Lockfile) find(root, key string) (LockedPackage, bool) {
+	for _, p := range l.Packages {
+		if p.key(root) == key {
+			return p, true
+		}
+	}
+	return LockedPackage{}, false
+}
+
+// checksumDir hashes the names, permissions and contents of the files in dir.
+// The result only depends on the files themselves, not on when or how they
+// were written.
+func checksumDir(dir string) (string, error) {
+	h := sha256.New()
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		executable := info.Mode()&0o111 != 0
+		fmt.Fprintf(h, "%s\x00%t\x00%d\x00", filepath.ToSlash(rel), executable, info.Size())
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(h, file)
+		return errors.Join(err, file.Close())
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,399 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// manifestName is the first file of every package archive
+const manifestName = ".sunbird-manifest.toml"
+
+// Manifest lists the files of a package archive with their hashes
+type Manifest struct {
+	Name    string         `toml:"name"`
+	Version string         `toml:"version"`
+	Files   []ManifestFile `toml:"file"`
+}
+
+type ManifestFile struct {
+	Path       string `toml:"path"`
+	Size       int64  `toml:"size"`
+	Executable bool   `toml:"executable,omitempty"`
+	SHA256     string `toml:"sha256"`
+}
+
+// archiveName is the file name of the archive of a package version,
+// which is also how archives are found in a registry
+func archiveName(name, version string) string {
+	return name + "-" + version + ".tar.gz"
+}
+
+// Pack builds an archive of the project at out and returns its path. By default,
+// or if out is a directory, the archive is called name-version.tar.gz. Hidden
+// files, sunbird.lock and the files matching the ignore list are left out.
+// Packing the same files always gives the same archive.
+func (pm *PackageManager) Pack(out string) (string, error) {
+	config, err := LoadConfig(pm.configPath())
+	if err != nil {
+		return "", err
+	}
+
+	name, version := config.Package.Name, config.Package.Version
+	if name == "" {
+		return "", errors.New("package name is missing in sunbird.toml")
+	}
+	if _, err := ParseVersion(version); err != nil {
+		return "", fmt.Errorf("package version in sunbird.toml: %w", err)
+	}
+
+	if out == "" {
+		out = pm.Dir
+	}
+	if info, err := os.Stat(out); err == nil && info.IsDir() {
+		out = filepath.Join(out, archiveName(name, version))
+	}
+
+	files, err := packageFileList(pm.Dir, name, config.Package.Ignore, out)
+	if err != nil {
+		return "", err
+	}
+
+	manifest := Manifest{Name: name, Version: version}
+	for _, file := range files {
+		entry, err := manifestEntry(pm.Dir, file)
+		if err != nil {
+			return "", err
+		}
+		manifest.Files = append(manifest.Files, entry)
+	}
+
+	var buf bytes.Buffer
+	if err := writeArchive(&buf, pm.Dir, manifest); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(out, buf.Bytes(), 0o644); err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(pm.Out, "  ✓ %s@%s (%d files) -> %s\n", name, version, len(files), out)
+	return out, nil
+}
+
+// packageFileList lists the files to pack from dir as sorted slash-separated paths.
+// Earlier archives of the package called name are left out too.
+func packageFileList(dir, name string, ignore []string, out string) ([]string, error) {
+	absOut, err := filepath.Abs(out)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		skip := strings.HasPrefix(entry.Name(), ".") || rel == lockfileName || isIgnored(rel, ignore)
+		if archive, _ := path.Match(archiveName(name, "*"), rel); archive {
+			skip = true
+		}
+		if entry.IsDir() {
+			if skip {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if abs, err := filepath.Abs(p); skip || !entry.Type().IsRegular() || (err == nil && abs == absOut) {
+			return nil
+		}
+
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(files)
+	return files, nil
+}
+
+// isIgnored reports whether the file or directory at rel matches a pattern of the
+// ignore list. Patterns without a slash match names anywhere in the tree, others
+// match paths from the project root. Matching a directory leaves out its contents.
+func isIgnored(rel string, ignore []string) bool {
+	for _, pattern := range ignore {
+		pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+				return true
+			}
+			continue
+		}
+
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func manifestEntry(dir, rel string) (ManifestFile, error) {
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	return ManifestFile{
+		Path:       rel,
+		Size:       int64(len(data)),
+		Executable: info.Mode()&0o111 != 0,
+		SHA256:     hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// writeArchive writes the manifest and the files it lists as a gzipped tarball.
+// Timestamps and owners are left out so that the archive only depends on the files.
+func writeArchive(w io.Writer, dir string, manifest Manifest) error {
+	gz, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(gz)
+
+	var data bytes.Buffer
+	if err := toml.NewEncoder(&data).Encode(manifest); err != nil {
+		return err
+	}
+	if err := writeArchiveFile(tw, manifestName, 0o644, data.Bytes()); err != nil {
+		return err
+	}
+
+	for _, file := range manifest.Files {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Path)))
+		if err != nil {
+			return err
+		}
+
+		mode := int64(0o644)
+		if file.Executable {
+			mode = 0o755
+		}
+		if err := writeArchiveFile(tw, file.Path, mode, content); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeArchiveFile(tw *tar.Writer, name string, mode int64, content []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     mode,
+		Size:     int64(len(content)),
+		ModTime:  time.Unix(0, 0),
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(content)
+	return err
+}
+
+// readArchive calls fn for every file in the archive at path, after checking
+// that the manifest comes first
+func readArchive(p string, fn func(manifest *Manifest, header *tar.Header, r io.Reader) error) error {
+	file, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	tr := tar.NewReader(gz)
+
+	var manifest *Manifest
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+
+		if manifest == nil {
+			if header.Name != manifestName {
+				return fmt.Errorf("%s: not a sunbird package, %s is missing", p, manifestName)
+			}
+
+			manifest = &Manifest{}
+			if _, err := toml.NewDecoder(tr).Decode(manifest); err != nil {
+				return fmt.Errorf("%s: invalid manifest: %w", p, err)
+			}
+			continue
+		}
+
+		if err := fn(manifest, header, tr); err != nil {
+			return err
+		}
+	}
+
+	if manifest == nil {
+		return fmt.Errorf("%s: not a sunbird package, %s is missing", p, manifestName)
+	}
+	return nil
+}
+
+// errStop ends reading an archive early
+var errStop = errors.New("stop")
+
+// archiveConfig reads the manifest and sunbird.toml of the archive at path
+func archiveConfig(p string) (*Manifest, *Config, error) {
+	var manifest *Manifest
+	var config *Config
+
+	err := readArchive(p, func(m *Manifest, header *tar.Header, r io.Reader) error {
+		manifest = m
+		if header.Name != "sunbird.toml" {
+			return nil
+		}
+
+		config = &Config{}
+		if _, err := toml.NewDecoder(r).Decode(config); err != nil {
+			return fmt.Errorf("%s: invalid sunbird.toml: %w", p, err)
+		}
+		return errStop
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return nil, nil, err
+	}
+
+	if config == nil {
+		return nil, nil, fmt.Errorf("%s: sunbird.toml is missing", p)
+	}
+	if config.Package.Name != manifest.Name || config.Package.Version != manifest.Version {
+		return nil, nil, fmt.Errorf("%s: the manifest doesn't match sunbird.toml", p)
+	}
+	return manifest, config, nil
+}
+
+// extractArchive writes the files of the archive at path to dir, checking
+// them against the hashes in the manifest
+func extractArchive(p, dir string) error {
+	seen := make(map[string]bool)
+
+	var manifest *Manifest
+	err := readArchive(p, func(m *Manifest, header *tar.Header, r io.Reader) error {
+		manifest = m
+
+		i := slices.IndexFunc(m.Files, func(f ManifestFile) bool { return f.Path == header.Name })
+		if i < 0 || seen[header.Name] || header.Typeflag != tar.TypeReg {
+			return fmt.Errorf("%s: unexpected file %s", p, header.Name)
+		}
+		seen[header.Name] = true
+		expected := m.Files[i]
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !isWithin(dir, target) {
+			return fmt.Errorf("%s: invalid file name %s", p, header.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+
+		perm := os.FileMode(0o644)
+		if expected.Executable {
+			perm = 0o755
+		}
+
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+		if err != nil {
+			return err
+		}
+
+		h := sha256.New()
+		n, err := io.Copy(io.MultiWriter(out, h), r)
+		if err := errors.Join(err, out.Close()); err != nil {
+			return err
+		}
+
+		if n != expected.Size || hex.EncodeToString(h.Sum(nil)) != expected.SHA256 {
+			return fmt.Errorf("%s: %s doesn't match the hash in the manifest", p, header.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		return fmt.Errorf("%s: sunbird.toml is missing", p)
+	}
+
+	for _, file := range manifest.Files {
+		if !seen[file.Path] {
+			return fmt.Errorf("%s: %s is listed in the manifest but missing", p, file.Path)
+		}
+	}
+	return nil
+}
+
+// registryVersions lists the versions of the package called name in the registry
+// directory, mapping each version to its archive
+func registryVersions(registry, name string) (map[string]string, error) {
+	matches, err := filepath.Glob(filepath.Join(registry, archiveName(name, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]string)
+	for _, match := range matches {
+		version := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), name+"-"), ".tar.gz")
+		if _, err := ParseVersion(version); err == nil {
+			versions[version] = match
+		}
+	}
+	return versions, nil
+}
@@ -0,0 +1,245 @@
+package pkg_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/radeqq007/sunbird/internal/pkg"
+)
+
+// newPackage creates a project with a name and version, ready to be packed
+func newPackage(t *testing.T, name, version string, files map[string]string) *pkg.PackageManager {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"sunbird.toml": "[package]\nname = \"" + name + "\"\nversion = \"" + version + "\"\nmain = \"main.sb\"\nignore = [\"*.log\", \"tests\"]\n",
+	})
+	writeFiles(t, dir, files)
+
+	return &pkg.PackageManager{Dir: dir, Out: io.Discard}
+}
+
+// archiveFiles reads the names and contents of the files in a package archive
+func archiveFiles(t *testing.T, path string) ([]string, map[string]string) {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	contents := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		contents[header.Name] = string(data)
+	}
+	return names, contents
+}
+
+// writeArchive writes files, in order, as a gzipped tarball
+func writeArchive(t *testing.T, path string, names []string, contents map[string]string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(contents[name])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPack(t *testing.T) {
+	pm := newPackage(t, "utils", "1.2.0", map[string]string{
+		"main.sb":          `export x :: 1`,
+		"lib/helpers.sb":   `export y :: 2`,
+		"debug.log":        "ignored",
+		"tests/a_test.sb":  "ignored",
+		".git/config":      "hidden",
+		"sunbird.lock":     "not packed",
+		"lib/more/deep.sb": "",
+	})
+
+	path, err := pm.Pack("")
+	if err != nil {
+		t.Fatalf("pack failed: %s", err)
+	}
+	if path != filepath.Join(pm.Dir, "utils-1.2.0.tar.gz") {
+		t.Errorf("unexpected archive path %s", path)
+	}
+
+	names, contents := archiveFiles(t, path)
+	expected := []string{".sunbird-manifest.toml", "lib/helpers.sb", "lib/more/deep.sb", "main.sb", "sunbird.toml"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected files %v, got %v", expected, names)
+	}
+
+	manifest := contents[".sunbird-manifest.toml"]
+	for _, s := range []string{`name = "utils"`, `version = "1.2.0"`, `path = "main.sb"`, "sha256 = "} {
+		if !strings.Contains(manifest, s) {
+			t.Errorf("expected the manifest to contain %q, got:\n%s", s, manifest)
+		}
+	}
+
+	// Packing again gives the same bytes, whenever the files were written
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(pm.Dir, "main.sb"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	path, err = pm.Pack(out)
+	if err != nil {
+		t.Fatalf("pack failed: %s", err)
+	}
+	if path != filepath.Join(out, "utils-1.2.0.tar.gz") {
+		t.Errorf("expected the archive in %s, got %s", out, path)
+	}
+	if second := readFile(t, path); second != string(first) {
+		t.Error("expected packing to be deterministic")
+	}
+
+	invalid := newPackage(t, "utils", "latest", nil)
+	if _, err := invalid.Pack(""); err == nil {
+		t.Error("expected packing without a valid version to fail")
+	}
+}
+
+func TestArchiveDependencies(t *testing.T) {
+	registry := t.TempDir()
+	for _, version := range []string{"1.0.0", "1.1.0", "2.0.0"} {
+		utils := newPackage(t, "utils", version, map[string]string{"main.sb": `export version :: "` + version + `"`})
+		if _, err := utils.Pack(registry); err != nil {
+			t.Fatalf("pack failed: %s", err)
+		}
+	}
+	archive := filepath.Join(registry, "utils-1.0.0.tar.gz")
+
+	// Installing a single archive
+	pm := newProject(t)
+	if err := pm.Add(archive); err != nil {
+		t.Fatalf("add failed: %s", err)
+	}
+	mainPath := filepath.Join(pm.Dir, ".sb_modules", "utils", "main.sb")
+	if got := readFile(t, mainPath); got != `export version :: "1.0.0"` {
+		t.Errorf("expected the archive to be installed, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(pm.Dir, ".sb_modules", "utils", ".sunbird-manifest.toml")); !os.IsNotExist(err) {
+		t.Errorf("expected the manifest not to be installed, got %v", err)
+	}
+
+	deps := loadDependencies(t, pm)
+	if len(deps) != 1 || deps[0].Name != "utils" || deps[0].Archive == "" {
+		t.Errorf("expected the archive in sunbird.toml, got %+v", deps)
+	}
+
+	lock, err := pkg.LoadLockfile(filepath.Join(pm.Dir, "sunbird.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Packages) != 1 || lock.Packages[0].Version != "1.0.0" || lock.Packages[0].Archive == "" {
+		t.Errorf("expected the archive to be locked, got %+v", lock.Packages)
+	}
+
+	// Archives whose files don't match their manifest are rejected
+	names, contents := archiveFiles(t, archive)
+	contents["main.sb"] = `export version :: "tampered"`
+	tampered := filepath.Join(t.TempDir(), "utils.tar.gz")
+	writeArchive(t, tampered, names, contents)
+
+	other := newProject(t)
+	if err := other.Add(tampered); err == nil || !strings.Contains(err.Error(), "doesn't match the hash") {
+		t.Errorf("expected a hash mismatch, got %v", err)
+	}
+
+	// Installing from a registry picks the newest version allowed
+	pm = newProject(t)
+	pm.Registry = registry
+	if err := pm.Add("utils@^1.0"); err != nil {
+		t.Fatalf("add failed: %s", err)
+	}
+	mainPath = filepath.Join(pm.Dir, ".sb_modules", "utils", "main.sb")
+	if got := readFile(t, mainPath); got != `export version :: "1.1.0"` {
+		t.Errorf("expected utils 1.1.0 to be installed, got %q", got)
+	}
+
+	deps = loadDependencies(t, pm)
+	if len(deps) != 1 || deps[0].Registry != registry || deps[0].Version != "^1.0" {
+		t.Errorf("expected the registry in sunbird.toml, got %+v", deps)
+	}
+
+	// A frozen install keeps the locked version after a newer one appears
+	newer := newPackage(t, "utils", "1.2.0", map[string]string{"main.sb": `export version :: "1.2.0"`})
+	if _, err := newer.Pack(registry); err != nil {
+		t.Fatalf("pack failed: %s", err)
+	}
+	if err := os.RemoveAll(filepath.Join(pm.Dir, ".sb_modules")); err != nil {
+		t.Fatal(err)
+	}
+	pm.Frozen = true
+	if err := pm.Install(); err != nil {
+		t.Fatalf("frozen install failed: %s", err)
+	}
+	if got := readFile(t, mainPath); got != `export version :: "1.1.0"` {
+		t.Errorf("expected the locked version to be installed, got %q", got)
+	}
+
+	pm.Frozen = false
+	if err := pm.Update(); err != nil {
+		t.Fatalf("update failed: %s", err)
+	}
+	if got := readFile(t, mainPath); got != `export version :: "1.2.0"` {
+		t.Errorf("expected update to install 1.2.0, got %q", got)
+	}
+
+	pm.Registry = registry
+	if err := pm.Add("missing"); err == nil || !strings.Contains(err.Error(), "isn't in the registry") {
+		t.Errorf("expected a missing package to fail, got %v", err)
+	}
+}
@@ -1,28 +1,144 @@
 package pkg
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
 )
 
 const dependencyDirectory = ".sb_modules/"
 
-type PackageManager struct{}
+type PackageManager struct {
+	// Dir is the root directory of the project, containing sunbird.toml
+	Dir string
+
+	// Out receives progress messages
+	Out io.Writer
+
+	// Frozen makes Install use sunbird.lock exactly as it is, failing
+	// if it's out of date instead of updating it
+	Frozen bool
+
+	// CacheDir keeps downloaded repositories and packages between runs.
+	// If it's empty, everything is downloaded again every time.
+	CacheDir string
+
+	// Offline makes installing only use what's in the cache
+	Offline bool
+
+	// Registry is a directory of package archives Add takes packages from,
+	// instead of git repositories
+	Registry string
+
+	// Sunbird is the sunbird executable tasks run .sb files with
+	Sunbird string
+}
 
 func NewPackageManager() *PackageManager {
-	return &PackageManager{}
+	pm := &PackageManager{Dir: ".", Out: os.Stdout}
+	if dir, err := DefaultCacheDir(); err == nil {
+		pm.CacheDir = dir
+	}
+	if exe, err := os.Executable(); err == nil {
+		pm.Sunbird = exe
+	}
+	return pm
+}
+
+func (pm *PackageManager) configPath() string {
+	return filepath.Join(pm.Dir, "sunbird.toml")
 }
 
-func (pm *PackageManager) Add(url string) error {
-	pkgURL, err := ParsePackageURL(url)
+func (pm *PackageManager) modulesDir() string {
+	return filepath.Join(pm.root(), dependencyDirectory)
+}
+
+// Add installs the dependency described by spec, along with the packages it
+// needs, and lists it in sunbird.toml and sunbird.lock, replacing earlier
+// entries for the same repository
+func (pm *PackageManager) Add(spec string) error {
+	var dep DependencyInfo
+	var err error
+	if pm.Registry != "" {
+		dep, err = ParseRegistryDependency(pm.Registry, spec)
+	} else {
+		dep, err = ParseDependency(spec)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Local paths are given relative to the working directory, but
+	// sunbird.toml needs them relative to the project
+	dep.Archive = pm.projectPath(dep.Archive)
+	dep.Registry = pm.projectPath(dep.Registry)
+
+	dir, err := filepath.Abs(pm.Dir)
+	if err != nil {
+		return err
+	}
+	key, err := dependencyKey(dep, dir)
+	if err != nil {
+		return err
+	}
+	sameKey := func(d DependencyInfo) bool {
+		k, err := dependencyKey(d, dir)
+		return err == nil && k == key
+	}
+
+	config, err := LoadConfig(pm.configPath())
 	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return errors.New("no sunbird.toml found, run 'sunbird init' first")
+		}
 		return err
 	}
 
-	fmt.Printf("Downloading %s/%s...\n", pkgURL.Owner, pkgURL.Repo)
-	err = DownloadPackage(pkgURL)
+	lock, err := LoadLockfile(pm.lockPath())
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// Adding a package again moves it to the newest version spec allows
+	root := pm.root()
+	lock.Packages = slices.DeleteFunc(lock.Packages, func(p LockedPackage) bool {
+		return p.key(root) == key
+	})
+
+	deps := config.Package.Dependencies
+	if i := slices.IndexFunc(deps, sameKey); i >= 0 {
+		deps[i] = dep
+	} else {
+		config.Package.Dependencies = append(deps, dep)
+	}
+
+	fmt.Fprintf(pm.Out, "Adding %s...\n", &dep)
+	if err := pm.sync(config, lock, false); err != nil {
+		return err
+	}
+	return SaveConfig(pm.configPath(), config)
+}
+
+// projectPath makes a path relative to the working directory relative to the project
+func (pm *PackageManager) projectPath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	dir, err := filepath.Abs(pm.Dir)
+	if err != nil {
+		return path
+	}
+
+	if rel, err := filepath.Rel(dir, abs); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return abs
 }
@@ -0,0 +1,745 @@
+package pkg_test
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/radeqq007/sunbird/internal/pkg"
+)
+
+// testRepo is a git repository serving as a package in tests
+type testRepo struct {
+	t    *testing.T
+	dir  string
+	repo *git.Repository
+}
+
+func newTestRepo(t *testing.T, name string) *testRepo {
+	t.Helper()
+
+	// Cloning file:// URLs runs git-upload-pack
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := filepath.Join(t.TempDir(), name)
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testRepo{t: t, dir: dir, repo: repo}
+}
+
+func (r *testRepo) url() string {
+	return "file://" + filepath.ToSlash(r.dir)
+}
+
+// commit writes files into the repository and commits them
+func (r *testRepo) commit(files map[string]string) {
+	r.t.Helper()
+
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+
+	writeFiles(r.t, r.dir, files)
+	if err := wt.AddGlob("."); err != nil {
+		r.t.Fatal(err)
+	}
+
+	_, err = wt.Commit("update", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *testRepo) tag(name string) {
+	r.t.Helper()
+
+	head, err := r.repo.Head()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if _, err := r.repo.CreateTag(name, head.Hash(), nil); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func packageFiles(name, main string) map[string]string {
+	return map[string]string{
+		"sunbird.toml": "[package]\nname = \"" + name + "\"\nmain = \"main.sb\"\n",
+		"main.sb":      main,
+	}
+}
+
+func newProject(t *testing.T, deps ...pkg.DependencyInfo) *pkg.PackageManager {
+	t.Helper()
+
+	dir := t.TempDir()
+	config := &pkg.Config{Package: pkg.PackageInfo{Name: "app", Main: "main.sb", Dependencies: deps}}
+	if err := pkg.SaveConfig(filepath.Join(dir, "sunbird.toml"), config); err != nil {
+		t.Fatal(err)
+	}
+
+	return &pkg.PackageManager{Dir: dir, Out: io.Discard}
+}
+
+func loadDependencies(t *testing.T, pm *pkg.PackageManager) []pkg.DependencyInfo {
+	t.Helper()
+
+	config, err := pkg.LoadConfig(filepath.Join(pm.Dir, "sunbird.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config.Package.Dependencies
+}
+
+func TestInstallAndUpdate(t *testing.T) {
+	tagged := newTestRepo(t, "tagged")
+	tagged.commit(packageFiles("tagged", `export version :: 1`))
+	tagged.tag("v1.0.0")
+	tagged.commit(packageFiles("tagged", `export version :: 2`))
+
+	branch := newTestRepo(t, "branch")
+	branch.commit(packageFiles("branch", `export version :: 1`))
+
+	pm := newProject(t,
+		pkg.DependencyInfo{Git: tagged.url(), Tag: "v1.0.0"},
+		pkg.DependencyInfo{Git: branch.url()},
+	)
+
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+
+	if got := readFile(t, filepath.Join(pm.Dir, ".sb_modules", "tagged", "main.sb")); got != `export version :: 1` {
+		t.Errorf("expected the tagged version to be installed, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(pm.Dir, ".sb_modules", "tagged", ".git")); err == nil {
+		t.Error("expected the package to be installed without its .git directory")
+	}
+
+	deps := loadDependencies(t, pm)
+	if deps[0].Name != "tagged" || deps[1].Name != "branch" {
+		t.Errorf("expected the names of the packages to be recorded, got %+v", deps)
+	}
+
+	// Installing again keeps what's installed, updating moves the branch forward
+	branch.commit(packageFiles("branch", `export version :: 2`))
+	branchMain := filepath.Join(pm.Dir, ".sb_modules", "branch", "main.sb")
+
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+	if got := readFile(t, branchMain); got != `export version :: 1` {
+		t.Errorf("expected install to keep the installed version, got %q", got)
+	}
+
+	if err := pm.Update(); err != nil {
+		t.Fatalf("update failed: %s", err)
+	}
+	if got := readFile(t, branchMain); got != `export version :: 2` {
+		t.Errorf("expected update to install the newest commit, got %q", got)
+	}
+	if got := readFile(t, filepath.Join(pm.Dir, ".sb_modules", "tagged", "main.sb")); got != `export version :: 1` {
+		t.Errorf("expected update to keep the tag, got %q", got)
+	}
+}
+
+func TestInstallErrors(t *testing.T) {
+	repo := newTestRepo(t, "repo")
+	repo.commit(packageFiles("actual", ""))
+
+	tests := []pkg.DependencyInfo{
+		{Git: repo.url(), Tag: "v9.9.9"},
+		{Name: "expected", Git: repo.url()},
+		{Git: "file://" + filepath.ToSlash(filepath.Join(t.TempDir(), "missing"))},
+	}
+
+	for _, dep := range tests {
+		pm := newProject(t, dep)
+		if err := pm.Install(); err == nil {
+			t.Errorf("expected installing %+v to fail", dep)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	repo := newTestRepo(t, "utils")
+	repo.commit(packageFiles("utils", ""))
+	repo.tag("v0.1.0")
+
+	pm := newProject(t)
+	if err := pm.Add(repo.url() + "@v0.1.0"); err != nil {
+		t.Fatalf("add failed: %s", err)
+	}
+
+	deps := loadDependencies(t, pm)
+	expected := pkg.DependencyInfo{Name: "utils", Git: repo.url(), Tag: "v0.1.0"}
+	if len(deps) != 1 || deps[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, deps)
+	}
+
+	// Adding the package again replaces its entry
+	if err := pm.Add(repo.url()); err != nil {
+		t.Fatalf("add failed: %s", err)
+	}
+	if deps := loadDependencies(t, pm); len(deps) != 1 || deps[0].Tag != "" {
+		t.Errorf("expected the entry to be replaced, got %+v", deps)
+	}
+
+	lock, err := pkg.LoadLockfile(filepath.Join(pm.Dir, "sunbird.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Packages) != 1 || lock.Packages[0].Version != "" {
+		t.Errorf("expected the locked package to be replaced, got %+v", lock.Packages)
+	}
+}
+
+func TestParseDependency(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected pkg.DependencyInfo
+	}{
+		{"github.com/user/repo", pkg.DependencyInfo{Git: "https://github.com/user/repo.git"}},
+		{"github.com/user/repo@v1.2.0", pkg.DependencyInfo{Git: "https://github.com/user/repo.git", Tag: "v1.2.0"}},
+		{"file:///srv/utils@main", pkg.DependencyInfo{Git: "file:///srv/utils", Branch: "main"}},
+		{"github.com/user/repo@^1.2", pkg.DependencyInfo{Git: "https://github.com/user/repo.git", Version: "^1.2"}},
+		{"file:///srv/utils@~0.3.1", pkg.DependencyInfo{Git: "file:///srv/utils", Version: "~0.3.1"}},
+		{"ssh://git@host/utils", pkg.DependencyInfo{Git: "ssh://git@host/utils"}},
+	}
+
+	for _, tt := range tests {
+		dep, err := pkg.ParseDependency(tt.spec)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.spec, err)
+			continue
+		}
+		if dep != tt.expected {
+			t.Errorf("%s: expected %+v, got %+v", tt.spec, tt.expected, dep)
+		}
+	}
+}
+
+func TestTidy(t *testing.T) {
+	used := newTestRepo(t, "used")
+	used.commit(packageFiles("used", `import "nested"; export x :: nested.x`))
+
+	nested := newTestRepo(t, "nested")
+	nested.commit(packageFiles("nested", `export x :: 1`))
+
+	unused := newTestRepo(t, "unused")
+	unused.commit(packageFiles("unused", ""))
+
+	pm := newProject(t,
+		pkg.DependencyInfo{Git: used.url()},
+		pkg.DependencyInfo{Git: nested.url()},
+		pkg.DependencyInfo{Git: unused.url()},
+	)
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+
+	writeFiles(t, pm.Dir, map[string]string{
+		"main.sb":        `import "io"; import "./lib/helpers"`,
+		"lib/helpers.sb": `import { x } from "used"`,
+	})
+
+	if err := pm.Tidy(); err != nil {
+		t.Fatalf("tidy failed: %s", err)
+	}
+
+	deps := loadDependencies(t, pm)
+	if len(deps) != 1 || deps[0].Name != "used" {
+		t.Errorf("expected only the imported package to stay in sunbird.toml, got %+v", deps)
+	}
+
+	lock, err := pkg.LoadLockfile(filepath.Join(pm.Dir, "sunbird.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Packages) != 2 || lock.Packages[0].Name != "nested" || lock.Packages[1].Name != "used" {
+		t.Errorf("expected only the imported packages to stay in sunbird.lock, got %+v", lock.Packages)
+	}
+
+	for name, expected := range map[string]bool{"used": true, "nested": true, "unused": false} {
+		_, err := os.Stat(filepath.Join(pm.Dir, ".sb_modules", name))
+		if installed := err == nil; installed != expected {
+			t.Errorf("%s: expected installed=%t, got %t", name, expected, installed)
+		}
+	}
+}
+
+func TestTidyNestedImports(t *testing.T) {
+	lazy := newTestRepo(t, "lazy")
+	lazy.commit(packageFiles("lazy", `export x :: 1`))
+
+	pm := newProject(t, pkg.DependencyInfo{Git: lazy.url()})
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+
+	writeFiles(t, pm.Dir, map[string]string{
+		"main.sb": "load :: fn() {\n  if true {\n    import \"lazy\"\n    return lazy.x\n  }\n}\nload()",
+	})
+
+	if err := pm.Tidy(); err != nil {
+		t.Fatalf("tidy failed: %s", err)
+	}
+
+	if deps := loadDependencies(t, pm); len(deps) != 1 || deps[0].Name != "lazy" {
+		t.Errorf("expected the package imported in a function to stay in sunbird.toml, got %+v", deps)
+	}
+	if _, err := os.Stat(filepath.Join(pm.Dir, ".sb_modules", "lazy")); err != nil {
+		t.Errorf("expected the package imported in a function to stay installed, got %v", err)
+	}
+}
+
+func TestTidyParseError(t *testing.T) {
+	pm := newProject(t)
+	writeFiles(t, pm.Dir, map[string]string{
+		"main.sb":       `import "./lib/broken"`,
+		"lib/broken.sb": `import { foo from "bar"`,
+	})
+
+	err := pm.Tidy()
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(pm.Dir, "lib", "broken.sb")+":") {
+		t.Errorf("expected a parse error naming lib/broken.sb, got %v", err)
+	}
+}
+
+func TestLockfile(t *testing.T) {
+	repo := newTestRepo(t, "utils")
+	repo.commit(packageFiles("utils", `export version :: 1`))
+	first, err := repo.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm := newProject(t, pkg.DependencyInfo{Git: repo.url(), Branch: "master"})
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+
+	lockPath := filepath.Join(pm.Dir, "sunbird.lock")
+	lock, err := pkg.LoadLockfile(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Packages) != 1 {
+		t.Fatalf("expected 1 locked package, got %+v", lock.Packages)
+	}
+	locked := lock.Packages[0]
+	if locked.Name != "utils" || locked.Commit != first.Hash().String() || !strings.HasPrefix(locked.Checksum, "sha256:") {
+		t.Errorf("unexpected locked package %+v", locked)
+	}
+
+	// A fresh install uses the locked commit, even though the branch has moved on
+	repo.commit(packageFiles("utils", `export version :: 2`))
+	mainPath := filepath.Join(pm.Dir, ".sb_modules", "utils", "main.sb")
+	if err := os.RemoveAll(filepath.Join(pm.Dir, ".sb_modules")); err != nil {
+		t.Fatal(err)
+	}
+
+	pm.Frozen = true
+	if err := pm.Install(); err != nil {
+		t.Fatalf("frozen install failed: %s", err)
+	}
+	if got := readFile(t, mainPath); got != `export version :: 1` {
+		t.Errorf("expected the locked commit to be installed, got %q", got)
+	}
+
+	// Changed files fail a frozen install, and are replaced by a normal one
+	writeFiles(t, pm.Dir, map[string]string{".sb_modules/utils/main.sb": "tampered"})
+	if err := pm.Install(); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("expected a checksum error, got %v", err)
+	}
+
+	pm.Frozen = false
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+	if got := readFile(t, mainPath); got != `export version :: 1` {
+		t.Errorf("expected the changed files to be replaced, got %q", got)
+	}
+
+	// Updating moves the lockfile forward
+	if err := pm.Update(); err != nil {
+		t.Fatalf("update failed: %s", err)
+	}
+	if got := readFile(t, mainPath); got != `export version :: 2` {
+		t.Errorf("expected update to install the newest commit, got %q", got)
+	}
+	lock, err = pkg.LoadLockfile(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Packages[0].Commit == locked.Commit || lock.Packages[0].Checksum == locked.Checksum {
+		t.Errorf("expected the lockfile to be updated, got %+v", lock.Packages[0])
+	}
+
+	// A lockfile whose checksum doesn't match what's downloaded is rejected
+	lock.Packages[0].Checksum = locked.Checksum
+	if err := pkg.SaveLockfile(lockPath, lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(pm.Dir, ".sb_modules")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.Install(); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+}
+
+func TestFrozenInstallOutOfDate(t *testing.T) {
+	repo := newTestRepo(t, "utils")
+	repo.commit(packageFiles("utils", ""))
+	repo.tag("v1.0.0")
+	repo.tag("v1.1.0")
+
+	pm := newProject(t, pkg.DependencyInfo{Git: repo.url(), Tag: "v1.0.0"})
+	pm.Frozen = true
+	if err := pm.Install(); err == nil {
+		t.Error("expected a frozen install without a lockfile to fail")
+	}
+
+	pm.Frozen = false
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+
+	config, err := pkg.LoadConfig(filepath.Join(pm.Dir, "sunbird.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Package.Dependencies[0].Tag = "v1.1.0"
+	if err := pkg.SaveConfig(filepath.Join(pm.Dir, "sunbird.toml"), config); err != nil {
+		t.Fatal(err)
+	}
+
+	pm.Frozen = true
+	if err := pm.Install(); err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Errorf("expected the lockfile to be out of date, got %v", err)
+	}
+}
+
+func TestVersionConstraints(t *testing.T) {
+	repo := newTestRepo(t, "utils")
+	for _, tag := range []string{"v0.3.1", "v0.3.4", "v0.4.0", "v1.0.0", "v1.2.0", "v1.3.0-beta", "v2.0.0"} {
+		repo.commit(packageFiles("utils", `export version :: "`+tag+`"`))
+		repo.tag(tag)
+	}
+
+	tests := []struct {
+		constraint string
+		expected   string
+	}{
+		{"^1.0", "v1.2.0"},
+		{"~0.3.1", "v0.3.4"},
+		{">=0.4 <1.1", "v1.0.0"},
+		{"*", "v2.0.0"},
+	}
+
+	for _, tt := range tests {
+		pm := newProject(t, pkg.DependencyInfo{Git: repo.url(), Version: tt.constraint})
+		if err := pm.Install(); err != nil {
+			t.Errorf("%s: install failed: %s", tt.constraint, err)
+			continue
+		}
+
+		lock, err := pkg.LoadLockfile(filepath.Join(pm.Dir, "sunbird.lock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lock.Packages) != 1 || lock.Packages[0].Version != tt.expected {
+			t.Errorf("%s: expected %s to be installed, got %+v", tt.constraint, tt.expected, lock.Packages)
+		}
+	}
+
+	// The locked version is kept until updating, as long as it still satisfies the constraint
+	pm := newProject(t, pkg.DependencyInfo{Git: repo.url(), Version: "~0.3.1"})
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+	config, err := pkg.LoadConfig(filepath.Join(pm.Dir, "sunbird.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Package.Dependencies[0].Version = "^0.3"
+	if err := pkg.SaveConfig(filepath.Join(pm.Dir, "sunbird.toml"), config); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+	mainPath := filepath.Join(pm.Dir, ".sb_modules", "utils", "main.sb")
+	if got := readFile(t, mainPath); got != `export version :: "v0.3.4"` {
+		t.Errorf("expected the locked version to be kept, got %q", got)
+	}
+
+	pm = newProject(t, pkg.DependencyInfo{Git: repo.url(), Version: "^3.0"})
+	if err := pm.Install(); err == nil || !strings.Contains(err.Error(), "app requires ^3.0") {
+		t.Errorf("expected no version to satisfy ^3.0, got %v", err)
+	}
+}
+
+func TestTransitiveDependencies(t *testing.T) {
+	base := newTestRepo(t, "base")
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "v2.0.0"} {
+		base.commit(packageFiles("base", `export version :: "`+tag+`"`))
+		base.tag(tag)
+	}
+
+	web := newTestRepo(t, "web")
+	web.commit(map[string]string{
+		"sunbird.toml": "[package]\nname = \"web\"\n\n[[package.dependencies]]\ngit = \"" + base.url() + "\"\nversion = \"^1.0\"\n",
+		"main.sb":      `import "base"; export version :: base.version`,
+	})
+	web.tag("v1.0.0")
+
+	pm := newProject(t,
+		pkg.DependencyInfo{Git: web.url(), Version: "^1"},
+		pkg.DependencyInfo{Git: base.url(), Version: ">=1.0.0 <1.1.0"},
+	)
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+
+	// The version of base satisfies both the project and web
+	lock, err := pkg.LoadLockfile(filepath.Join(pm.Dir, "sunbird.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Packages) != 2 || lock.Packages[0].Name != "base" || lock.Packages[0].Version != "v1.0.0" {
+		t.Errorf("expected base v1.0.0 to be locked, got %+v", lock.Packages)
+	}
+	if got := readFile(t, filepath.Join(pm.Dir, ".sb_modules", "base", "main.sb")); got != `export version :: "v1.0.0"` {
+		t.Errorf("expected base v1.0.0 to be installed, got %q", got)
+	}
+
+	// Packages needed only by other packages are installed too
+	pm = newProject(t, pkg.DependencyInfo{Git: web.url()})
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+	if got := readFile(t, filepath.Join(pm.Dir, ".sb_modules", "base", "main.sb")); got != `export version :: "v1.1.0"` {
+		t.Errorf("expected the newest base allowed by web to be installed, got %q", got)
+	}
+	if deps := loadDependencies(t, pm); len(deps) != 1 {
+		t.Errorf("expected transitive packages to stay out of sunbird.toml, got %+v", deps)
+	}
+
+	// Conflicting requirements report the chain of packages leading to each
+	pm = newProject(t,
+		pkg.DependencyInfo{Git: web.url(), Version: "^1"},
+		pkg.DependencyInfo{Git: base.url(), Version: "^2.0"},
+	)
+	err = pm.Install()
+	if err == nil {
+		t.Fatal("expected conflicting requirements to fail")
+	}
+	for _, expected := range []string{
+		"no version of base satisfies every requirement",
+		"app requires ^2.0",
+		"app -> web@v1.0.0 requires ^1.0",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected the error to contain %q, got %s", expected, err)
+		}
+	}
+}
+
+func TestPathDependencies(t *testing.T) {
+	utils := newTestRepo(t, "utils")
+	utils.commit(packageFiles("utils", `export x :: 1`))
+
+	shared := filepath.Join(t.TempDir(), "shared")
+	writeFiles(t, shared, map[string]string{
+		"sunbird.toml": "[package]\nname = \"shared\"\nmain = \"main.sb\"\n\n[[package.dependencies]]\ngit = \"" + utils.url() + "\"\n",
+		"main.sb":      `import "utils"`,
+	})
+
+	pm := newProject(t, pkg.DependencyInfo{Path: shared})
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+
+	// The path dependency is used where it is, but what it needs is installed
+	if _, err := os.Stat(filepath.Join(pm.Dir, ".sb_modules", "shared")); !os.IsNotExist(err) {
+		t.Errorf("expected the path dependency not to be copied, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(pm.Dir, ".sb_modules", "utils", "main.sb")); err != nil {
+		t.Errorf("expected the dependency of the path dependency to be installed: %s", err)
+	}
+
+	deps := loadDependencies(t, pm)
+	if len(deps) != 1 || deps[0].Name != "shared" {
+		t.Errorf("expected the path dependency to be named, got %+v", deps)
+	}
+	if dir, ok := pkg.FindPackage(pm.Dir, "shared"); !ok || dir != shared {
+		t.Errorf("expected shared to be found at %s, got %q", shared, dir)
+	}
+
+	missing := newProject(t, pkg.DependencyInfo{Path: "./missing"})
+	if err := missing.Install(); err == nil {
+		t.Error("expected a missing path dependency to fail")
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	utils := newTestRepo(t, "utils")
+	utils.commit(packageFiles("utils", `export x :: 1`))
+	utils.tag("v1.0.0")
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"sunbird.toml":               "[workspace]\nmembers = [\"packages/*\"]\n",
+		"packages/api/sunbird.toml":  "[package]\nname = \"api\"\nmain = \"main.sb\"\n\n[[package.dependencies]]\ngit = \"" + utils.url() + "\"\nversion = \"^1.0\"\n",
+		"packages/api/main.sb":       `import "utils"; import "core"`,
+		"packages/core/sunbird.toml": "[package]\nname = \"core\"\nmain = \"main.sb\"\n",
+		"packages/core/main.sb":      `export name :: "core"`,
+	})
+
+	// Installing from a member installs for the whole workspace, at its root
+	pm := &pkg.PackageManager{Dir: filepath.Join(root, "packages", "core"), Out: io.Discard}
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, ".sb_modules", "utils")); err != nil {
+		t.Errorf("expected utils to be installed at the workspace root: %s", err)
+	}
+	lock, err := pkg.LoadLockfile(filepath.Join(root, "sunbird.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Packages) != 1 || lock.Packages[0].Name != "utils" {
+		t.Errorf("expected utils in the shared lockfile, got %+v", lock.Packages)
+	}
+	for _, member := range []string{"api", "core"} {
+		for _, name := range []string{".sb_modules", "sunbird.lock"} {
+			if _, err := os.Stat(filepath.Join(root, "packages", member, name)); !os.IsNotExist(err) {
+				t.Errorf("expected no %s in %s, got %v", name, member, err)
+			}
+		}
+	}
+
+	// Members find each other and the shared packages
+	api := filepath.Join(root, "packages", "api")
+	if dir, ok := pkg.FindPackage(api, "core"); !ok || dir != filepath.Join(root, "packages", "core") {
+		t.Errorf("expected core to be found, got %q", dir)
+	}
+	if dir, ok := pkg.FindPackage(api, "utils"); !ok || dir != filepath.Join(root, ".sb_modules", "utils") {
+		t.Errorf("expected utils to be found, got %q", dir)
+	}
+
+	// Tidying keeps what any member imports
+	if err := pm.Tidy(); err != nil {
+		t.Fatalf("tidy failed: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".sb_modules", "utils")); err != nil {
+		t.Errorf("expected utils to be kept: %s", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	repo := newTestRepo(t, "utils")
+	repo.commit(packageFiles("utils", `export version :: 1`))
+
+	cache := &pkg.Cache{Dir: t.TempDir()}
+	pm := newProject(t, pkg.DependencyInfo{Git: repo.url()})
+	pm.CacheDir = cache.Dir
+	if err := pm.Install(); err != nil {
+		t.Fatalf("install failed: %s", err)
+	}
+
+	entries, err := cache.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Kind != "git" || entries[0].Name != repo.url() ||
+		entries[1].Kind != "package" || !strings.HasPrefix(entries[1].Name, "utils sha256:") {
+		t.Errorf("unexpected cache entries %+v", entries)
+	}
+
+	// Updating fetches new commits into the cached repository
+	repo.commit(packageFiles("utils", `export version :: 2`))
+	if err := pm.Update(); err != nil {
+		t.Fatalf("update failed: %s", err)
+	}
+	mainPath := filepath.Join(pm.Dir, ".sb_modules", "utils", "main.sb")
+	if got := readFile(t, mainPath); got != `export version :: 2` {
+		t.Errorf("expected update to fetch the newest commit, got %q", got)
+	}
+
+	// Once cached, packages install without their repository
+	if err := os.RemoveAll(repo.dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(pm.Dir, ".sb_modules")); err != nil {
+		t.Fatal(err)
+	}
+	pm.Offline = true
+	if err := pm.Install(); err != nil {
+		t.Fatalf("offline install failed: %s", err)
+	}
+	if got := readFile(t, mainPath); got != `export version :: 2` {
+		t.Errorf("expected the cached package to be installed, got %q", got)
+	}
+
+	// A new project can resolve from the cached repository too
+	other := newProject(t, pkg.DependencyInfo{Git: repo.url()})
+	other.CacheDir, other.Offline = cache.Dir, true
+	if err := other.Install(); err != nil {
+		t.Fatalf("offline install failed: %s", err)
+	}
+
+	missing := newProject(t, pkg.DependencyInfo{Git: "file:///nowhere/repo"})
+	missing.CacheDir, missing.Offline = cache.Dir, true
+	if err := missing.Install(); err == nil || !strings.Contains(err.Error(), "isn't in the cache") {
+		t.Errorf("expected an uncached package to fail offline, got %v", err)
+	}
+
+	if err := cache.Clean(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := cache.List(); err != nil || len(entries) != 0 {
+		t.Errorf("expected an empty cache, got %+v, %v", entries, err)
+	}
+}
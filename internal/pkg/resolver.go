@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/go-git/go-git/v5"
@@ -53,89 +55,66 @@ func (p *PackageURL) GetGitURL() string {
 	return fmt.Sprintf("https://%s/%s/%s.git", p.Host, p.Owner, p.Repo)
 }
 
-func (p *PackageURL) GetCachePath(cacheDir string) string {
-	var versionSuffix string
-	if p.Version != "" {
-		versionSuffix = "@" + p.Version
-	} else {
-		versionSuffix = "@latest"
+// ParseDependency parses a dependency given on the command line: either
+// host/owner/repo or the URL of a git repository, like file:///srv/git/utils,
+// optionally followed by @ and a version constraint, tag or branch. Paths
+// ending in .tar.gz or .tgz are package archives.
+func ParseDependency(spec string) (DependencyInfo, error) {
+	var dep DependencyInfo
+	var version string
+
+	if isArchivePath(spec) {
+		dep.Archive = spec
+		return dep, nil
 	}
 
-	return filepath.Join(cacheDir, p.Host, p.Owner, p.Repo+versionSuffix)
-}
-
-func DownloadPackage(pkgURL *PackageURL) error {
-	gitURL := pkgURL.GetGitURL()
-	fmt.Printf("  Cloning from: %s\n", gitURL)
-
-	cloneOptions := &git.CloneOptions{
-		URL:      gitURL,
-		Progress: os.Stdout,
-	}
-
-	// If a specific version is specified
-	if pkgURL.Version != "" {
-		if pkgURL.IsTag {
-			// Clone specific tag
-			cloneOptions.ReferenceName = plumbing.NewTagReferenceName(pkgURL.Version)
-			cloneOptions.SingleBranch = true
-		} else {
-			// Clone specific branch
-			cloneOptions.ReferenceName = plumbing.NewBranchReferenceName(pkgURL.Version)
-			cloneOptions.SingleBranch = true
+	if strings.Contains(spec, "://") {
+		dep.Git = spec
+		// An @ before the path belongs to the credentials, e.g. ssh://git@host/repo
+		if at := strings.LastIndex(spec, "@"); at > strings.LastIndex(spec, "/") {
+			dep.Git, version = spec[:at], spec[at+1:]
 		}
-	}
-
-	tempDir := filepath.Join(os.TempDir(), "sunbird_cache", pkgURL.Owner, pkgURL.Repo)
-
-	if _, err := os.Stat(tempDir); err == nil {
-		err = os.RemoveAll(tempDir)
+	} else {
+		pkgURL, err := ParsePackageURL(spec)
 		if err != nil {
-			return fmt.Errorf("failed to remove existing temp directory: %w", err)
+			return dep, err
 		}
+		dep.Git = pkgURL.GetGitURL()
+		version = pkgURL.Version
 	}
 
-	// Clone the repository
-	_, err := git.PlainClone(tempDir, false, cloneOptions)
-	if err != nil {
-		return fmt.Errorf("failed to clone repository: %w", err)
+	switch {
+	case strings.HasPrefix(version, "v"):
+		dep.Tag = version
+	case isConstraint(version):
+		dep.Version = version
+	default:
+		dep.Branch = version
 	}
 
-	// Read config from the cloned package, get the name and move the package to .sunbird/host/owner/repo/version
-	configPath := filepath.Join(tempDir, "sunbird.toml")
-	config, err := LoadConfig(configPath)
-	if err != nil {
-		return errors.New("failed to load config from downloaded package: " + err.Error())
-	}
+	return dep, nil
+}
 
-	packageName := config.Package.Name
-	if packageName == "" {
-		return errors.New("package name is missing in sunbird.toml")
+// ParseRegistryDependency parses a dependency on a package in the registry
+// directory, given as the package name optionally followed by @ and a
+// version constraint
+func ParseRegistryDependency(registry, spec string) (DependencyInfo, error) {
+	name, version, _ := strings.Cut(spec, "@")
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return DependencyInfo{}, fmt.Errorf("invalid package name %q", name)
 	}
 
-	finalPath := filepath.Join(dependencyDirectory, packageName)
-
-	// Remove existing directory if it exists
-	if _, err :=
-		os.Stat(finalPath); err == nil {
-		err = os.RemoveAll(finalPath)
-		if err != nil {
-			return fmt.Errorf("failed to remove existing directory: %w", err)
+	if version != "" {
+		if _, err := ParseConstraint(version); err != nil {
+			return DependencyInfo{}, err
 		}
 	}
 
-	err = os.MkdirAll(filepath.Dir(finalPath), 0o755)
-	if err != nil {
-		return fmt.Errorf("failed to create directory for package: %w", err)
-	}
-
-	err = moveDirectory(tempDir, finalPath)
-	if err != nil {
-		return fmt.Errorf("failed to move package to final location: %w", err)
-	}
+	return DependencyInfo{Name: name, Registry: registry, Version: version}, nil
+}
 
-	fmt.Printf("  ✓ Downloaded\n")
-	return nil
+func isArchivePath(spec string) bool {
+	return !strings.Contains(spec, "://") && (strings.HasSuffix(spec, ".tar.gz") || strings.HasSuffix(spec, ".tgz"))
 }
 
 func moveDirectory(src, dst string) error {
@@ -202,3 +181,510 @@ func copyFile(src, dst string) error {
 	}
 	return os.Chmod(dst, info.Mode())
 }
+
+// Keys of packages that don't come from a git repository start with these
+const (
+	archivePrefix  = "archive:"
+	registryPrefix = "registry:"
+)
+
+// dependencyKey identifies the package dep refers to: the URL of its repository,
+// or the absolute path of its archive or of its entry in a registry. Paths
+// are relative to dir, which is empty for packages that weren't found on disk.
+// Path dependencies have no key, since they aren't installed.
+func dependencyKey(dep DependencyInfo, dir string) (string, error) {
+	switch {
+	case dep.Git != "":
+		return dep.Git, nil
+	case dep.Archive == "" && dep.Registry == "":
+		return "", nil
+	}
+
+	local := dep.Archive + dep.Registry
+	if dir == "" && !filepath.IsAbs(local) {
+		return "", fmt.Errorf("%s: relative paths can only be used by packages on disk", &dep)
+	}
+
+	if dep.Archive != "" {
+		return archivePrefix + resolveConfigPath(dir, dep.Archive), nil
+	}
+	return registryPrefix + filepath.Join(resolveConfigPath(dir, dep.Registry), dep.Name), nil
+}
+
+// requirement is a dependency listed by the project or by one of its packages
+type requirement struct {
+	dep   DependencyInfo
+	from  string   // key of the package listing it, or the directory of a local package
+	chain []string // packages leading to the requirement, starting with the project
+}
+
+// accepts reports whether the locked package satisfies the requirement
+func (r *requirement) accepts(p LockedPackage) bool {
+	switch {
+	case r.dep.Tag != "":
+		return p.Version == r.dep.Tag
+	case r.dep.Branch != "":
+		return p.Version == "" && p.Branch == r.dep.Branch
+	case r.dep.Version != "":
+		c, err := ParseConstraint(r.dep.Version)
+		if err != nil {
+			return false
+		}
+		v, err := ParseVersion(p.Version)
+		return err == nil && c.Allows(v)
+	default:
+		return true
+	}
+}
+
+// selection is the version of a package the resolver picked
+type selection struct {
+	name    string
+	key     string
+	version string // tag the commit was picked by, or version of the archive
+	branch  string // branch the commit was picked from, if any
+	commit  plumbing.Hash
+	deps    []DependencyInfo
+
+	// Packages picked from an archive have no commit
+	git      string
+	archive  string
+	registry string // directory of the registry the archive is in, if any
+}
+
+func (s *selection) label() string {
+	if s.version != "" {
+		return s.name + "@" + s.version
+	}
+	return s.name + "@" + s.commit.String()[:7]
+}
+
+// same reports whether both selections are the same version of the same package
+func (s *selection) same(other *selection) bool {
+	return s.key == other.key && s.commit == other.commit && s.version == other.version && s.archive == other.archive
+}
+
+// matches reports whether the locked package is the selected version
+func (s *selection) matches(p LockedPackage) bool {
+	if s.git != "" {
+		return p.Commit == s.commit.String()
+	}
+	return p.Version == s.version
+}
+
+// maxResolveRounds bounds how often the resolver revisits its choices
+const maxResolveRounds = 100
+
+// resolver picks a version of every package the project needs, directly or
+// through other packages, such that every requirement is satisfied
+type resolver struct {
+	cache    *Cache
+	offline  bool // only use repositories already in the cache
+	repos    map[string]*git.Repository
+	reqs     map[string][]requirement // by repository
+	selected map[string]*selection    // by repository
+
+	// Locked packages are preferred over newer versions while they satisfy
+	// every requirement. If frozen is set, nothing else is accepted.
+	locked map[string]LockedPackage
+	frozen bool
+}
+
+// newResolver creates a resolver reading repositories through cache. Paths
+// in locked packages are relative to root.
+func newResolver(cache *Cache, root string, offline bool, locked []LockedPackage, frozen bool) *resolver {
+	r := &resolver{
+		cache:    cache,
+		offline:  offline,
+		repos:    make(map[string]*git.Repository),
+		reqs:     make(map[string][]requirement),
+		selected: make(map[string]*selection),
+		locked:   make(map[string]LockedPackage),
+		frozen:   frozen,
+	}
+
+	for _, p := range locked {
+		r.locked[p.key(root)] = p
+	}
+	return r
+}
+
+// resolve selects the packages needed to satisfy the requirements set so far.
+// The requirements of the selected packages are added as they're picked.
+func (r *resolver) resolve() error {
+	for range maxResolveRounds {
+		changed := false
+
+		for _, url := range slices.Sorted(maps.Keys(r.reqs)) {
+			// Packages no other package needs anymore are dropped with their own requirements
+			if len(r.reqs[url]) == 0 {
+				delete(r.reqs, url)
+				if _, ok := r.selected[url]; ok {
+					delete(r.selected, url)
+					_ = r.setRequirements(url, "", nil, nil)
+				}
+				changed = true
+				continue
+			}
+
+			sel, err := r.choose(url)
+			if err != nil {
+				return err
+			}
+
+			if prev, ok := r.selected[url]; ok && prev.same(sel) {
+				continue
+			}
+
+			r.selected[url] = sel
+			chain := append(slices.Clone(r.reqs[url][0].chain), sel.label())
+			if err := r.setRequirements(url, "", chain, sel.deps); err != nil {
+				return fmt.Errorf("%s: %w", sel.label(), err)
+			}
+			changed = true
+		}
+
+		if !changed {
+			return nil
+		}
+	}
+
+	return errors.New("could not find a consistent set of dependency versions")
+}
+
+// setRequirements replaces the requirements listed by from, the key or directory
+// of a package. Paths in deps are relative to dir, or must be absolute if it's
+// empty. Path dependencies aren't resolved.
+func (r *resolver) setRequirements(from, dir string, chain []string, deps []DependencyInfo) error {
+	for key, reqs := range r.reqs {
+		r.reqs[key] = slices.DeleteFunc(reqs, func(req requirement) bool {
+			return req.from == from
+		})
+	}
+
+	for _, dep := range deps {
+		key, err := dependencyKey(dep, dir)
+		if err != nil {
+			return err
+		}
+		if key != "" {
+			r.reqs[key] = append(r.reqs[key], requirement{dep: dep, from: from, chain: chain})
+		}
+	}
+	return nil
+}
+
+// choose picks the version of the package satisfying all its requirements
+func (r *resolver) choose(key string) (*selection, error) {
+	reqs := r.reqs[key]
+
+	var tag, branch string
+	var constraints []Constraint
+	refs := 0
+	for _, req := range reqs {
+		switch {
+		case req.dep.Tag != "" && req.dep.Tag != tag:
+			tag = req.dep.Tag
+			refs++
+		case req.dep.Branch != "" && req.dep.Branch != branch:
+			branch = req.dep.Branch
+			refs++
+		case req.dep.Version != "":
+			c, err := ParseConstraint(req.dep.Version)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", strings.Join(req.chain, " -> "), err)
+			}
+			constraints = append(constraints, c)
+		}
+	}
+
+	// Only a single tag or branch can be checked out, and archives have neither
+	if refs > 1 || (branch != "" && len(constraints) > 0) || (refs > 0 && !r.isGit(key)) {
+		return nil, conflictError(r.packageName(key), reqs)
+	}
+
+	switch {
+	case strings.HasPrefix(key, archivePrefix):
+		return r.chooseArchive(key, constraints)
+	case strings.HasPrefix(key, registryPrefix):
+		return r.chooseRegistry(key, constraints)
+	}
+
+	url := key
+	if locked, ok := r.locked[url]; ok && acceptsAll(reqs, locked) {
+		return r.selectLocked(url, locked)
+	}
+
+	if r.frozen {
+		return nil, r.outOfDate(key)
+	}
+
+	repo, err := r.repository(url)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case tag != "":
+		if len(constraints) > 0 {
+			v, err := ParseVersion(tag)
+			if err != nil || !allowedByAll(constraints, v) {
+				return nil, conflictError(r.packageName(url), reqs)
+			}
+		}
+		hash, err := resolveRevision(repo, tag)
+		if err != nil {
+			return nil, err
+		}
+		return r.selectCommit(url, tag, "", hash)
+
+	case len(constraints) > 0:
+		tag, ok, err := newestAllowedTag(repo, constraints)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, conflictError(r.packageName(url), reqs)
+		}
+		hash, err := resolveRevision(repo, tag)
+		if err != nil {
+			return nil, err
+		}
+		return r.selectCommit(url, tag, "", hash)
+
+	default:
+		hash, err := resolveRevision(repo, branch)
+		if err != nil {
+			return nil, err
+		}
+		return r.selectCommit(url, "", branch, hash)
+	}
+}
+
+// chooseArchive selects the package in an archive, whose version is fixed
+func (r *resolver) chooseArchive(key string, constraints []Constraint) (*selection, error) {
+	path := strings.TrimPrefix(key, archivePrefix)
+
+	manifest, config, err := archiveConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := ParseVersion(manifest.Version)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if !allowedByAll(constraints, v) {
+		return nil, conflictError(manifest.Name, r.reqs[key])
+	}
+
+	if locked, ok := r.locked[key]; r.frozen && (!ok || locked.Version != manifest.Version) {
+		return nil, r.outOfDate(key)
+	}
+
+	sel, err := r.newSelection(key, manifest.Version, "", plumbing.ZeroHash, config)
+	if err != nil {
+		return nil, err
+	}
+	sel.archive = path
+	return sel, nil
+}
+
+// chooseRegistry selects the newest version in a registry that satisfies every
+// constraint, or the locked one while it still does
+func (r *resolver) chooseRegistry(key string, constraints []Constraint) (*selection, error) {
+	dir, name := filepath.Split(strings.TrimPrefix(key, registryPrefix))
+	dir = filepath.Clean(dir)
+
+	versions, err := registryVersions(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%s isn't in the registry at %s", name, dir)
+	}
+
+	locked, ok := r.locked[key]
+	_, available := versions[locked.Version]
+	if !ok || !available || !acceptsAll(r.reqs[key], locked) {
+		if r.frozen {
+			return nil, r.outOfDate(key)
+		}
+
+		best, ok := newestAllowedVersion(slices.Collect(maps.Keys(versions)), constraints)
+		if !ok {
+			return nil, conflictError(name, r.reqs[key])
+		}
+		locked.Version = best
+	}
+
+	path := versions[locked.Version]
+	manifest, config, err := archiveConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Name != name || manifest.Version != locked.Version {
+		return nil, fmt.Errorf("%s: expected %s %s, but the archive contains %s %s",
+			path, name, locked.Version, manifest.Name, manifest.Version)
+	}
+
+	sel, err := r.newSelection(key, manifest.Version, "", plumbing.ZeroHash, config)
+	if err != nil {
+		return nil, err
+	}
+	sel.archive = path
+	sel.registry = dir
+	return sel, nil
+}
+
+func (r *resolver) isGit(key string) bool {
+	return !strings.HasPrefix(key, archivePrefix) && !strings.HasPrefix(key, registryPrefix)
+}
+
+func (r *resolver) outOfDate(key string) error {
+	return fmt.Errorf("%s is out of date: no locked version of %s satisfies %s",
+		lockfileName, r.packageName(key), strings.Join(requirementSpecs(r.reqs[key]), ", "))
+}
+
+// selectLocked selects the locked package. Its name and dependencies are read
+// from the cached package files if possible, which saves fetching the repository.
+func (r *resolver) selectLocked(url string, locked LockedPackage) (*selection, error) {
+	commit := plumbing.NewHash(locked.Commit)
+	if locked.Checksum != "" {
+		if config, ok := r.cache.packageConfig(locked.Checksum); ok {
+			return r.newSelection(url, locked.Version, locked.Branch, commit, config)
+		}
+	}
+	return r.selectCommit(url, locked.Version, locked.Branch, commit)
+}
+
+// selectCommit reads the name and dependencies of the package at commit
+func (r *resolver) selectCommit(url, version, branch string, commit plumbing.Hash) (*selection, error) {
+	repo, err := r.repository(url)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := readCommitConfig(repo, commit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to load config from downloaded package: %w", url, err)
+	}
+
+	return r.newSelection(url, version, branch, commit, config)
+}
+
+func (r *resolver) newSelection(key, version, branch string, commit plumbing.Hash, config *Config) (*selection, error) {
+	name := config.Package.Name
+	if name == "" {
+		return nil, fmt.Errorf("%s: package name is missing in sunbird.toml", key)
+	}
+
+	for _, req := range r.reqs[key] {
+		if req.dep.Name != "" && req.dep.Name != name {
+			return nil, fmt.Errorf("expected package %s, but %s contains %s", req.dep.Name, key, name)
+		}
+	}
+
+	sel := &selection{
+		name:    name,
+		key:     key,
+		version: version,
+		branch:  branch,
+		commit:  commit,
+		deps:    config.Package.Dependencies,
+	}
+	if r.isGit(key) {
+		sel.git = key
+	}
+	return sel, nil
+}
+
+// repository opens the cached repository at url, fetching it once per resolver
+func (r *resolver) repository(url string) (*git.Repository, error) {
+	if repo, ok := r.repos[url]; ok {
+		return repo, nil
+	}
+
+	repo, err := r.cache.repository(url, r.offline)
+	if err != nil {
+		return nil, err
+	}
+
+	r.repos[url] = repo
+	return repo, nil
+}
+
+func acceptsAll(reqs []requirement, p LockedPackage) bool {
+	for _, req := range reqs {
+		if !req.accepts(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func allowedByAll(constraints []Constraint, v Version) bool {
+	for _, c := range constraints {
+		if !c.Allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// newestAllowedTag finds the tag with the highest version satisfying every constraint
+func newestAllowedTag(repo *git.Repository, constraints []Constraint) (string, bool, error) {
+	tags, err := repositoryTags(repo)
+	if err != nil {
+		return "", false, err
+	}
+
+	best, ok := newestAllowedVersion(tags, constraints)
+	return best, ok, nil
+}
+
+// newestAllowedVersion finds the highest of the versions satisfying every constraint
+func newestAllowedVersion(tags []string, constraints []Constraint) (string, bool) {
+	var best string
+	var bestVersion Version
+	for _, tag := range tags {
+		v, err := ParseVersion(tag)
+		if err != nil || !allowedByAll(constraints, v) {
+			continue
+		}
+		if best == "" || v.Compare(bestVersion) > 0 {
+			best, bestVersion = tag, v
+		}
+	}
+
+	return best, best != ""
+}
+
+func conflictError(name string, reqs []requirement) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "no version of %s satisfies every requirement:", name)
+	for _, req := range reqs {
+		fmt.Fprintf(&b, "\n  %s requires %s", strings.Join(req.chain, " -> "), req.dep.spec())
+	}
+	return errors.New(b.String())
+}
+
+func (r *resolver) packageName(url string) string {
+	if sel, ok := r.selected[url]; ok {
+		return sel.name
+	}
+	for _, req := range r.reqs[url] {
+		if req.dep.Name != "" {
+			return req.dep.Name
+		}
+	}
+	return url
+}
+
+func requirementSpecs(reqs []requirement) []string {
+	specs := make([]string, len(reqs))
+	for i, req := range reqs {
+		specs[i] = req.dep.spec()
+	}
+	return specs
+}
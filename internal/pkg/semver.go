@@ -0,0 +1,250 @@
+package pkg
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version, like v1.2.3 or 2.0.0-beta.1
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string
+}
+
+// ParseVersion parses a version with an optional "v" prefix.
+// Missing minor and patch numbers are zero, so "1.2" is 1.2.0.
+func ParseVersion(s string) (Version, error) {
+	v, _, err := parsePartialVersion(s)
+	return v, err
+}
+
+// parsePartialVersion is like ParseVersion, but also reports how
+// many of the three numbers were given
+func parsePartialVersion(s string) (Version, int, error) {
+	var v Version
+
+	rest := strings.TrimPrefix(s, "v")
+	rest, v.Pre, _ = strings.Cut(rest, "-")
+	rest, _, _ = strings.Cut(rest, "+") // build metadata doesn't affect the order
+
+	parts := strings.Split(rest, ".")
+	if len(parts) > 3 {
+		return v, 0, fmt.Errorf("invalid version %q", s)
+	}
+
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, 0, fmt.Errorf("invalid version %q", s)
+		}
+		*numbers[i] = n
+	}
+
+	return v, len(parts), nil
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Compare returns -1, 0 or +1 depending on whether v sorts before, with or after w
+func (v Version) Compare(w Version) int {
+	if c := cmp.Compare(v.Major, w.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Minor, w.Minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Patch, w.Patch); c != 0 {
+		return c
+	}
+
+	// A pre-release comes before the release itself
+	switch {
+	case v.Pre == w.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case w.Pre == "":
+		return -1
+	default:
+		return comparePrerelease(v.Pre, w.Pre)
+	}
+}
+
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range min(len(as), len(bs)) {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = cmp.Compare(an, bn)
+		case aErr == nil: // numeric identifiers sort first
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(as[i], bs[i])
+		}
+
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// Constraint is a set of versions, like "^1.2", "~0.3.1" or ">=1.0 <2.0".
+// Versions have to satisfy every space-separated part of the constraint.
+type Constraint struct {
+	text   string
+	bounds []bound
+}
+
+type bound struct {
+	op      string // one of >=, >, <=, <, =
+	version Version
+}
+
+func (b bound) allows(v Version) bool {
+	c := v.Compare(b.version)
+	switch b.op {
+	case ">=":
+		return c >= 0
+	case ">":
+		return c > 0
+	case "<=":
+		return c <= 0
+	case "<":
+		return c < 0
+	default:
+		return c == 0
+	}
+}
+
+// ParseConstraint parses a version constraint. An empty constraint or "*" allows any version.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{text: s}
+
+	for _, part := range strings.Fields(s) {
+		bounds, err := parseConstraintPart(part)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: %w", s, err)
+		}
+		c.bounds = append(c.bounds, bounds...)
+	}
+
+	return c, nil
+}
+
+func parseConstraintPart(part string) ([]bound, error) {
+	if part == "*" {
+		return nil, nil
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		rest, ok := strings.CutPrefix(part, op)
+		if !ok {
+			continue
+		}
+
+		v, given, err := parsePartialVersion(rest)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "^":
+			return []bound{{">=", v}, {"<", caretLimit(v, given)}}, nil
+		case "~":
+			return []bound{{">=", v}, {"<", tildeLimit(v, given)}}, nil
+		default:
+			return []bound{{op, v}}, nil
+		}
+	}
+
+	// A bare version is an exact match, except that missing numbers
+	// stand for any value, so "1.2" allows 1.2.0 and 1.2.5 alike
+	v, given, err := parsePartialVersion(part)
+	if err != nil {
+		return nil, err
+	}
+	if given == 3 {
+		return []bound{{"=", v}}, nil
+	}
+	return []bound{{">=", v}, {"<", tildeLimit(v, given)}}, nil
+}
+
+// caretLimit is the first version ^v doesn't allow: the next version
+// changing the leftmost non-zero number of the ones given
+func caretLimit(v Version, given int) Version {
+	switch {
+	case v.Major > 0 || given == 1:
+		return Version{Major: v.Major + 1}
+	case v.Minor > 0 || given == 2:
+		return Version{Minor: v.Minor + 1}
+	default:
+		return Version{Patch: v.Patch + 1}
+	}
+}
+
+// tildeLimit is the first version ~v doesn't allow: only the
+// patch number may change, or the minor one if it wasn't given
+func tildeLimit(v Version, given int) Version {
+	if given == 1 {
+		return Version{Major: v.Major + 1}
+	}
+	return Version{Major: v.Major, Minor: v.Minor + 1}
+}
+
+// Allows reports whether v satisfies the constraint. Pre-releases are only
+// allowed by constraints that mention a pre-release of the same version.
+func (c Constraint) Allows(v Version) bool {
+	if v.Pre != "" && !c.mentionsPrerelease(v) {
+		return false
+	}
+
+	for _, b := range c.bounds {
+		if !b.allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Constraint) mentionsPrerelease(v Version) bool {
+	for _, b := range c.bounds {
+		bv := b.version
+		if bv.Pre != "" && bv.Major == v.Major && bv.Minor == v.Minor && bv.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Constraint) String() string {
+	return c.text
+}
+
+// isConstraint reports whether s looks like a version constraint
+// rather than the name of a tag or branch
+func isConstraint(s string) bool {
+	if s == "" {
+		return false
+	}
+	if strings.ContainsAny(s[:1], "^~<>=*0123456789") {
+		_, err := ParseConstraint(s)
+		return err == nil
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package pkg_test
+
+import (
+	"testing"
+
+	"github.com/radeqq007/sunbird/internal/pkg"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"v1.2.3", "1.2.3"},
+		{"1.2", "1.2.0"},
+		{"v2", "2.0.0"},
+		{"1.0.0-beta.1", "1.0.0-beta.1"},
+		{"1.0.0+build.5", "1.0.0"},
+	}
+
+	for _, tt := range tests {
+		v, err := pkg.ParseVersion(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if v.String() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, v)
+		}
+	}
+
+	for _, input := range []string{"", "main", "1.2.3.4", "1.x", "v-1"} {
+		if _, err := pkg.ParseVersion(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	ordered := []string{"0.9.0", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0", "1.0.1", "1.10.0", "2.0.0"}
+
+	for i := range len(ordered) - 1 {
+		a, _ := pkg.ParseVersion(ordered[i])
+		b, _ := pkg.ParseVersion(ordered[i+1])
+		if a.Compare(b) >= 0 || b.Compare(a) <= 0 {
+			t.Errorf("expected %s < %s", a, b)
+		}
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		allowed    []string
+		denied     []string
+	}{
+		{"^1.2", []string{"1.2.0", "1.9.3"}, []string{"1.1.9", "2.0.0"}},
+		{"^0.3.1", []string{"0.3.1", "0.3.9"}, []string{"0.3.0", "0.4.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~0.3.1", []string{"0.3.1", "0.3.7"}, []string{"0.4.0"}},
+		{"~1", []string{"1.0.0", "1.5.0"}, []string{"2.0.0"}},
+		{">=1.0 <2.0", []string{"1.0.0", "1.99.0"}, []string{"0.9.0", "2.0.0"}},
+		{"1.2", []string{"1.2.0", "1.2.8"}, []string{"1.3.0"}},
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+		{"*", []string{"0.0.1", "5.0.0"}, []string{"1.0.0-rc.1"}},
+		{"^1.0", nil, []string{"1.1.0-beta"}},
+		{">=1.1.0-beta", []string{"1.1.0-beta.2", "1.1.0"}, []string{"1.2.0-beta"}},
+	}
+
+	for _, tt := range tests {
+		c, err := pkg.ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.constraint, err)
+			continue
+		}
+
+		for _, s := range tt.allowed {
+			if v, _ := pkg.ParseVersion(s); !c.Allows(v) {
+				t.Errorf("expected %s to allow %s", tt.constraint, s)
+			}
+		}
+		for _, s := range tt.denied {
+			if v, _ := pkg.ParseVersion(s); c.Allows(v) {
+				t.Errorf("expected %s not to allow %s", tt.constraint, s)
+			}
+		}
+	}
+
+	for _, input := range []string{"^", ">=x", "~1.2.3.4", "1.0 || 2.0"} {
+		if _, err := pkg.ParseConstraint(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
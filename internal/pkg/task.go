@@ -0,0 +1,302 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Script is a task in the [scripts] table of sunbird.toml. It's written either as
+// the command alone, or as a table with the command, the tasks to run before
+// it and environment variables to set.
+type Script struct {
+	Run  string            `toml:"run,omitempty"`
+	Deps []string          `toml:"deps,omitempty"`
+	Env  map[string]string `toml:"env,omitempty"`
+}
+
+func (s *Script) UnmarshalTOML(data any) error {
+	switch data := data.(type) {
+	case string:
+		s.Run = data
+		return nil
+	case map[string]any:
+		for key, value := range data {
+			var ok bool
+			switch key {
+			case "run":
+				s.Run, ok = value.(string)
+			case "deps":
+				s.Deps, ok = stringList(value)
+			case "env":
+				s.Env, ok = stringMap(value)
+			default:
+				return fmt.Errorf("unknown script option %q", key)
+			}
+			if !ok {
+				return fmt.Errorf("invalid value for script option %q", key)
+			}
+		}
+		return nil
+	default:
+		return errors.New("a script must be a command or a table")
+	}
+}
+
+// MarshalTOML writes scripts with nothing but a command as a string
+func (s Script) MarshalTOML() ([]byte, error) {
+	if len(s.Deps) == 0 && len(s.Env) == 0 {
+		return []byte(quoteTOML(s.Run)), nil
+	}
+
+	var parts []string
+	if s.Run != "" {
+		parts = append(parts, "run = "+quoteTOML(s.Run))
+	}
+	if len(s.Deps) > 0 {
+		deps := make([]string, len(s.Deps))
+		for i, dep := range s.Deps {
+			deps[i] = quoteTOML(dep)
+		}
+		parts = append(parts, "deps = ["+strings.Join(deps, ", ")+"]")
+	}
+	if len(s.Env) > 0 {
+		var env []string
+		for _, key := range slices.Sorted(maps.Keys(s.Env)) {
+			env = append(env, quoteTOML(key)+" = "+quoteTOML(s.Env[key]))
+		}
+		parts = append(parts, "env = { "+strings.Join(env, ", ")+" }")
+	}
+
+	return []byte("{ " + strings.Join(parts, ", ") + " }"), nil
+}
+
+func quoteTOML(s string) string {
+	var buf bytes.Buffer
+	_ = toml.NewEncoder(&buf).Encode(map[string]string{"v": s})
+	_, value, _ := strings.Cut(strings.TrimSpace(buf.String()), " = ")
+	return value
+}
+
+func stringList(value any) ([]string, bool) {
+	items, ok := value.([]any)
+	if !ok {
+		return nil, false
+	}
+
+	list := make([]string, len(items))
+	for i, item := range items {
+		if list[i], ok = item.(string); !ok {
+			return nil, false
+		}
+	}
+	return list, true
+}
+
+func stringMap(value any) (map[string]string, bool) {
+	table, ok := value.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+
+	m := make(map[string]string, len(table))
+	for key, item := range table {
+		if m[key], ok = item.(string); !ok {
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+// Tasks lists the names of the scripts in sunbird.toml
+func (pm *PackageManager) Tasks() ([]string, error) {
+	config, err := LoadConfig(pm.configPath())
+	if err != nil {
+		return nil, err
+	}
+	return slices.Sorted(maps.Keys(config.Scripts)), nil
+}
+
+// RunTask runs the script called name from sunbird.toml, after the tasks it
+// depends on. Every task runs at most once. args are added to the command of
+// the task itself, but not to the ones it depends on.
+func (pm *PackageManager) RunTask(name string, args []string) error {
+	config, err := LoadConfig(pm.configPath())
+	if err != nil {
+		return err
+	}
+
+	order, err := taskOrder(config.Scripts, name)
+	if err != nil {
+		return err
+	}
+
+	for _, task := range order {
+		var taskArgs []string
+		if task == name {
+			taskArgs = args
+		}
+
+		if err := pm.runScript(task, config.Scripts[task], taskArgs); err != nil {
+			return fmt.Errorf("task %s failed: %w", task, err)
+		}
+	}
+	return nil
+}
+
+// taskOrder lists the tasks to run for name, dependencies first
+func taskOrder(scripts map[string]Script, name string) ([]string, error) {
+	var order []string
+	done := make(map[string]bool)
+
+	var visit func(name string, chain []string) error
+	visit = func(name string, chain []string) error {
+		if slices.Contains(chain, name) {
+			return fmt.Errorf("tasks depend on each other: %s", strings.Join(append(chain, name), " -> "))
+		}
+		if done[name] {
+			return nil
+		}
+
+		script, ok := scripts[name]
+		if !ok {
+			if len(chain) > 0 {
+				return fmt.Errorf("task %s depends on unknown task %s", chain[len(chain)-1], name)
+			}
+			return fmt.Errorf("unknown task %s, available tasks: %s",
+				name, strings.Join(slices.Sorted(maps.Keys(scripts)), ", "))
+		}
+
+		for _, dep := range script.Deps {
+			if err := visit(dep, append(chain, name)); err != nil {
+				return err
+			}
+		}
+
+		done[name] = true
+		order = append(order, name)
+		return nil
+	}
+
+	return order, visit(name, nil)
+}
+
+// runScript runs the command of a script from the project directory.
+// Commands starting with a .sb file run it with 'sunbird run', and the
+// rest run in the system shell, with sunbird on the PATH.
+func (pm *PackageManager) runScript(name string, script Script, args []string) error {
+	// Scripts without a command only run their dependencies
+	if script.Run == "" {
+		return nil
+	}
+
+	words, err := splitWords(script.Run)
+	if err != nil {
+		return fmt.Errorf("script %s: %w", name, err)
+	}
+	if len(words) == 0 {
+		return fmt.Errorf("script %s has nothing to run", name)
+	}
+
+	command := script.Run
+	for _, arg := range args {
+		command += " " + shellQuote(arg)
+	}
+	fmt.Fprintf(pm.Out, "> %s: %s\n", name, command)
+
+	var cmd *exec.Cmd
+	if strings.HasSuffix(words[0], ".sb") {
+		cmd = exec.Command(pm.Sunbird, append(append([]string{"run"}, words...), args...)...)
+	} else if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	cmd.Dir = pm.Dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = pm.Out
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if pm.Sunbird != "" {
+		path := filepath.Dir(pm.Sunbird) + string(os.PathListSeparator) + os.Getenv("PATH")
+		cmd.Env = append(cmd.Env, "PATH="+path)
+	}
+	for _, key := range slices.Sorted(maps.Keys(script.Env)) {
+		cmd.Env = append(cmd.Env, key+"="+script.Env[key])
+	}
+
+	return cmd.Run()
+}
+
+// shellQuote quotes an argument for the system shell
+func shellQuote(arg string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// splitWords splits a command into words like the shell does, so quotes
+// keep arguments with spaces together. Variables aren't expanded.
+func splitWords(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+
+		case c == '\\' && i+1 < len(command):
+			i++
+			word.WriteByte(command[i])
+
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated ' quote")
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				// Inside double quotes, backslashes only escape these
+				if command[i] == '\\' && i+1 < len(command) && strings.ContainsRune("\"\\$`", rune(command[i+1])) {
+					i++
+				}
+				word.WriteByte(command[i])
+			}
+			if i == len(command) {
+				return nil, errors.New(`unterminated " quote`)
+			}
+
+		default:
+			word.WriteByte(c)
+		}
+		inWord = true
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,139 @@
+package pkg_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/radeqq007/sunbird/internal/pkg"
+)
+
+const tasksConfig = `[package]
+name = "app"
+
+[scripts]
+prepare = "echo prepare >> log.txt"
+build = { run = "echo build $MODE >> log.txt", deps = ["prepare"], env = { MODE = "release" } }
+all = { deps = ["build", "prepare"] }
+serve = "src/server.sb --port 8080"
+greet = "src/greet.sb \"Ada Lovelace\" 'single quoted' two\\ words"
+unterminated = "src/greet.sb \"Ada"
+loop = { run = "true", deps = ["again"] }
+again = { deps = ["loop"] }
+broken = { deps = ["missing"] }
+fail = "exit 3"
+blank = { run = "   " }
+`
+
+func TestTasks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test scripts use sh")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"sunbird.toml": tasksConfig,
+		// Stands in for sunbird, recording how it's called
+		"bin/sunbird": "#!/bin/sh\nprintf '%s\\n' \"$@\" > args.txt\n",
+	})
+	if err := os.Chmod(filepath.Join(dir, "bin", "sunbird"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	pm := &pkg.PackageManager{Dir: dir, Out: &out, Sunbird: filepath.Join(dir, "bin", "sunbird")}
+
+	tasks, err := pm.Tasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"again", "all", "blank", "broken", "build", "fail", "greet", "loop", "prepare", "serve", "unterminated"}
+	if !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("expected tasks %v, got %v", expected, tasks)
+	}
+
+	// Dependencies run first and only once, and arguments only go to the task itself
+	if err := pm.RunTask("all", nil); err != nil {
+		t.Fatalf("task failed: %s", err)
+	}
+	if err := pm.RunTask("build", []string{"fast", "it's"}); err != nil {
+		t.Fatalf("task failed: %s", err)
+	}
+	if got := readFile(t, filepath.Join(dir, "log.txt")); got != "prepare\nbuild release\nprepare\nbuild release fast it's\n" {
+		t.Errorf("unexpected task output %q", got)
+	}
+	if !strings.Contains(out.String(), "> build: echo build $MODE >> log.txt 'fast' 'it'\\''s'") {
+		t.Errorf("expected the command to be shown, got %q", out.String())
+	}
+
+	// Scripts starting with a .sb file are run by sunbird
+	if err := pm.RunTask("serve", []string{"--debug"}); err != nil {
+		t.Fatalf("task failed: %s", err)
+	}
+	if got := readFile(t, filepath.Join(dir, "args.txt")); got != "run\nsrc/server.sb\n--port\n8080\n--debug\n" {
+		t.Errorf("unexpected sunbird arguments %q", got)
+	}
+
+	// Quoted arguments stay together, like in the shell
+	if err := pm.RunTask("greet", nil); err != nil {
+		t.Fatalf("task failed: %s", err)
+	}
+	if got := readFile(t, filepath.Join(dir, "args.txt")); got != "run\nsrc/greet.sb\nAda Lovelace\nsingle quoted\ntwo words\n" {
+		t.Errorf("unexpected sunbird arguments %q", got)
+	}
+
+	errorTests := []struct {
+		task     string
+		expected string
+	}{
+		{"loop", "tasks depend on each other: loop -> again -> loop"},
+		{"broken", "task broken depends on unknown task missing"},
+		{"nope", "unknown task nope, available tasks: again, all"},
+		{"fail", "task fail failed: exit status 3"},
+		{"blank", "task blank failed: script blank has nothing to run"},
+		{"unterminated", `task unterminated failed: script unterminated: unterminated " quote`},
+	}
+	for _, tt := range errorTests {
+		err := pm.RunTask(tt.task, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.task, tt.expected, err)
+		}
+	}
+}
+
+func TestScriptsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sunbird.toml")
+	writeFiles(t, filepath.Dir(path), map[string]string{"sunbird.toml": tasksConfig})
+
+	config, err := pkg.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pkg.SaveConfig(path, config); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := pkg.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(saved.Scripts, config.Scripts) {
+		t.Errorf("expected %+v, got %+v", config.Scripts, saved.Scripts)
+	}
+	if s := readFile(t, path); !strings.Contains(s, `prepare = "echo prepare >> log.txt"`) {
+		t.Errorf("expected plain commands to stay strings, got:\n%s", s)
+	}
+
+	writeFiles(t, filepath.Dir(path), map[string]string{"sunbird.toml": "[scripts]\nx = { run = 1 }\n"})
+	if _, err := pkg.LoadConfig(path); err == nil {
+		t.Error("expected an invalid script to fail")
+	}
+}
@@ -0,0 +1,299 @@
+package pkg
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/radeqq007/sunbird/internal/ast"
+	"github.com/radeqq007/sunbird/internal/lexer"
+	"github.com/radeqq007/sunbird/internal/modules"
+	"github.com/radeqq007/sunbird/internal/parser"
+)
+
+// Tidy removes the dependencies no file of the project imports, directly or
+// through another dependency, from sunbird.toml, sunbird.lock and .sb_modules.
+// In a workspace, every member is tidied.
+func (pm *PackageManager) Tidy() error {
+	config, err := LoadConfig(pm.configPath())
+	if err != nil {
+		return err
+	}
+
+	packages, err := pm.localPackages(config)
+	if err != nil {
+		return err
+	}
+
+	// Packages used from their own directory rather than from .sb_modules
+	localDirs := make(map[string]string)
+	for _, p := range packages {
+		localDirs[p.config.Package.Name] = p.dir
+	}
+
+	direct := make(map[string]map[string]bool)
+	var queue []string
+	for _, p := range packages {
+		if !p.member {
+			continue
+		}
+
+		imports, err := packageImports(p.dir)
+		if err != nil {
+			return err
+		}
+		direct[p.dir] = imports
+		queue = append(queue, slices.Collect(maps.Keys(imports))...)
+	}
+
+	// Follow the imports of the packages the project uses
+	reachable := make(map[string]bool)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if reachable[name] {
+			continue
+		}
+
+		dir, ok := localDirs[name]
+		if !ok {
+			if !pm.isInstalled(name) {
+				continue
+			}
+			dir = filepath.Join(pm.modulesDir(), name)
+		}
+		reachable[name] = true
+
+		imports, err := packageImports(dir)
+		if err != nil {
+			return err
+		}
+		for imported := range imports {
+			queue = append(queue, imported)
+		}
+	}
+
+	for _, p := range packages {
+		if p.member {
+			if err := pm.tidyConfig(p, direct[p.dir]); err != nil {
+				return err
+			}
+		}
+	}
+
+	lock, err := LoadLockfile(pm.lockPath())
+	if err != nil {
+		return err
+	}
+
+	lockedCount := len(lock.Packages)
+	lock.Packages = slices.DeleteFunc(lock.Packages, func(p LockedPackage) bool {
+		return !reachable[p.Name]
+	})
+	if len(lock.Packages) != lockedCount {
+		if err := SaveLockfile(pm.lockPath(), lock); err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(pm.modulesDir())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || reachable[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(pm.modulesDir(), entry.Name())); err != nil {
+			return err
+		}
+		fmt.Fprintf(pm.Out, "  - %s (%s)\n", entry.Name(), dependencyDirectory)
+	}
+
+	return nil
+}
+
+// tidyConfig removes the dependencies p doesn't import from its sunbird.toml
+func (pm *PackageManager) tidyConfig(p localPackage, imports map[string]bool) error {
+	configPath := filepath.Join(p.dir, "sunbird.toml")
+	shown := "sunbird.toml"
+	if rel, err := filepath.Rel(pm.root(), configPath); err == nil {
+		shown = filepath.ToSlash(rel)
+	}
+
+	var kept []DependencyInfo
+	for _, dep := range p.config.Package.Dependencies {
+		// Dependencies that were never installed have no name to look for
+		if dep.Name == "" || imports[dep.Name] {
+			kept = append(kept, dep)
+			continue
+		}
+		fmt.Fprintf(pm.Out, "  - %s (%s)\n", dep.Name, shown)
+	}
+
+	if len(kept) == len(p.config.Package.Dependencies) {
+		return nil
+	}
+
+	p.config.Package.Dependencies = kept
+	return SaveConfig(configPath, p.config)
+}
+
+// packageImports collects the names of the packages imported by the .sb files in dir,
+// leaving out built-in modules and relative imports. Hidden directories, like
+// .sb_modules, are skipped.
+func packageImports(dir string) (map[string]bool, error) {
+	imports := make(map[string]bool)
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(path) != ".sb" {
+			return nil
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		paths, err := fileImports(path, string(src))
+		if err != nil {
+			return err
+		}
+
+		for _, imported := range paths {
+			if isPackageImport(imported) {
+				imports[imported] = true
+			}
+		}
+		return nil
+	})
+
+	return imports, err
+}
+
+// fileImports lists the paths imported anywhere in the file at path,
+// whose source is src
+func fileImports(path, src string) ([]string, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s: %s", path, strings.Join(errs, "; "))
+	}
+
+	return nodeImports(program, nil), nil
+}
+
+// nodeImports appends the paths imported in node to paths. Imports can be
+// wherever statements can, so this goes into blocks and function bodies too.
+func nodeImports(node ast.Node, paths []string) []string {
+	switch node := node.(type) {
+	case *ast.Program:
+		return statementImports(node.Statements, paths)
+	case *ast.ImportStatement:
+		return append(paths, node.Path.Value)
+	case *ast.ExportStatement:
+		if node.From != nil {
+			paths = append(paths, node.From.Value)
+		}
+		return nodeImports(node.Declaration, paths)
+
+	case *ast.ExpressionStatement:
+		return nodeImports(node.Expression, paths)
+	case *ast.ReturnStatement:
+		return nodeImports(node.ReturnValue, paths)
+	case *ast.DeferStatement:
+		return nodeImports(node.Body, paths)
+	case *ast.PropertyAssignStatement:
+		return nodeImports(node.Value, nodeImports(node.Object, paths))
+	case *ast.BlockStatement:
+		return blockImports(node, paths)
+	case *ast.ForStatement:
+		return blockImports(node.Body, nodeImports(node.Iterable, paths))
+	case *ast.WhileStatement:
+		return blockImports(node.Body, nodeImports(node.Condition, paths))
+	case *ast.LoopStatement:
+		return blockImports(node.Body, paths)
+	case *ast.TryCatchStatement:
+		return blockImports(node.Finally, blockImports(node.Catch, blockImports(node.Try, paths)))
+
+	case *ast.FunctionLiteral:
+		return blockImports(node.Body, paths)
+	case *ast.IfExpression:
+		paths = nodeImports(node.Condition, paths)
+		return blockImports(node.Alternative, blockImports(node.Consequence, paths))
+	case *ast.PrefixExpression:
+		return nodeImports(node.Right, paths)
+	case *ast.InfixExpression:
+		return nodeImports(node.Right, nodeImports(node.Left, paths))
+	case *ast.IndexExpression:
+		return nodeImports(node.Index, nodeImports(node.Left, paths))
+	case *ast.PropertyExpression:
+		return nodeImports(node.Object, paths)
+	case *ast.CallExpression:
+		return expressionImports(node.Arguments, nodeImports(node.Function, paths))
+	case *ast.AssignExpression:
+		return nodeImports(node.Value, nodeImports(node.Name, paths))
+	case *ast.CompoundAssignExpression:
+		return nodeImports(node.Value, nodeImports(node.Name, paths))
+	case *ast.DeclarationExpression:
+		return nodeImports(node.Value, paths)
+	case *ast.RangeExpression:
+		return nodeImports(node.Step, nodeImports(node.End, nodeImports(node.Start, paths)))
+	case *ast.AwaitExpression:
+		return nodeImports(node.Value, paths)
+	case *ast.ArrayLiteral:
+		return expressionImports(node.Elements, paths)
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			paths = nodeImports(pair.Value, nodeImports(pair.Key, paths))
+		}
+	}
+	return paths
+}
+
+// blockImports is nodeImports for blocks, which are optional in some statements
+func blockImports(block *ast.BlockStatement, paths []string) []string {
+	if block == nil {
+		return paths
+	}
+	return statementImports(block.Statements, paths)
+}
+
+func statementImports(stmts []ast.Statement, paths []string) []string {
+	for _, stmt := range stmts {
+		paths = nodeImports(stmt, paths)
+	}
+	return paths
+}
+
+func expressionImports(exprs []ast.Expression, paths []string) []string {
+	for _, expr := range exprs {
+		paths = nodeImports(expr, paths)
+	}
+	return paths
+}
+
+func isPackageImport(path string) bool {
+	if strings.HasPrefix(path, ".") || filepath.IsAbs(path) {
+		return false
+	}
+
+	_, builtin := modules.Get(path)
+	return !builtin
+}
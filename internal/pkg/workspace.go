@@ -0,0 +1,236 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/radeqq007/sunbird/internal/vfs"
+)
+
+// WorkspaceConfig turns the directory of a sunbird.toml into a workspace.
+// Members are directories relative to it, and may contain glob patterns
+// like "packages/*". Members can import each other by name and share the
+// .sb_modules directory and sunbird.lock of the workspace.
+type WorkspaceConfig struct {
+	Members []string `toml:"members"`
+}
+
+// localPackage is a package used straight from its directory:
+// the project, a member of its workspace, or a path dependency
+type localPackage struct {
+	dir    string
+	config *Config
+	member bool // whether it's the project or a member of its workspace
+}
+
+func (p *localPackage) name() string {
+	if p.config.Package.Name != "" {
+		return p.config.Package.Name
+	}
+	return filepath.Base(p.dir)
+}
+
+// localPackages lists the packages whose dependencies are installed along with
+// the project's: the other members of its workspace and the path dependencies
+// of all of them. config is the project's own sunbird.toml.
+func (pm *PackageManager) localPackages(config *Config) ([]localPackage, error) {
+	dir, err := filepath.Abs(pm.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := []localPackage{{dir: dir, config: config, member: true}}
+	if root, members, ok := findWorkspace(dir); ok {
+		for _, p := range append([]localPackage{root}, members...) {
+			if p.dir != dir {
+				p.member = true
+				packages = append(packages, p)
+			}
+		}
+	}
+
+	for i := 0; i < len(packages); i++ {
+		for _, dep := range packages[i].config.Package.Dependencies {
+			if dep.Path == "" {
+				continue
+			}
+
+			target := resolveConfigPath(packages[i].dir, dep.Path)
+			if slices.ContainsFunc(packages, func(p localPackage) bool { return p.dir == target }) {
+				continue
+			}
+
+			config, err := LoadConfig(filepath.Join(target, "sunbird.toml"))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", &dep, err)
+			}
+			if dep.Name != "" && dep.Name != config.Package.Name {
+				return nil, fmt.Errorf("expected package %s, but %s contains %s", dep.Name, dep.Path, config.Package.Name)
+			}
+
+			packages = append(packages, localPackage{dir: target, config: config})
+		}
+	}
+
+	return packages, nil
+}
+
+// root is the directory holding .sb_modules and sunbird.lock: the root of
+// the workspace the project belongs to, or the project itself
+func (pm *PackageManager) root() string {
+	dir, err := filepath.Abs(pm.Dir)
+	if err != nil {
+		return pm.Dir
+	}
+
+	if root, _, ok := findWorkspace(dir); ok {
+		return root.dir
+	}
+	return dir
+}
+
+// FindPackage finds the directory of the package imported as name by a file in dir.
+// It looks at the path dependencies of the project dir belongs to, then at
+// the members of its workspace and finally at the installed packages.
+func FindPackage(dir, name string) (string, bool) {
+	if strings.HasPrefix(name, ".") || filepath.IsAbs(name) {
+		return "", false
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+
+	if project, ok := findProject(dir); ok {
+		if target, ok := pathDependency(project, name); ok {
+			return target, true
+		}
+
+		if _, members, ok := findWorkspace(project.dir); ok {
+			for _, member := range members {
+				if member.config.Package.Name == name {
+					return member.dir, true
+				}
+			}
+		}
+	}
+
+	if modulesDir, ok := findModulesDir(dir); ok {
+		packageDir := filepath.Join(modulesDir, name)
+		if info, err := vfs.Stat(packageDir); err == nil && info.IsDir() && isWithin(modulesDir, packageDir) {
+			return packageDir, true
+		}
+	}
+
+	return "", false
+}
+
+// pathDependency finds the path dependency of the project called name
+func pathDependency(project localPackage, name string) (string, bool) {
+	for _, dep := range project.config.Package.Dependencies {
+		if dep.Path == "" {
+			continue
+		}
+
+		target := resolveConfigPath(project.dir, dep.Path)
+		if dep.Name == name {
+			return target, true
+		}
+
+		if dep.Name == "" {
+			if config, err := LoadConfig(filepath.Join(target, "sunbird.toml")); err == nil && config.Package.Name == name {
+				return target, true
+			}
+		}
+	}
+	return "", false
+}
+
+// findProject finds the nearest sunbird.toml in dir or its parents
+func findProject(dir string) (localPackage, bool) {
+	for {
+		if config, err := LoadConfig(filepath.Join(dir, "sunbird.toml")); err == nil {
+			return localPackage{dir: dir, config: config}, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return localPackage{}, false
+		}
+		dir = parent
+	}
+}
+
+// findWorkspace finds the workspace the project in dir is the root or a member of
+func findWorkspace(dir string) (localPackage, []localPackage, bool) {
+	for current := dir; ; {
+		config, err := LoadConfig(filepath.Join(current, "sunbird.toml"))
+		if err == nil && config.Workspace != nil {
+			root := localPackage{dir: current, config: config}
+			members, err := workspaceMembers(root)
+			if err == nil && (current == dir || slices.ContainsFunc(members, func(m localPackage) bool {
+				return m.dir == dir
+			})) {
+				return root, members, true
+			}
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return localPackage{}, nil, false
+		}
+		current = parent
+	}
+}
+
+// workspaceMembers lists the packages matching the member patterns of the workspace
+func workspaceMembers(root localPackage) ([]localPackage, error) {
+	var members []localPackage
+
+	for _, pattern := range root.config.Workspace.Members {
+		matches, err := filepath.Glob(resolveConfigPath(root.dir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace member %q: %w", pattern, err)
+		}
+
+		for _, dir := range matches {
+			if dir == root.dir || slices.ContainsFunc(members, func(m localPackage) bool { return m.dir == dir }) {
+				continue
+			}
+
+			config, err := LoadConfig(filepath.Join(dir, "sunbird.toml"))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", dir, err)
+			}
+
+			members = append(members, localPackage{dir: dir, config: config})
+		}
+	}
+
+	return members, nil
+}
+
+// findModulesDir looks for the .sb_modules directory of the project dir belongs to,
+// walking up to its parents
+func findModulesDir(dir string) (string, bool) {
+	for {
+		modulesDir := filepath.Join(dir, dependencyDirectory)
+		if info, err := vfs.Stat(modulesDir); err == nil && info.IsDir() {
+			return modulesDir, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
@@ -1,6 +1,7 @@
 package repl
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -26,6 +27,7 @@ var keywords = []prompt.Suggest{
 	{Text: "return", Description: "Return value"},
 	{Text: "import", Description: "Import a module"},
 	{Text: "as", Description: "Alias for imported module"},
+	{Text: "from", Description: "Module to import names from"},
 	{Text: "for", Description: "Loop statement"},
 	{Text: "while", Description: "While loop statement"},
 	{Text: "null", Description: "Null value"},
@@ -43,6 +45,9 @@ var keywords = []prompt.Suggest{
 	{Text: "catch", Description: "Catch block for exception handling"},
 	{Text: "finally", Description: "Finally block for exception handling"},
 	{Text: "in", Description: "Iteration keyword"},
+	{Text: "async", Description: "Declare an async function"},
+	{Text: "await", Description: "Wait for a promise to settle"},
+	{Text: "defer", Description: "Run an expression when the function returns"},
 	{Text: "exit", Description: "Exit the REPL"},
 }
 
@@ -78,7 +83,7 @@ func Start(in io.Reader, out io.Writer) {
 		// TODO: suggest module names also after 'import "' etc.
 		if strings.HasSuffix(textBefore, "import ") {
 			var moduleSuggestions []prompt.Suggest
-			for mod := range modules.BuiltinModules {
+			for _, mod := range modules.Names() {
 				moduleSuggestions = append(moduleSuggestions, prompt.Suggest{Text: "\"" + mod + "\"", Description: "Imported module"})
 			}
 			return prompt.FilterHasPrefix(moduleSuggestions, word, true)
@@ -106,7 +111,7 @@ func EvalInput(input string, env *object.Environment, out io.Writer) error {
 		return printParserErrors(out, p.Errors())
 	}
 
-	evaluated := evaluator.Eval(program, env)
+	evaluated := evaluator.Eval(context.Background(), program, env)
 
 	if evaluated.IsNull() {
 		return nil
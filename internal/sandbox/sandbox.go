@@ -0,0 +1,182 @@
+// Package sandbox describes which capabilities a Sunbird script may use.
+//
+// The interpreter and the built-in modules consult the policy of the running
+// script before touching the filesystem, the network or the process.
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Error reports an action the policy doesn't allow
+type Error struct {
+	Action string
+}
+
+func (e *Error) Error() string {
+	return e.Action + " is not allowed by the sandbox policy"
+}
+
+// Policy is a set of capabilities granted to a script.
+// A nil *Policy grants everything, which is what scripts get by default.
+type Policy struct {
+	// read and write list the directories a script may access.
+	// A nil list leaves access unrestricted, an empty one denies it entirely.
+	read  []string
+	write []string
+
+	denyNet     bool
+	denyExit    bool
+	denyModules []string
+}
+
+// New returns a policy that allows everything until told otherwise
+func New() *Policy {
+	return &Policy{}
+}
+
+// DenyAll revokes every capability. Individual ones can be granted back afterwards.
+func (p *Policy) DenyAll() {
+	p.read = []string{}
+	p.write = []string{}
+	p.denyNet = true
+	p.denyExit = true
+}
+
+// AllowRead lets the script read files inside dir. The first call restricts
+// reading to the allowed directories only.
+func (p *Policy) AllowRead(dir string) error {
+	resolved, err := resolve(dir)
+	if err != nil {
+		return err
+	}
+
+	p.read = append(nonNil(p.read), resolved)
+	return nil
+}
+
+// AllowWrite lets the script create, modify and remove files inside dir.
+// The first call restricts writing to the allowed directories only.
+func (p *Policy) AllowWrite(dir string) error {
+	resolved, err := resolve(dir)
+	if err != nil {
+		return err
+	}
+
+	p.write = append(nonNil(p.write), resolved)
+	return nil
+}
+
+func (p *Policy) SetNet(allowed bool) {
+	p.denyNet = !allowed
+}
+
+func (p *Policy) SetExit(allowed bool) {
+	p.denyExit = !allowed
+}
+
+// DenyModule prevents the built-in module called name from being imported
+func (p *Policy) DenyModule(name string) {
+	p.denyModules = append(p.denyModules, name)
+}
+
+func (p *Policy) CheckRead(path string) error {
+	if p == nil {
+		return nil
+	}
+	return checkPath(p.read, "reading "+path, path)
+}
+
+func (p *Policy) CheckWrite(path string) error {
+	if p == nil {
+		return nil
+	}
+	return checkPath(p.write, "writing "+path, path)
+}
+
+func (p *Policy) CheckNet() error {
+	if p != nil && p.denyNet {
+		return &Error{Action: "network access"}
+	}
+	return nil
+}
+
+func (p *Policy) CheckExit() error {
+	if p != nil && p.denyExit {
+		return &Error{Action: "exiting the process"}
+	}
+	return nil
+}
+
+func (p *Policy) CheckImport(module string) error {
+	if p != nil && slices.Contains(p.denyModules, module) {
+		return &Error{Action: fmt.Sprintf("importing module %q", module)}
+	}
+	return nil
+}
+
+func checkPath(allowed []string, action, path string) error {
+	if allowed == nil {
+		return nil
+	}
+
+	resolved, err := resolve(path)
+	if err != nil {
+		return &Error{Action: action}
+	}
+
+	for _, dir := range allowed {
+		if within(dir, resolved) {
+			return nil
+		}
+	}
+
+	return &Error{Action: action}
+}
+
+// resolve makes path absolute and follows symlinks as far as the path exists,
+// so a link inside an allowed directory can't be used to escape it.
+func resolve(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	parent := filepath.Dir(abs)
+	if parent == abs {
+		return abs, nil
+	}
+
+	realParent, err := resolve(parent)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(realParent, filepath.Base(abs)), nil
+}
+
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func nonNil(dirs []string) []string {
+	if dirs == nil {
+		return []string{}
+	}
+	return dirs
+}
@@ -0,0 +1,103 @@
+package sandbox_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/radeqq007/sunbird/internal/sandbox"
+)
+
+func TestNilPolicyAllowsEverything(t *testing.T) {
+	var p *sandbox.Policy
+
+	checks := []error{
+		p.CheckRead("/etc/passwd"),
+		p.CheckWrite("/tmp/out"),
+		p.CheckNet(),
+		p.CheckExit(),
+		p.CheckImport("http"),
+	}
+
+	for _, err := range checks {
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+}
+
+func TestDenyAll(t *testing.T) {
+	p := sandbox.New()
+	p.DenyAll()
+
+	checks := []error{
+		p.CheckRead("file.txt"),
+		p.CheckWrite("file.txt"),
+		p.CheckNet(),
+		p.CheckExit(),
+	}
+
+	for _, err := range checks {
+		if err == nil {
+			t.Errorf("expected the action to be denied")
+		}
+	}
+}
+
+func TestAllowedDirectories(t *testing.T) {
+	root := t.TempDir()
+	data := filepath.Join(root, "data")
+	if err := os.Mkdir(data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A link inside the allowed directory pointing outside of it
+	if err := os.Symlink(root, filepath.Join(data, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	p := sandbox.New()
+	if err := p.AllowRead(data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{filepath.Join(data, "file.txt"), true},
+		{filepath.Join(data, "nested", "file.txt"), true},
+		{data, true},
+		{filepath.Join(root, "secret.txt"), false},
+		{filepath.Join(data, "..", "secret.txt"), false},
+		{filepath.Join(data, "escape", "secret.txt"), false},
+		{data + "2", false},
+	}
+
+	for _, tt := range tests {
+		err := p.CheckRead(tt.path)
+		if tt.allowed && err != nil {
+			t.Errorf("expected %s to be readable, got %s", tt.path, err)
+		}
+		if !tt.allowed && err == nil {
+			t.Errorf("expected reading %s to be denied", tt.path)
+		}
+	}
+
+	// Writing stays unrestricted since no write directories were given
+	if err := p.CheckWrite(filepath.Join(root, "out.txt")); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestDenyModule(t *testing.T) {
+	p := sandbox.New()
+	p.DenyModule("http")
+
+	if err := p.CheckImport("http"); err == nil {
+		t.Errorf("expected importing http to be denied")
+	}
+	if err := p.CheckImport("math"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -79,12 +79,16 @@ const (
 	Import   TokenType = "IMPORT"
 	Export   TokenType = "EXPORT"
 	As       TokenType = "AS"
+	From     TokenType = "FROM"
 	Break    TokenType = "BREAK"
 	Continue TokenType = "CONTINUE"
 	Try      TokenType = "TRY"
 	Catch    TokenType = "CATCH"
 	Finally  TokenType = "FINALLY"
 	In       TokenType = "IN"
+	Async    TokenType = "ASYNC"
+	Await    TokenType = "AWAIT"
+	Defer    TokenType = "DEFER"
 )
 
 func (t Token) String() string {
@@ -0,0 +1,175 @@
+// Package vfs lets the interpreter read files bundled into an executable by
+// 'sunbird build' as if they were on disk.
+//
+// A bundle is mounted at a directory, usually the one holding the executable.
+// Paths inside it are looked up in the bundle first, and everything the
+// bundle doesn't contain is read from the operating system as usual.
+// Bundled files are read-only: writes always go to the real filesystem.
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	mu      sync.RWMutex
+	mounted *mount
+)
+
+type mount struct {
+	dir   string
+	files fs.FS
+}
+
+// Mount makes the files of bundle appear under dir, replacing any bundle
+// mounted before. Names in bundle are slash separated and relative to dir.
+func Mount(dir string, bundle fs.FS) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	mounted = &mount{dir: dir, files: bundle}
+	return nil
+}
+
+// Unmount removes the mounted bundle, if there's one
+func Unmount() {
+	mu.Lock()
+	defer mu.Unlock()
+	mounted = nil
+}
+
+// lookup finds the bundle holding path and the name of path inside it
+func lookup(path string) (fs.FS, string, bool) {
+	mu.RLock()
+	m := mounted
+	mu.RUnlock()
+
+	if m == nil {
+		return nil, "", false
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", false
+	}
+
+	rel, err := filepath.Rel(m.dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, "", false
+	}
+
+	return m.files, filepath.ToSlash(rel), true
+}
+
+// ReadFile reads the file at path, from the bundle if it contains it
+func ReadFile(path string) ([]byte, error) {
+	if bundle, name, ok := lookup(path); ok {
+		data, err := fs.ReadFile(bundle, name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return data, err
+		}
+	}
+	return os.ReadFile(path)
+}
+
+// File is an open file that can be read from any offset
+type File interface {
+	fs.File
+	io.ReadSeeker
+}
+
+// Open opens the file at path for reading, from the bundle if it contains it.
+// Bundled files that can't seek, like compressed ones, are read into memory.
+func Open(path string) (File, error) {
+	if bundle, name, ok := lookup(path); ok {
+		f, err := bundle.Open(name)
+		if err == nil {
+			return seekable(f)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return os.Open(path)
+}
+
+func seekable(f fs.File) (File, error) {
+	if file, ok := f.(File); ok {
+		return file, nil
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return &memFile{Reader: bytes.NewReader(data), info: info}, nil
+}
+
+// memFile is a bundled file read into memory
+type memFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+// Stat describes the file at path, from the bundle if it contains it
+func Stat(path string) (fs.FileInfo, error) {
+	if bundle, name, ok := lookup(path); ok {
+		info, err := fs.Stat(bundle, name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return info, err
+		}
+	}
+	return os.Stat(path)
+}
+
+// ReadDir lists the directory at path sorted by name. Directories in the
+// bundle also list the files next to them on disk, if they exist there too.
+func ReadDir(path string) ([]fs.DirEntry, error) {
+	bundle, name, ok := lookup(path)
+	if !ok {
+		return os.ReadDir(path)
+	}
+
+	entries, err := fs.ReadDir(bundle, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return os.ReadDir(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	onDisk, err := os.ReadDir(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, entry := range onDisk {
+		if !slices.ContainsFunc(entries, func(e fs.DirEntry) bool { return e.Name() == entry.Name() }) {
+			entries = append(entries, entry)
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
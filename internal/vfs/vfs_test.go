@@ -0,0 +1,145 @@
+package vfs_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/radeqq007/sunbird/internal/vfs"
+)
+
+func TestMount(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "disk.txt"), []byte("disk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "both.txt"), []byte("disk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vfs.Mount(dir, fstest.MapFS{
+		"both.txt":    {Data: []byte("bundle")},
+		"src/main.sb": {Data: []byte("main")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer vfs.Unmount()
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"src/main.sb", "main"},
+		{"both.txt", "bundle"},
+		{"disk.txt", "disk"},
+		{"src/../both.txt", "bundle"},
+	}
+
+	for _, tt := range tests {
+		data, err := vfs.ReadFile(filepath.Join(dir, tt.path))
+		if err != nil {
+			t.Errorf("%s: %s", tt.path, err)
+			continue
+		}
+		if string(data) != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.path, tt.expected, data)
+		}
+	}
+
+	if _, err := vfs.ReadFile(filepath.Join(dir, "missing.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing file to not exist, got %v", err)
+	}
+
+	if info, err := vfs.Stat(filepath.Join(dir, "src")); err != nil || !info.IsDir() {
+		t.Errorf("expected src to be a directory, got %v, %v", info, err)
+	}
+
+	entries, err := vfs.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 3 || names[0] != "both.txt" || names[1] != "disk.txt" || names[2] != "src" {
+		t.Errorf("expected [both.txt disk.txt src], got %v", names)
+	}
+
+	// Paths outside the bundle come from the disk
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "src"), []byte("outside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := vfs.ReadFile(filepath.Join(outside, "src")); err != nil || string(data) != "outside" {
+		t.Errorf("expected \"outside\", got %q, %v", data, err)
+	}
+
+	vfs.Unmount()
+	if _, err := vfs.ReadFile(filepath.Join(dir, "src/main.sb")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the bundle to be gone after unmounting, got %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "disk.txt"), []byte("disk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Compressed files in zip archives can't seek
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create("bundled.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "0123456789")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vfs.Mount(dir, bundle); err != nil {
+		t.Fatal(err)
+	}
+	defer vfs.Unmount()
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"bundled.txt", "6789"},
+		{"disk.txt", "k"},
+	}
+
+	for _, tt := range tests {
+		f, err := vfs.Open(filepath.Join(dir, tt.path))
+		if err != nil {
+			t.Errorf("%s: %s", tt.path, err)
+			continue
+		}
+
+		if _, err := f.Seek(-int64(len(tt.expected)), io.SeekEnd); err != nil {
+			t.Errorf("%s: %s", tt.path, err)
+		}
+		if data, err := io.ReadAll(f); err != nil || string(data) != tt.expected {
+			t.Errorf("%s: expected %q, got %q (%v)", tt.path, tt.expected, data, err)
+		}
+		f.Close()
+	}
+
+	if _, err := vfs.Open(filepath.Join(dir, "missing.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing file to not exist, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+// Package sunbird embeds the Sunbird interpreter in Go programs.
+//
+// An Interpreter keeps its global scope between calls, so a host program can
+// load a script once and then call the functions it defines:
+//
+//	in := sunbird.New(sunbird.Options{Timeout: time.Second})
+//	if _, err := in.RunFile("rules.sb"); err != nil {
+//		return err
+//	}
+//	allowed, err := in.Call("is_allowed", user)
+package sunbird
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/radeqq007/sunbird/internal/evaluator"
+	"github.com/radeqq007/sunbird/internal/lexer"
+	"github.com/radeqq007/sunbird/internal/modules"
+	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
+	"github.com/radeqq007/sunbird/internal/object"
+	"github.com/radeqq007/sunbird/internal/parser"
+	"github.com/radeqq007/sunbird/internal/sandbox"
+)
+
+// Policy restricts what scripts may do, see NewPolicy
+type Policy = sandbox.Policy
+
+// NewPolicy returns a policy that allows everything until told otherwise
+func NewPolicy() *Policy {
+	return sandbox.New()
+}
+
+type Options struct {
+	// Timeout bounds every call to Eval, RunFile and Call. Zero means no timeout.
+	Timeout time.Duration
+
+	// MaxDepth is the maximum function call depth, zero means the default.
+	MaxDepth int
+
+	// MaxSteps is the number of evaluation steps every call may take, zero means no limit.
+	MaxSteps int64
+
+	// Policy restricts what scripts may do. A nil policy allows everything.
+	Policy *Policy
+}
+
+// Interpreter runs Sunbird code. It is safe for concurrent use,
+// though only one call evaluates code at a time.
+//
+// Go functions called by a script run while the call evaluating the script
+// holds the interpreter, so they must not call its methods themselves:
+// Eval, Call, Global and the others would wait for that call forever.
+type Interpreter struct {
+	env     *object.Environment
+	timeout time.Duration
+}
+
+func New(opts Options) *Interpreter {
+	env := object.NewEnvironment()
+	env.Runtime().SetLimits(object.Limits{MaxDepth: opts.MaxDepth, MaxSteps: opts.MaxSteps})
+	env.Runtime().SetPolicy(opts.Policy)
+
+	return &Interpreter{env: env, timeout: opts.Timeout}
+}
+
+// Eval evaluates src in the global scope and returns the value of its last statement
+func (in *Interpreter) Eval(src string) (Value, error) {
+	return in.EvalContext(context.Background(), src)
+}
+
+// EvalContext is like Eval, but stops evaluating once ctx is done
+func (in *Interpreter) EvalContext(ctx context.Context, src string) (Value, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return object.NewNull(), &ParseError{Errors: p.Errors()}
+	}
+
+	ctx, cancel := in.withTimeout(ctx)
+	defer cancel()
+
+	return result(evaluator.Eval(ctx, program, in.env))
+}
+
+// RunFile evaluates the script at path in the global scope. Imports and fs paths
+// in the script, and in code evaluated after it, resolve relative to its directory.
+func (in *Interpreter) RunFile(path string) (Value, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return object.NewNull(), err
+	}
+
+	in.env.SetFile(path)
+	return in.Eval(string(src))
+}
+
+// Call calls the global function called fnName. Arguments are converted with ToValue.
+func (in *Interpreter) Call(fnName string, args ...any) (Value, error) {
+	return in.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext is like Call, but stops evaluating once ctx is done
+func (in *Interpreter) CallContext(ctx context.Context, fnName string, args ...any) (Value, error) {
+	fn, ok := in.Global(fnName)
+	if !ok {
+		return object.NewNull(), fmt.Errorf("%s is not defined", fnName)
+	}
+
+	if !fn.IsFunction() && !fn.IsBuiltin() {
+		return object.NewNull(), fmt.Errorf("%s is not a function, got %s", fnName, fn.Kind())
+	}
+
+	values := make([]object.Value, len(args))
+	for i, arg := range args {
+		val, err := ToValue(arg)
+		if err != nil {
+			return object.NewNull(), fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		values[i] = val
+	}
+
+	ctx, cancel := in.withTimeout(ctx)
+	defer cancel()
+
+	return result(evaluator.Apply(ctx, fn, values, in.env))
+}
+
+// SetGlobal defines a global variable visible to every script the interpreter runs.
+// The value is converted with ToValue.
+func (in *Interpreter) SetGlobal(name string, value any) error {
+	val, err := ToValue(value)
+	if err != nil {
+		return err
+	}
+
+	rt := in.env.Runtime()
+	rt.Acquire()
+	defer rt.Release()
+
+	in.env.Set(name, val)
+	return nil
+}
+
+// Global returns the value of a global variable
+func (in *Interpreter) Global(name string) (Value, bool) {
+	rt := in.env.Runtime()
+	rt.Acquire()
+	defer rt.Release()
+
+	return in.env.Get(name)
+}
+
+// RegisterModule makes members importable as the module name in scripts run by
+// this interpreter. Members are converted with ToValue, so Go functions can be
+// exposed directly. A registered module takes precedence over a built-in module
+// with the same name.
+func (in *Interpreter) RegisterModule(name string, members map[string]any) error {
+	module, err := buildModule(name, members)
+	if err != nil {
+		return err
+	}
+
+	rt := in.env.Runtime()
+	rt.Acquire()
+	defer rt.Release()
+
+	rt.SetModule(name, module)
+	return nil
+}
+
+// RegisterModule adds a module importable by every interpreter, including the
+// ones already created, just like the modules of the standard library.
+// It fails if a built-in module with the same name exists.
+func RegisterModule(name string, members map[string]any) error {
+	module, err := buildModule(name, members)
+	if err != nil {
+		return err
+	}
+
+	return modules.Register(name, module)
+}
+
+func buildModule(name string, members map[string]any) (Value, error) {
+	mb := modbuilder.NewModuleBuilder()
+	for member, value := range members {
+		val, err := ToValue(value)
+		if err != nil {
+			return object.NewNull(), fmt.Errorf("%s.%s: %w", name, member, err)
+		}
+		mb.AddValue(member, val)
+	}
+
+	module := mb.Build()
+	module.AsModule().Name = name
+	return module, nil
+}
+
+func (in *Interpreter) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if in.timeout > 0 {
+		return context.WithTimeout(ctx, in.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func result(val Value) (Value, error) {
+	if val.IsError() && val.AsError().Propagating {
+		err := val.AsError()
+		return object.NewNull(), &Error{Message: err.Message, Line: err.Line, Col: err.Col}
+	}
+	return val, nil
+}
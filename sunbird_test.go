@@ -0,0 +1,295 @@
+package sunbird_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/radeqq007/sunbird"
+)
+
+func TestEval(t *testing.T) {
+	in := sunbird.New(sunbird.Options{})
+
+	val, err := in.Eval("x := 20; x + 22")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := sunbird.FromValue(val); got != int64(42) {
+		t.Errorf("expected 42, got %v", got)
+	}
+
+	// The global scope is kept between calls
+	val, err = in.Eval("x * 2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := sunbird.FromValue(val); got != int64(40) {
+		t.Errorf("expected 40, got %v", got)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	in := sunbird.New(sunbird.Options{})
+
+	_, err := in.Eval("1 + true")
+	var scriptErr *sunbird.Error
+	if !errors.As(err, &scriptErr) {
+		t.Fatalf("expected *sunbird.Error, got %T (%v)", err, err)
+	}
+	if scriptErr.Message != "TypeMismatchError: Integer + Boolean" {
+		t.Errorf("wrong error message: %q", scriptErr.Message)
+	}
+
+	_, err = in.Eval("x := ")
+	var parseErr *sunbird.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *sunbird.ParseError, got %T (%v)", err, err)
+	}
+}
+
+func TestRunFileAndCall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.sb")
+	src := `
+discount :: fn(order) {
+  if order.total > limit { return order.total / 10 }
+  return 0
+}
+`
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	in := sunbird.New(sunbird.Options{})
+	if err := in.SetGlobal("limit", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := in.RunFile(path); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	type order struct {
+		Total int `sunbird:"total"`
+	}
+
+	val, err := in.Call("discount", order{Total: 250})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := sunbird.FromValue(val); got != int64(25) {
+		t.Errorf("expected 25, got %v", got)
+	}
+
+	if _, err := in.Call("missing"); err == nil {
+		t.Error("expected an error calling an undefined function")
+	}
+}
+
+func TestRunFileImports(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.sb"), []byte(`import "./lib"; lib.answer`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "lib.sb"), []byte(`export answer :: 42`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	in := sunbird.New(sunbird.Options{})
+	val, err := in.RunFile(filepath.Join(dir, "main.sb"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := sunbird.FromValue(val); got != int64(42) {
+		t.Errorf("expected 42, got %v", got)
+	}
+}
+
+func TestRegisterModule(t *testing.T) {
+	in := sunbird.New(sunbird.Options{})
+
+	var greet sunbird.Function = func(args ...sunbird.Value) (sunbird.Value, error) {
+		if len(args) != 1 || !args[0].IsString() {
+			return sunbird.ToValue(nil)
+		}
+		return sunbird.ToValue("hello, " + args[0].AsString().Value)
+	}
+
+	var fail sunbird.Function = func(args ...sunbird.Value) (sunbird.Value, error) {
+		null, _ := sunbird.ToValue(nil)
+		return null, errors.New("host failure")
+	}
+
+	err := in.RegisterModule("host", map[string]any{
+		"greet":   greet,
+		"fail":    fail,
+		"version": "1.2.3",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := in.Eval(`import "host"; host.greet("sunbird") + " " + host.version`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := sunbird.FromValue(val); got != "hello, sunbird 1.2.3" {
+		t.Errorf("unexpected result %v", got)
+	}
+
+	val, err = in.Eval(`import "host"; try { host.fail() } catch e { e }`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := val.Inspect(); got != "RuntimeError: host failure (at line 1, col 32)" {
+		t.Errorf("unexpected error value %q", got)
+	}
+}
+
+func TestGoFunctions(t *testing.T) {
+	type user struct {
+		Name string `sunbird:"name"`
+		Age  int    `sunbird:"age"`
+	}
+
+	err := sunbird.RegisterModule("users", map[string]any{
+		"describe": func(u user) string {
+			return u.Name + " is " + strconv.Itoa(u.Age)
+		},
+		"lookup": func(name string) (*user, error) {
+			if name != "ada" {
+				return nil, errors.New("no such user: " + name)
+			}
+			return &user{Name: "Ada", Age: 36}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sunbird.RegisterModule("users", map[string]any{}); err == nil {
+		t.Error("expected an error registering a module twice")
+	}
+
+	in := sunbird.New(sunbird.Options{})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "users"; users.lookup("ada").age`, "36"},
+		{`import "users"; users.describe({ "name": "Bob", "age": 41 })`, `"Bob is 41"`},
+		{`import "users"; try { users.lookup("bob") } catch e { e }`, "RuntimeError: no such user: bob (at line 1, col 41)"},
+		{`import "users"; try { users.describe(1, 2) } catch e { e }`, "ArgumentError: expected 1 arguments, got 2 (at line 1, col 42)"},
+		{`import "users"; try { users.describe({ "age": "x" }) } catch e { e }`, "TypeError: argument 1: field age: expected Integer, got String (at line 1, col 52)"},
+	}
+
+	for _, tt := range tests {
+		val, err := in.Eval(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if got := val.Inspect(); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	in := sunbird.New(sunbird.Options{})
+
+	val, err := in.Eval(`{ "host": "localhost", "ports": [80, 443] }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Host  string `sunbird:"host"`
+		Ports []int  `sunbird:"ports"`
+	}
+	if err := sunbird.Decode(val, &config); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if config.Host != "localhost" || !reflect.DeepEqual(config.Ports, []int{80, 443}) {
+		t.Errorf("unexpected result %+v", config)
+	}
+
+	var n int
+	if err := sunbird.Decode(val, &n); err == nil {
+		t.Error("expected an error decoding a hash into an int")
+	}
+	if err := sunbird.Decode(val, n); err == nil {
+		t.Error("expected an error decoding into a non-pointer")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	in := sunbird.New(sunbird.Options{Timeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := in.Eval("loop {}"); err == nil {
+		t.Error("expected the timeout to stop the script")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("timeout took too long: %s", elapsed)
+	}
+
+	policy := sunbird.NewPolicy()
+	policy.SetExit(false)
+
+	in = sunbird.New(sunbird.Options{Policy: policy})
+	if _, err := in.Eval("exit()"); err == nil {
+		t.Error("expected the policy to deny exit")
+	}
+}
+
+func TestValueConversions(t *testing.T) {
+	type inner struct {
+		Name   string
+		Hidden string `sunbird:"-"`
+	}
+
+	tests := []struct {
+		input    any
+		expected any
+	}{
+		{nil, nil},
+		{true, true},
+		{7, int64(7)},
+		{uint8(7), int64(7)},
+		{1.5, 1.5},
+		{"text", "text"},
+		{[]byte("bytes"), "bytes"},
+		{[]int{1, 2}, []any{int64(1), int64(2)}},
+		{map[string]float64{"pi": 3.14}, map[string]any{"pi": 3.14}},
+		{&inner{Name: "x", Hidden: "y"}, map[string]any{"Name": "x"}},
+	}
+
+	for _, tt := range tests {
+		val, err := sunbird.ToValue(tt.input)
+		if err != nil {
+			t.Errorf("ToValue(%v): unexpected error: %s", tt.input, err)
+			continue
+		}
+
+		if got := sunbird.FromValue(val); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("round trip of %v: expected %#v, got %#v", tt.input, tt.expected, got)
+		}
+	}
+
+	if _, err := sunbird.ToValue(make(chan int)); err == nil {
+		t.Error("expected an error converting a channel")
+	}
+}
@@ -0,0 +1,54 @@
+package sunbird
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/radeqq007/sunbird/internal/modules/modbuilder"
+	"github.com/radeqq007/sunbird/internal/object"
+)
+
+// Value is a Sunbird value
+type Value = object.Value
+
+// Function is a Go function that receives its arguments as Sunbird values,
+// without any checking or conversion. A returned error is raised as a RuntimeError.
+type Function = modbuilder.Function
+
+// ToValue converts a Go value into a Sunbird value.
+//
+// Booleans, numbers and strings map onto their Sunbird counterparts, slices and
+// arrays become arrays, and maps and structs become hashes. Struct fields can be
+// renamed with a `sunbird:"name"` tag or skipped with `sunbird:"-"`.
+//
+// Functions become functions scripts can call. Their arguments are checked
+// against the parameter types and converted like Decode does, and a non-nil
+// error result is raised as a RuntimeError, so a function such as
+// func(s string, n int) (string, error) can be exposed as is.
+func ToValue(v any) (Value, error) {
+	return modbuilder.ToValue(v)
+}
+
+// FromValue converts a Sunbird value into a plain Go value: nil, bool, int64,
+// float64, string, []any or map[string]any. Values without a Go counterpart,
+// such as functions, are returned unchanged.
+func FromValue(v Value) any {
+	return modbuilder.FromValue(v)
+}
+
+// Decode stores the Sunbird value v in the Go value target points to,
+// converting it to target's type the same way ToValue would encode it.
+func Decode(v Value, target any) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("expected a non-nil pointer, got %T", target)
+	}
+
+	val, err := modbuilder.Decode(v, ptr.Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	ptr.Elem().Set(val)
+	return nil
+}